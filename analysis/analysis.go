@@ -0,0 +1,388 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package analysis defines analyzers that statically report the error
+// handling mistakes that the dares of package errdare detect dynamically.
+package analysis
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzers holds all analyzers of this package.
+var Analyzers = []*analysis.Analyzer{
+	DeferArgs,
+	IgnoredClose,
+	DeferInLoop,
+	RowsErr,
+}
+
+// DeferArgs reports deferred calls that are passed an error variable, like
+// defer w.CloseWithError(err). The argument is evaluated when the defer
+// statement is executed, not when the deferred call runs, so later
+// assignments to the variable are missed. Only local variables, including
+// parameters and results, that may be assigned after the defer statement are
+// reported.
+var DeferArgs = &analysis.Analyzer{
+	Name:     "deferargs",
+	Doc:      "report error variables passed to deferred calls, which are evaluated too early",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runDeferArgs,
+}
+
+// IgnoredClose reports calls to Close on writers whose error is discarded.
+// Writers typically report errors flushing buffered data from Close. Local
+// variables holding only files opened with os.Open, which are read-only, are
+// not reported.
+var IgnoredClose = &analysis.Analyzer{
+	Name:     "ignoredclose",
+	Doc:      "report ignored errors of Close on writers",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runIgnoredClose,
+}
+
+// DeferInLoop reports defer statements in loops. The deferred calls only run
+// when the function returns, so resources opened in the loop are held for
+// too long.
+var DeferInLoop = &analysis.Analyzer{
+	Name:     "deferinloop",
+	Doc:      "report defer statements in loops",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runDeferInLoop,
+}
+
+// RowsErr reports iterations over a *sql.Rows that are not followed by a
+// check of its Err method, which is the only way to learn that the iteration
+// stopped because of an error.
+var RowsErr = &analysis.Analyzer{
+	Name:     "rowserr",
+	Doc:      "report iterations over sql.Rows without a call to Err",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runRowsErr,
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func runDeferArgs(pass *analysis.Pass) (interface{}, error) {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	in.WithStack([]ast.Node{(*ast.DeferStmt)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		fn := enclosingFunc(stack)
+		if fn == nil {
+			return true
+		}
+		call := n.(*ast.DeferStmt).Call
+		for _, arg := range call.Args {
+			id, ok := arg.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+			if !ok || !types.Identical(v.Type(), errorType) {
+				continue
+			}
+			// Package variables, like io.EOF, are typically not assigned
+			// after initialization.
+			if v.Pkg() == nil || v.Parent() == v.Pkg().Scope() {
+				continue
+			}
+			if !assignedAfter(pass, fn, v, n.End()) {
+				continue
+			}
+			pass.Reportf(arg.Pos(),
+				"%s is evaluated when the defer statement is executed; use a closure to pass its final value", id.Name)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// enclosingFunc returns the innermost function declaration or literal of
+// stack, or nil if there is none.
+func enclosingFunc(stack []ast.Node) ast.Node {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return stack[i]
+		}
+	}
+	return nil
+}
+
+// assignedAfter reports whether v may be assigned in the function fn after
+// pos: by an assignment, by taking its address, or, if v is a named result of
+// fn, by a return statement with results.
+func assignedAfter(pass *analysis.Pass, fn ast.Node, v *types.Var, pos token.Pos) bool {
+	var typ *ast.FuncType
+	var body *ast.BlockStmt
+	switch fn := fn.(type) {
+	case *ast.FuncDecl:
+		typ, body = fn.Type, fn.Body
+	case *ast.FuncLit:
+		typ, body = fn.Type, fn.Body
+	}
+	isResult := false
+	if typ.Results != nil {
+		for _, f := range typ.Results.List {
+			for _, name := range f.Names {
+				if pass.TypesInfo.Defs[name] == v {
+					isResult = true
+				}
+			}
+		}
+	}
+	is := func(e ast.Expr) bool {
+		id, ok := ast.Unparen(e).(*ast.Ident)
+		return ok && pass.TypesInfo.ObjectOf(id) == v
+	}
+	assigned := false
+	var walk func(root ast.Node, returns bool)
+	walk = func(root ast.Node, returns bool) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			if assigned || n == nil || n.End() <= pos {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range n.Lhs {
+					assigned = assigned || is(lhs)
+				}
+			case *ast.UnaryExpr:
+				assigned = n.Op == token.AND && is(n.X)
+			case *ast.ReturnStmt:
+				assigned = returns && len(n.Results) > 0
+			case *ast.FuncLit:
+				// The returns of a function literal do not assign the
+				// results of fn.
+				walk(n.Body, false)
+				return false
+			}
+			return !assigned
+		})
+	}
+	walk(body, isResult)
+	return assigned
+}
+
+func runIgnoredClose(pass *analysis.Pass) (interface{}, error) {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	filter := []ast.Node{(*ast.ExprStmt)(nil), (*ast.DeferStmt)(nil), (*ast.GoStmt)(nil)}
+	in.WithStack(filter, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		var call *ast.CallExpr
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			call, _ = n.X.(*ast.CallExpr)
+		case *ast.DeferStmt:
+			call = n.Call
+		case *ast.GoStmt:
+			call = n.Call
+		}
+		if call == nil {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Close" {
+			return true
+		}
+		recv := pass.TypesInfo.TypeOf(sel.X)
+		if recv == nil || !isWriter(recv) {
+			return true
+		}
+		sig, ok := pass.TypesInfo.TypeOf(sel).(*types.Signature)
+		if !ok || sig.Results().Len() != 1 || !types.Identical(sig.Results().At(0).Type(), errorType) {
+			return true
+		}
+		if id, ok := ast.Unparen(sel.X).(*ast.Ident); ok {
+			v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+			if fn := enclosingFunc(stack); ok && fn != nil && openedReadOnly(pass, fn, v) {
+				return true
+			}
+		}
+		pass.Reportf(call.Pos(), "error returned by Close of writer %s is ignored", types.ExprString(sel.X))
+		return true
+	})
+	return nil, nil
+}
+
+// openedReadOnly reports whether v is a local variable of the function fn
+// that is only assigned the results of os.Open.
+func openedReadOnly(pass *analysis.Pass, fn ast.Node, v *types.Var) bool {
+	opened, other := false, false
+	assign := func(rhs ast.Expr) {
+		if isCallTo(pass, rhs, "os", "Open") {
+			opened = true
+		} else {
+			other = true
+		}
+	}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			// Parameters and results may hold any file.
+			for _, name := range n.Names {
+				other = other || pass.TypesInfo.Defs[name] == v
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range n.Lhs {
+				id, ok := ast.Unparen(lhs).(*ast.Ident)
+				if !ok || pass.TypesInfo.ObjectOf(id) != v {
+					continue
+				}
+				if len(n.Rhs) == len(n.Lhs) {
+					assign(n.Rhs[i])
+				} else {
+					assign(n.Rhs[0])
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				if pass.TypesInfo.Defs[name] != v {
+					continue
+				}
+				switch {
+				case len(n.Values) == len(n.Names):
+					assign(n.Values[i])
+				case len(n.Values) == 1:
+					assign(n.Values[0])
+				}
+			}
+		case *ast.UnaryExpr:
+			if id, ok := ast.Unparen(n.X).(*ast.Ident); ok && n.Op == token.AND {
+				other = other || pass.TypesInfo.ObjectOf(id) == v
+			}
+		}
+		return true
+	})
+	return opened && !other
+}
+
+// isCallTo reports whether e is a call to the function name of the package
+// with the given path.
+func isCallTo(pass *analysis.Pass, e ast.Expr, path, name string) bool {
+	call, ok := ast.Unparen(e).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+	return ok && fn.Name() == name && fn.Pkg() != nil && fn.Pkg().Path() == path
+}
+
+// isWriter reports whether t has a Write or CloseWithError method.
+func isWriter(t types.Type) bool {
+	ms := types.NewMethodSet(t)
+	if _, ok := t.Underlying().(*types.Interface); !ok {
+		if _, isPtr := t.(*types.Pointer); !isPtr {
+			ms = types.NewMethodSet(types.NewPointer(t))
+		}
+	}
+	for i := 0; i < ms.Len(); i++ {
+		switch ms.At(i).Obj().Name() {
+		case "Write", "CloseWithError":
+			return true
+		}
+	}
+	return false
+}
+
+func runDeferInLoop(pass *analysis.Pass) (interface{}, error) {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	in.WithStack([]ast.Node{(*ast.DeferStmt)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		// Walk up to the enclosing function, looking for loops.
+		for i := len(stack) - 2; i >= 0; i-- {
+			switch stack[i].(type) {
+			case *ast.FuncLit, *ast.FuncDecl:
+				return true
+			case *ast.ForStmt, *ast.RangeStmt:
+				pass.Reportf(n.Pos(), "defer in loop; deferred calls only run when the function returns")
+				return true
+			}
+		}
+		return true
+	})
+	return nil, nil
+}
+
+func runRowsErr(pass *analysis.Pass) (interface{}, error) {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	filter := []ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)}
+	in.Preorder(filter, func(n ast.Node) {
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.FuncLit:
+			body = n.Body
+		}
+		if body == nil {
+			return
+		}
+		next := map[types.Object]*ast.CallExpr{}
+		checked := map[types.Object]bool{}
+		ast.Inspect(body, func(n ast.Node) bool {
+			if _, ok := n.(*ast.FuncLit); ok {
+				return false // analyzed separately
+			}
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			id, ok := sel.X.(*ast.Ident)
+			if !ok || !isRows(pass.TypesInfo.TypeOf(id)) {
+				return true
+			}
+			obj := pass.TypesInfo.Uses[id]
+			switch sel.Sel.Name {
+			case "Next":
+				if next[obj] == nil {
+					next[obj] = call
+				}
+			case "Err":
+				checked[obj] = true
+			}
+			return true
+		})
+		for obj, call := range next {
+			if !checked[obj] {
+				pass.Reportf(call.Pos(), "iteration over %s is not followed by a call to %s.Err", obj.Name(), obj.Name())
+			}
+		}
+	})
+	return nil, nil
+}
+
+// isRows reports whether t is *database/sql.Rows.
+func isRows(t types.Type) bool {
+	p, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+	n, ok := p.Elem().(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "database/sql" && obj.Name() == "Rows"
+}
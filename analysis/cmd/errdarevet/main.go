@@ -0,0 +1,17 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command errdarevet runs the analyzers of package analysis.
+//
+// It can be run directly or through go vet:
+//
+//	go vet -vettool=$(which errdarevet) ./...
+package main
+
+import (
+	"github.com/mpvl/errdare/analysis"
+	"golang.org/x/tools/go/analysis/multichecker"
+)
+
+func main() { multichecker.Main(analysis.Analyzers...) }
@@ -0,0 +1,64 @@
+package deferargs
+
+import (
+	"errors"
+	"io"
+)
+
+type writer struct{}
+
+func (w *writer) Write(p []byte) (int, error)    { return len(p), nil }
+func (w *writer) CloseWithError(err error) error { return nil }
+
+var ErrX = errors.New("x")
+
+func deferArgs(w *writer) (err error) {
+	defer w.CloseWithError(err) // want "err is evaluated when the defer statement is executed"
+	defer func() { w.CloseWithError(err) }()
+	_, err = w.Write(nil)
+	return err
+}
+
+func namedResult(w *writer) (err error) {
+	defer w.CloseWithError(err) // want "err is evaluated when the defer statement is executed"
+	return errors.New("fail")
+}
+
+func address(w *writer) {
+	var err error
+	defer w.CloseWithError(err) // want "err is evaluated when the defer statement is executed"
+	read(&err)
+}
+
+func closure(w *writer) {
+	var err error
+	defer w.CloseWithError(err) // want "err is evaluated when the defer statement is executed"
+	func() { _, err = w.Write(nil) }()
+}
+
+func read(err *error) {}
+
+func sentinels(w *writer) {
+	defer w.CloseWithError(io.EOF)
+	defer w.CloseWithError(ErrX)
+	w.Write(nil)
+}
+
+func parameter(w *writer, err error) error {
+	defer w.CloseWithError(err)
+	_, errW := w.Write(nil)
+	return errW
+}
+
+func assignedBefore(w *writer) error {
+	_, err := w.Write(nil)
+	defer w.CloseWithError(err)
+	return nil
+}
+
+func literalResult(w *writer) (err error) {
+	defer w.CloseWithError(err)
+	f := func() error { return errors.New("fail") }
+	f()
+	return
+}
@@ -0,0 +1,16 @@
+package deferinloop
+
+import "os"
+
+func deferInLoop(names []string) {
+	for _, name := range names {
+		f, _ := os.Open(name)
+		defer f.Close() // want "defer in loop"
+		func() {
+			defer f.Close()
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		defer println(i) // want "defer in loop"
+	}
+}
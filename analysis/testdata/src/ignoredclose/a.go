@@ -0,0 +1,55 @@
+package ignoredclose
+
+import (
+	"io"
+	"os"
+)
+
+type writer struct{}
+
+func (w *writer) Write(p []byte) (int, error) { return len(p), nil }
+func (w *writer) Close() error                { return nil }
+
+type reader struct{}
+
+func (r *reader) Close() error { return nil }
+
+func ignoredClose(w *writer, r *reader, f *os.File, wc io.WriteCloser) error {
+	defer r.Close()
+	defer w.Close() // want "error returned by Close of writer w is ignored"
+	wc.Close()      // want "error returned by Close of writer wc is ignored"
+	f.Close()       // want "error returned by Close of writer f is ignored"
+	return w.Close()
+}
+
+// Files opened with os.Open are read-only, so closing them cannot lose
+// written data.
+func readOnly(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var g, _ = os.Open(name)
+	g.Close()
+	return nil
+}
+
+func written(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // want "error returned by Close of writer f is ignored"
+	g, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	g, err = os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	g.Close() // want "error returned by Close of writer g is ignored"
+	_, err = f.Write(nil)
+	return err
+}
@@ -0,0 +1,25 @@
+package rowserr
+
+import "database/sql"
+
+func rowsErr(db *sql.DB) error {
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() { // want "iteration over rows is not followed by a call to rows.Err"
+	}
+	return nil
+}
+
+func rowsErrChecked(db *sql.DB) error {
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
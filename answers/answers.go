@@ -0,0 +1,444 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package answers holds reference solutions to the dares of package errdare.
+//
+// Each dare has a solution using only the standard library, named after the
+// dare, and solutions using the github.com/mpvl/errc and github.com/mpvl/errd
+// packages, suffixed with Errc and Errd. Solutions can be passed to the Run
+// function of their dare, as in
+//
+//	errdare.RunCloudStorage(t, nil, answers.CloudStorage)
+//
+// Spoiler alert: attempt the dares before reading on.
+package answers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mpvl/errc"
+	"github.com/mpvl/errd"
+
+	"github.com/mpvl/errdare"
+)
+
+// CloudStorage solves the CloudStorage dare. The writer is closed with the
+// error returned by the solution or, if the solution panics, with the panic.
+func CloudStorage(t *errdare.CloudStorage) (err error) {
+	c, err := t.NewClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	r, err := t.NewReader()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errC := r.Close(); err == nil {
+			err = errC
+		}
+	}()
+
+	w := t.NewWriter(c)
+	defer func() {
+		if r := recover(); r != nil {
+			w.CloseWithError(r.(error))
+			panic(r)
+		}
+		w.CloseWithError(err)
+	}()
+
+	_, err = t.Copy(w, r)
+	return err
+}
+
+// CloudStorageCapture solves the CloudStorage dare using
+// errdare.CloseAndCapture and errdare.CloseWithErrorAndCapture, which pass the
+// panic, if any, to the writer.
+func CloudStorageCapture(t *errdare.CloudStorage) (err error) {
+	c, err := t.NewClient()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	r, err := t.NewReader()
+	if err != nil {
+		return err
+	}
+	defer errdare.CloseAndCapture(&err, r)
+
+	w := t.NewWriter(c)
+	defer errdare.CloseWithErrorAndCapture(&err, w)
+
+	_, err = t.Copy(w, r)
+	return err
+}
+
+// CloudStorageErrc solves the CloudStorage dare using package errc.
+func CloudStorageErrc(t *errdare.CloudStorage) (err error) {
+	e := errc.Catch(&err)
+	defer e.Handle()
+
+	c, err := t.NewClient()
+	e.Must(err)
+	e.Defer(c.Close, errc.Discard)
+
+	r, err := t.NewReader()
+	e.Must(err)
+	e.Defer(r.Close)
+
+	w := t.NewWriter(c)
+	e.Defer(w.CloseWithError)
+
+	_, err = t.Copy(w, r)
+	e.Must(err)
+	return nil
+}
+
+// CloudStorageErrd solves the CloudStorage dare using package errd.
+func CloudStorageErrd(t *errdare.CloudStorage) (err error) {
+	return errd.Run(func(e *errd.E) {
+		c, err := t.NewClient()
+		e.Must(err)
+		e.Defer(c.Close, errd.Discard)
+
+		r, err := t.NewReader()
+		e.Must(err)
+		e.Defer(r.Close)
+
+		w := t.NewWriter(c)
+		e.Defer(w.CloseWithError)
+
+		_, err = t.Copy(w, r)
+		e.Must(err)
+	})
+}
+
+// PipeConvert solves the PipeConvert dare. The goroutine writing to the pipe
+// closes it with any error or panic, so that Wait returns it.
+func PipeConvert(t *errdare.PipeConvert, r errdare.Reader) error {
+	pipeReader, pipeWriter := t.Pipe()
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				// The panic is passed to the reader of the pipe instead of
+				// crashing the program.
+				pipeWriter.CloseWithError(r.(error))
+				return
+			}
+			pipeWriter.CloseWithError(err)
+		}()
+		scanner := t.NewScanner(r)
+		for t.Scan(scanner) {
+			err = t.WriteScanned(pipeWriter, scanner)
+			if err != nil {
+				return
+			}
+		}
+		err = t.ScanErr(scanner)
+	}()
+	return t.Wait(pipeReader)
+}
+
+// PipeConvertErrd solves the PipeConvert dare using package errd.
+func PipeConvertErrd(t *errdare.PipeConvert, r errdare.Reader) error {
+	pipeReader, pipeWriter := t.Pipe()
+	// errd.Run passes panics to the deferred functions and then panics
+	// again, which errdare.Go checks instead of crashing.
+	errdare.Go(t, func() {
+		errd.Run(func(e *errd.E) {
+			e.Defer(pipeWriter.CloseWithError)
+			scanner := t.NewScanner(r)
+			for t.Scan(scanner) {
+				e.Must(t.WriteScanned(pipeWriter, scanner))
+			}
+			e.Must(t.ScanErr(scanner))
+		})
+	})
+	return t.Wait(pipeReader)
+}
+
+// PipeConvertGroup solves the PipeConvert dare using a group, which waits for
+// the goroutine writing to the pipe and returns its error or panic.
+func PipeConvertGroup(t *errdare.PipeConvert, r errdare.Reader) error {
+	g, _ := errdare.NewGroup(t, "writer")
+	pipeReader, pipeWriter := t.Pipe()
+	g.Go(func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				pipeWriter.CloseWithError(r.(error))
+				panic(r) // recorded by the group
+			}
+			pipeWriter.CloseWithError(err)
+		}()
+		scanner := t.NewScanner(r)
+		for t.Scan(scanner) {
+			if err := t.WriteScanned(pipeWriter, scanner); err != nil {
+				return err
+			}
+		}
+		return t.ScanErr(scanner)
+	})
+	err := t.Wait(pipeReader)
+	if errG := g.Wait(); err == nil {
+		err = errG
+	}
+	return err
+}
+
+// TrickyCatch solves the TrickyCatch dare. The original writer must be closed
+// with the first error or panic, including those of closing the wrapper.
+func TrickyCatch(t *errdare.TrickyCatch) (err error) {
+	w, err := t.NewWriter()
+	if err != nil {
+		return err
+	}
+	isPanic := false
+	defer func() {
+		r := recover()
+		if r != nil && !isPanic {
+			err = r.(error)
+			isPanic = true
+		}
+		if errC := w.CloseWithError(err); err == nil {
+			err = errC
+		}
+		if isPanic {
+			panic(err)
+		}
+	}()
+
+	ww, err := t.NewWrapper(w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// A panic of the close is caught by the deferred function above.
+		if errC := ww.Close(); err == nil {
+			err = errC
+		}
+	}()
+
+	err = t.WriteSomething(ww)
+	return err
+}
+
+// TrickyCatchErrd solves the TrickyCatch dare using package errd.
+func TrickyCatchErrd(t *errdare.TrickyCatch) (err error) {
+	return errd.Run(func(e *errd.E) {
+		w, err := t.NewWriter()
+		e.Must(err)
+		e.Defer(w.CloseWithError)
+
+		ww, err := t.NewWrapper(w)
+		e.Must(err)
+		e.Defer(ww.Close)
+
+		e.Must(t.WriteSomething(ww))
+	})
+}
+
+// TrickyCatchErrc solves the TrickyCatch dare using package errc.
+func TrickyCatchErrc(t *errdare.TrickyCatch) (err error) {
+	e := errc.Catch(&err)
+	defer e.Handle()
+
+	w, err := t.NewWriter()
+	e.Must(err)
+	e.Defer(w.CloseWithError)
+
+	ww, err := t.NewWrapper(w)
+	e.Must(err)
+	e.Defer(ww.Close)
+
+	return t.WriteSomething(ww)
+}
+
+// Pipeline solves the Pipeline dare. Each Writer is closed by a deferred call
+// to closeWithError, which also passes panics to the Writers.
+func Pipeline(p *errdare.Pipeline) (err error) {
+	w, err := p.NewSink()
+	if err != nil {
+		return err
+	}
+	defer closeWithError(w, &err)
+
+	for i := 0; i < p.N(); i++ {
+		if w, err = p.NewStage(w); err != nil {
+			return err
+		}
+		defer closeWithError(w, &err)
+	}
+	return p.Write(w)
+}
+
+// closeWithError closes w with the error pointed to by err, or with the
+// current panic, if any. It must be called directly by a defer statement. If
+// the close fails and *err is nil, the error of the close is assigned to *err.
+func closeWithError(w errdare.Writer, err *error) {
+	if r := recover(); r != nil {
+		w.CloseWithError(r.(error))
+		panic(r)
+	}
+	if errC := w.CloseWithError(*err); *err == nil {
+		*err = errC
+	}
+}
+
+// MultiReader solves the MultiReader dare.
+func MultiReader(m *errdare.MultiReader) (err error) {
+	var rs []errdare.Reader
+	for i := 0; i < m.N(); i++ {
+		r, errR := m.NewReader()
+		if errR != nil {
+			return errR
+		}
+		// The deferred function must refer to the named result, not to an
+		// error variable declared in the loop.
+		defer func() {
+			if errC := r.Close(); err == nil {
+				err = errC
+			}
+		}()
+		rs = append(rs, r)
+	}
+	return m.Read(m.Concat(rs...))
+}
+
+// MultiReaderCapture solves the MultiReader dare using errdare.CloseAndCapture,
+// which evaluates each Reader when the defer statement is executed.
+func MultiReaderCapture(m *errdare.MultiReader) (err error) {
+	var rs []errdare.Reader
+	for i := 0; i < m.N(); i++ {
+		r, errR := m.NewReader()
+		if errR != nil {
+			return errR
+		}
+		// err must refer to the named result, not to a variable declared in
+		// the loop.
+		defer errdare.CloseAndCapture(&err, r)
+		rs = append(rs, r)
+	}
+	return m.Read(m.Concat(rs...))
+}
+
+// ErrorCodes solves the ErrorCodes dare.
+func ErrorCodes(c *errdare.ErrorCodes) error {
+	for {
+		err := c.Delete()
+		var ce *errdare.CodeError
+		if err != nil && errors.As(err, &ce) {
+			switch ce.Code() {
+			case errdare.Unavailable:
+				continue
+			case errdare.NotFound:
+				err = nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return c.Commit()
+	}
+}
+
+// GracefulShutdown solves the GracefulShutdown dare. Only the cancellations
+// reported after the shutdown was initiated are caused by it.
+func GracefulShutdown(g *errdare.GracefulShutdown) (err error) {
+	srv := g.Start()
+	defer func() {
+		g.Shutdown(srv)
+		if errD := g.Drain(); err == nil && !errors.Is(errD, context.Canceled) {
+			err = errD
+		}
+	}()
+	return g.Process(srv)
+}
+
+// PartialResponse solves the PartialResponse dare. The Response is closed even
+// if Send returns an error, but only read if it does not.
+func PartialResponse(p *errdare.PartialResponse) error {
+	resp, err := p.Send()
+	if resp != nil {
+		defer resp.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return p.ReadAll(resp)
+}
+
+// BatchUpdate solves the BatchUpdate dare. The errors of Apply are assigned to
+// the named result, so that the deferred function passes them to
+// CloseWithError.
+func BatchUpdate(b *errdare.BatchUpdate) (err error) {
+	tx, err := b.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errC := tx.CloseWithError(err); err == nil {
+			err = errC
+		}
+	}()
+	for i := 0; i < b.N(); i++ {
+		if err = b.Apply(tx, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Connect solves the Connect dare. Close is deferred only after the error of
+// Dial was checked, as the Conn is nil otherwise.
+func Connect(c *errdare.Connect) error {
+	conn, err := c.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return c.Query(conn)
+}
+
+// Upload solves the Upload dare. A single deferred function closes the part
+// and the upload in the order required by the outcome: the part is closed
+// before the upload is committed, but only after the upload is aborted.
+func Upload(u *errdare.Upload) (err error) {
+	up, err := u.Begin()
+	if err != nil {
+		return err
+	}
+	part, err := u.NewPart(up)
+	if err != nil {
+		up.CloseWithError(err)
+		return err
+	}
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = r.(error)
+		}
+		if err == nil {
+			// The part is flushed before the upload is committed.
+			if err = part.Close(); err == nil {
+				err = up.CloseWithError(nil)
+			} else {
+				up.CloseWithError(err)
+			}
+			return
+		}
+		// The upload is aborted before the part is closed.
+		up.CloseWithError(err)
+		part.Close()
+		if r != nil {
+			panic(r)
+		}
+	}()
+	return u.Write(part)
+}
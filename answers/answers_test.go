@@ -0,0 +1,73 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package answers
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/mpvl/errdare"
+	"github.com/mpvl/errdare/errtest"
+)
+
+type namedConfig struct {
+	name string
+	cfg  *errtest.Config
+}
+
+// flagConfig is the configuration selected with the flags of
+// errtest.RegisterFlags, under which the answers are run in addition to
+// configs, so that, for instance, go test -pedantic shows which answers fail
+// under the strictest settings.
+var flagConfig = errtest.RegisterFlags(flag.CommandLine)
+
+// configs lists the configurations under which all answers must pass. The
+// answers do not close values when a panic occurs and do not preserve the
+// order of panics, so they are not expected to pass with RequireCloseOnPanic
+// or without IgnorePanicOrder.
+var configs = []namedConfig{
+	{"default", &errtest.Config{IgnorePanicOrder: true}},
+	{"AllowWrapping", &errtest.Config{IgnorePanicOrder: true, AllowWrapping: true}},
+	{"CaptureStacks", &errtest.Config{IgnorePanicOrder: true, CaptureStacks: true}},
+	{"DetectCollected", &errtest.Config{IgnorePanicOrder: true, DetectCollected: true}},
+	{"GoroutineGrace", &errtest.Config{IgnorePanicOrder: true, GoroutineGrace: time.Second}},
+	{"ForbidRecover", &errtest.Config{IgnorePanicOrder: true, ForbidRecover: true}},
+}
+
+func TestAnswers(t *testing.T) {
+	answers := []struct {
+		name string
+		run  func(t *testing.T, cfg *errtest.Config)
+	}{
+		{"CloudStorage", func(t *testing.T, cfg *errtest.Config) { errdare.RunCloudStorage(t, cfg, CloudStorage) }},
+		{"CloudStorageCapture", func(t *testing.T, cfg *errtest.Config) { errdare.RunCloudStorage(t, cfg, CloudStorageCapture) }},
+		{"CloudStorageErrc", func(t *testing.T, cfg *errtest.Config) { errdare.RunCloudStorage(t, cfg, CloudStorageErrc) }},
+		{"CloudStorageErrd", func(t *testing.T, cfg *errtest.Config) { errdare.RunCloudStorage(t, cfg, CloudStorageErrd) }},
+		{"PipeConvert", func(t *testing.T, cfg *errtest.Config) { errdare.RunPipeConvert(t, cfg, PipeConvert) }},
+		{"PipeConvertErrd", func(t *testing.T, cfg *errtest.Config) { errdare.RunPipeConvert(t, cfg, PipeConvertErrd) }},
+		{"PipeConvertGroup", func(t *testing.T, cfg *errtest.Config) { errdare.RunPipeConvert(t, cfg, PipeConvertGroup) }},
+		{"TrickyCatch", func(t *testing.T, cfg *errtest.Config) { errdare.RunTrickyCatch(t, cfg, TrickyCatch) }},
+		{"TrickyCatchErrc", func(t *testing.T, cfg *errtest.Config) { errdare.RunTrickyCatch(t, cfg, TrickyCatchErrc) }},
+		{"TrickyCatchErrd", func(t *testing.T, cfg *errtest.Config) { errdare.RunTrickyCatch(t, cfg, TrickyCatchErrd) }},
+		{"Pipeline", func(t *testing.T, cfg *errtest.Config) { errdare.RunPipeline(t, cfg, 3, Pipeline) }},
+		{"MultiReader", func(t *testing.T, cfg *errtest.Config) { errdare.RunMultiReader(t, cfg, 3, MultiReader) }},
+		{"MultiReaderCapture", func(t *testing.T, cfg *errtest.Config) { errdare.RunMultiReader(t, cfg, 3, MultiReaderCapture) }},
+		{"ErrorCodes", func(t *testing.T, cfg *errtest.Config) { errdare.RunErrorCodes(t, cfg, ErrorCodes) }},
+		{"GracefulShutdown", func(t *testing.T, cfg *errtest.Config) { errdare.RunGracefulShutdown(t, cfg, GracefulShutdown) }},
+		{"PartialResponse", func(t *testing.T, cfg *errtest.Config) { errdare.RunPartialResponse(t, cfg, PartialResponse) }},
+		{"BatchUpdate", func(t *testing.T, cfg *errtest.Config) { errdare.RunBatchUpdate(t, cfg, 3, BatchUpdate) }},
+		{"Connect", func(t *testing.T, cfg *errtest.Config) { errdare.RunConnect(t, cfg, Connect) }},
+		{"Upload", func(t *testing.T, cfg *errtest.Config) { errdare.RunUpload(t, cfg, Upload) }},
+	}
+	all := append(configs[:len(configs):len(configs)], namedConfig{"flags", flagConfig()})
+	for _, a := range answers {
+		for _, c := range all {
+			t.Run(a.name+"/"+c.name, func(t *testing.T) {
+				a.run(t, c.cfg)
+			})
+		}
+	}
+}
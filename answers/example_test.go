@@ -0,0 +1,33 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package answers_test
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare"
+	"github.com/mpvl/errdare/answers"
+	"github.com/mpvl/errdare/errtest"
+)
+
+var t *testing.T
+
+// cfg is the configuration under which the answers pass.
+var cfg = &errtest.Config{IgnorePanicOrder: true}
+
+func ExampleCloudStorage() {
+	// In a test function with t *testing.T:
+	errdare.RunCloudStorage(t, cfg, answers.CloudStorage)
+}
+
+func ExamplePipeConvert() {
+	// In a test function with t *testing.T:
+	errdare.RunPipeConvert(t, cfg, answers.PipeConvert)
+}
+
+func ExampleTrickyCatch() {
+	// In a test function with t *testing.T:
+	errdare.RunTrickyCatch(t, cfg, answers.TrickyCatch)
+}
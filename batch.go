@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// The BatchUpdate challenge: apply n updates in order within a transaction.
+// The transaction returned by Begin must be closed with CloseWithError,
+// passing the first error encountered, which rolls it back, or nil, which
+// commits it. Updating must stop at the first error of Apply, which must be
+// returned, as must the error of committing the transaction.
+//
+// The dare is constructed so that declaring a new err with := in a nested
+// block, instead of assigning to the named result, causes the wrong error to
+// be returned and the wrong error to be passed to CloseWithError.
+//
+// A simple, but incorrect, implementation is:
+//
+//	func TestBatchUpdate(t *testing.T) {
+//		errdare.RunBatchUpdate(t, nil, 3, func(b *errdare.BatchUpdate) (err error) {
+//			tx, err := b.Begin()
+//			if err != nil {
+//				return err
+//			}
+//			defer func() {
+//				if errC := tx.CloseWithError(err); err == nil {
+//					err = errC
+//				}
+//			}()
+//			for i := 0; i < b.N(); i++ {
+//				if err := b.Apply(tx, i); err != nil {
+//					break // err shadows the named result
+//				}
+//			}
+//			return err
+//		})
+//	}
+type BatchUpdate struct {
+	s       *errtest.Simulation
+	n       int
+	applied int
+	failed  bool
+}
+
+func init() {
+	Register("BatchUpdate", Info{
+		Description: "apply a batch of updates in a transaction, returning the first error",
+		Tags:        []string{"close", "shadow", "scaled"},
+		Difficulty:  Intermediate,
+		Concepts:    []string{"named results", "variable shadowing", "CloseWithError"},
+	}, nil)
+}
+
+// RunBatchUpdate runs the BatchUpdate dare with n updates as a test. The
+// options, if any, override cfg for this dare only.
+func RunBatchUpdate(t *testing.T, cfg *errtest.Config, n int, f func(b *BatchUpdate) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, scale(t, cfg, n, opts), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&BatchUpdate{s: s, n: n}), fmt.Sprintf("apply%d", n-1))
+	})
+}
+
+// N returns the number of updates that must be applied.
+func (b *BatchUpdate) N() int { return b.n }
+
+// Begin starts the transaction, which must be closed with CloseWithError.
+func (b *BatchUpdate) Begin() (Writer, error) {
+	return ve(b.s, "tx",
+		errtest.Describe("the transaction returned by Begin"),
+		errtest.CloseOptions(errtest.NoPanic()))
+}
+
+// Apply applies update i within the transaction tx. Updates must be applied
+// in order, and not after an earlier update failed.
+func (b *BatchUpdate) Apply(tx Writer, i int) error {
+	require(b.s, tx, "tx")
+	switch {
+	case b.failed:
+		b.s.Fatalf("Apply called after an update failed")
+	case i != b.applied:
+		b.s.Fatalf("Apply(%d) called; want Apply(%d)", i, b.applied)
+	}
+	b.applied++
+	err := e(b.s, fmt.Sprintf("apply%d", i), errtest.NoPanic())
+	b.failed = err != nil
+	return err
+}
@@ -0,0 +1,77 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestBatchUpdate(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(b *BatchUpdate) error
+	}{{
+		desc: "shadowed in loop",
+		f: func(b *BatchUpdate) (err error) {
+			tx, err := b.Begin()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if errC := tx.CloseWithError(err); err == nil {
+					err = errC
+				}
+			}()
+			for i := 0; i < b.N(); i++ {
+				if err := b.Apply(tx, i); err != nil {
+					break
+				}
+			}
+			return err
+		},
+	}, {
+		desc: "shadowed in block",
+		f: func(b *BatchUpdate) (err error) {
+			tx, err := b.Begin()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if errC := tx.CloseWithError(err); err == nil {
+					err = errC
+				}
+			}()
+			{
+				i := 0
+				for err := error(nil); err == nil && i < b.N(); i++ {
+					err = b.Apply(tx, i)
+				}
+			}
+			return err
+		},
+	}, {
+		desc: "argument evaluated early",
+		f: func(b *BatchUpdate) (err error) {
+			tx, err := b.Begin()
+			if err != nil {
+				return err
+			}
+			defer tx.CloseWithError(err)
+			for i := 0; i < b.N(); i++ {
+				if err = b.Apply(tx, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			RunBatchUpdate(t, nil, 2, tc.f, errtest.WithExpectFailure())
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Builder declares a dare in terms of the resources it opens and the steps
+// it performs, as an alternative to writing the dare against errtest
+// directly. For instance, a dare similar to CloudStorage is declared as
+//
+//	NewDare("Storage").
+//		Resource("client", Closeable, IgnoreCloseError).
+//		Resource("writer", CloseWithError, Requires("client")).
+//		Step("copy", Requires("writer")).
+//		MustCall("copy")
+type Builder struct {
+	name     string
+	decls    map[string]*decl
+	mustCall []string
+}
+
+type decl struct {
+	resource  bool
+	closeable bool
+	withError bool
+	requires  []string
+	opts      []errtest.Option
+}
+
+// An Option configures a resource or step declared with a Builder.
+type Option func(d *decl)
+
+var (
+	// Closeable declares a resource that must be closed.
+	Closeable Option = func(d *decl) { d.closeable = true }
+
+	// CloseWithError declares a resource that must be closed with
+	// CloseWithError, passing any error that occurred.
+	CloseWithError Option = func(d *decl) { d.closeable, d.withError = true, true }
+
+	// IgnoreCloseError declares a resource of which the error returned by
+	// its close may be ignored.
+	IgnoreCloseError Option = func(d *decl) {
+		d.opts = append(d.opts, errtest.CloseOptions(errtest.IgnoreError()))
+	}
+
+	// NoError declares a resource or step that never returns an error.
+	NoError Option = func(d *decl) { d.opts = append(d.opts, errtest.NoError()) }
+
+	// NoPanic declares a resource or step that never panics.
+	NoPanic Option = func(d *decl) { d.opts = append(d.opts, errtest.NoPanic()) }
+)
+
+// Requires declares that a resource or step must be passed the values opened
+// for the given keys, in order.
+func Requires(keys ...string) Option {
+	return func(d *decl) { d.requires = append(d.requires, keys...) }
+}
+
+// Options adds simulation options to a resource or step.
+func Options(opts ...errtest.Option) Option {
+	return func(d *decl) { d.opts = append(d.opts, opts...) }
+}
+
+// NewDare returns a Builder for a dare with the given name.
+func NewDare(name string) *Builder {
+	return &Builder{name: name, decls: map[string]*decl{}}
+}
+
+// Name returns the name of the dare.
+func (b *Builder) Name() string { return b.name }
+
+// Resource declares a resource that is opened with Instance.Open.
+func (b *Builder) Resource(key string, opts ...Option) *Builder {
+	d := &decl{resource: true}
+	for _, o := range opts {
+		o(d)
+	}
+	b.decls[key] = d
+	return b
+}
+
+// Step declares a step that is performed with Instance.Do.
+func (b *Builder) Step(key string, opts ...Option) *Builder {
+	d := &decl{}
+	for _, o := range opts {
+		o(d)
+	}
+	b.decls[key] = d
+	return b
+}
+
+// MustCall declares steps that must be performed if no fault occurs.
+func (b *Builder) MustCall(keys ...string) *Builder {
+	b.mustCall = append(b.mustCall, keys...)
+	return b
+}
+
+// Run runs the declared dare as a test, with f as the solution. The options,
+// if any, override cfg for this dare only.
+func (b *Builder) Run(t *testing.T, cfg *errtest.Config, f func(d *Instance) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&Instance{s: s, b: b}), b.mustCall...)
+	})
+}
+
+// Register registers the declared dare under its name, with f as the
+// solution. See Register.
+func (b *Builder) Register(info Info, f func(d *Instance) error) {
+	Register(b.name, info, func(t *testing.T, cfg *errtest.Config) {
+		b.Run(t, cfg, f)
+	})
+}
+
+// An Instance is a scenario of a dare declared with a Builder.
+type Instance struct {
+	s *errtest.Simulation
+	b *Builder
+}
+
+// A Resource is a value opened by Instance.Open.
+type Resource interface {
+	Value
+	Close() error
+	CloseWithError(err error) error
+}
+
+// Open opens the resource declared with key. It must be passed the values
+// declared with Requires.
+func (d *Instance) Open(key string, args ...Value) (Resource, error) {
+	dc := d.lookup(key, true, args)
+	opts := dc.opts
+	if !dc.closeable {
+		opts = append(opts[:len(opts):len(opts)], errtest.NoClose())
+	}
+	v, err := ve(d.s, key, opts...)
+	return &resource{v, dc.withError}, err
+}
+
+// Do performs the step declared with key. It must be passed the values
+// declared with Requires.
+func (d *Instance) Do(key string, args ...Value) error {
+	dc := d.lookup(key, false, args)
+	return e(d.s, key, dc.opts...)
+}
+
+func (d *Instance) lookup(key string, resource bool, args []Value) *decl {
+	dc, ok := d.b.decls[key]
+	if !ok || dc.resource != resource {
+		d.s.Fatalf("dare %s: undeclared key %q", d.b.name, key)
+	}
+	if len(args) != len(dc.requires) {
+		d.s.Fatalf("%q: got %d values; want %d", key, len(args), len(dc.requires))
+	}
+	for i, k := range dc.requires {
+		require(d.s, args[i], k)
+	}
+	return dc
+}
+
+type resource struct {
+	*value
+	withError bool
+}
+
+func (r *resource) Close() error {
+	if r.withError {
+		r.Simulation().Fatalf("%q must be closed with CloseWithError", r.Key())
+	}
+	return r.value.Close()
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+var storage = NewDare("Storage").
+	Resource("client", Closeable, IgnoreCloseError).
+	Resource("reader", Closeable).
+	Resource("writer", CloseWithError, NoError, Requires("client"),
+		Options(errtest.CloseOptions(errtest.NoError()))).
+	Step("copy", Requires("writer", "reader")).
+	MustCall("copy")
+
+func TestBuilder(t *testing.T) {
+	storage.Run(t, config(), func(d *Instance) (err error) {
+		c, err := d.Open("client")
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		r, err := d.Open("reader")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if errC := r.Close(); err == nil {
+				err = errC
+			}
+		}()
+
+		w, _ := d.Open("writer", c)
+		defer func() {
+			if r := recover(); r != nil {
+				w.CloseWithError(r.(error))
+				panic(r)
+			}
+			w.CloseWithError(err)
+		}()
+
+		return d.Do("copy", w, r)
+	})
+}
+
+func TestBuilderIncorrect(t *testing.T) {
+	cfg := config()
+	cfg.ExpectFailure = true
+	storage.Run(t, cfg, func(d *Instance) error {
+		c, err := d.Open("client")
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		w, _ := d.Open("writer", c)
+		defer w.Close() // must use CloseWithError
+		return nil      // does not copy
+	})
+}
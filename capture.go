@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"fmt"
+	"io"
+)
+
+// CloseAndCapture closes c and, if *err is nil, assigns the error returned by
+// Close to *err. It implements the idiom of returning the first error,
+// including that of a deferred close:
+//
+//	func copyAll(w io.Writer, name string) (err error) {
+//		f, err := os.Open(name)
+//		if err != nil {
+//			return err
+//		}
+//		defer errdare.CloseAndCapture(&err, f)
+//		_, err = io.Copy(w, f)
+//		return err
+//	}
+//
+// The function must have a named error result for err to point to. As c is
+// evaluated when the defer statement is executed, CloseAndCapture may be
+// deferred in a loop, as in the MultiReader dare. A panic of Close is not
+// recovered. Values that must be closed with the error of the function, like
+// the writer of the CloudStorage dare, need CloseWithErrorAndCapture instead.
+func CloseAndCapture(err *error, c io.Closer) {
+	if errC := c.Close(); *err == nil {
+		*err = errC
+	}
+}
+
+// CloseWithErrorAndCapture closes c with *err and, if *err is nil, assigns
+// the error returned by CloseWithError to *err. If the function deferring it
+// is panicking, c is instead closed with the panic, as an error, and the
+// panic is passed on unchanged:
+//
+//	w := t.NewWriter(c)
+//	defer errdare.CloseWithErrorAndCapture(&err, w)
+//
+// It must be deferred directly, not called from a deferred function literal,
+// to be able to recover the panic.
+func CloseWithErrorAndCapture(err *error, c interface{ CloseWithError(error) error }) {
+	if r := recover(); r != nil {
+		errP, ok := r.(error)
+		if !ok {
+			errP = fmt.Errorf("panic: %v", r)
+		}
+		c.CloseWithError(errP)
+		panic(r)
+	}
+	if errC := c.CloseWithError(*err); *err == nil {
+		*err = errC
+	}
+}
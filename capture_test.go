@@ -0,0 +1,48 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// simCloser closes the value opened for key in s.
+type simCloser struct {
+	s   *errtest.Simulation
+	key string
+}
+
+func (c simCloser) Close() error                   { return c.s.Close(c.key) }
+func (c simCloser) CloseWithError(err error) error { return c.s.CloseWithError(c.key, err) }
+
+func TestCloseAndCapture(t *testing.T) {
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) (err error) {
+		for _, key := range []string{"a", "b", "c"} {
+			if err := s.Open(key); err != nil {
+				return err
+			}
+			defer CloseAndCapture(&err, simCloser{s, key})
+		}
+		return s.Open("write", errtest.NoClose())
+	})
+}
+
+func TestCloseWithErrorAndCapture(t *testing.T) {
+	for _, cfg := range []*errtest.Config{nil, {ForbidRecover: true}} {
+		errtest.RunReport(t, cfg, func(s *errtest.Simulation) (err error) {
+			if err := s.Open("reader"); err != nil {
+				return err
+			}
+			defer CloseAndCapture(&err, simCloser{s, "reader"})
+			if err := s.Open("writer"); err != nil {
+				return err
+			}
+			defer CloseWithErrorAndCapture(&err, simCloser{s, "writer"})
+			return s.Open("copy", errtest.NoClose())
+		})
+	}
+}
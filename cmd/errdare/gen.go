@@ -0,0 +1,410 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// genUsage documents the gen subcommand.
+const genUsage = `usage: errdare gen [flags] file.go
+
+Gen generates a fake implementation, backed by an errtest.Simulation, for
+each interface declared in file.go, or those selected with -type, and for the
+interfaces returned by their methods. Each method call opens a step keyed
+by the key of the fake followed by a period and the method name. Methods may
+be called more than once, as each call is opened with errtest.Iterate.
+
+	- Close and CloseWithError close the fake.
+	- A method returning an interface declared in file.go, optionally with an
+	  error, opens a value that must be closed if that interface has a Close
+	  method, and returns a fake for it. The fake is keyed by the iteration
+	  of the call, such as client.Bucket#1 for the second call.
+	- A method returning an error may return an error or panic.
+	- Any other method may panic.
+
+The fake for an interface I is named SimI and created with NewSimI.
+`
+
+// genMain runs the gen subcommand with the given arguments.
+func genMain(args []string) error {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	out := fs.String("o", "", "output file; the generated code is written to standard output if empty")
+	names := fs.String("type", "", "comma-separated interfaces to generate fakes for; all interfaces if empty")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, genUsage)
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+	}
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	var ifaces []string
+	if *names != "" {
+		ifaces = strings.Split(*names, ",")
+	}
+	b, err := gen(fs.Arg(0), src, ifaces)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(*out, b, 0666)
+}
+
+// stdInterfaces holds the definitions of interfaces that may be embedded in
+// the interfaces passed to gen.
+const stdInterfaces = `package io
+
+type Closer interface { Close() error }
+type Reader interface { Read(p []byte) (n int, err error) }
+type Writer interface { Write(p []byte) (n int, err error) }
+type ReadCloser interface { Reader; Closer }
+type WriteCloser interface { Writer; Closer }
+type ReadWriter interface { Reader; Writer }
+type ReadWriteCloser interface { Reader; Writer; Closer }
+`
+
+// A generator generates fakes for the interfaces of a single file.
+type generator struct {
+	fset    *token.FileSet
+	file    *ast.File
+	ifaces  map[string]*ast.InterfaceType // interfaces declared in file
+	std     map[string]*ast.InterfaceType // interfaces declared in stdInterfaces
+	imports map[string]string             // package name to import path
+	used    map[string]bool               // imported packages used by the fakes
+	queue   []string                      // interfaces to generate fakes for
+	queued  map[string]bool
+	buf     bytes.Buffer
+}
+
+// need adds the named interface to the interfaces to generate fakes for.
+func (g *generator) need(name string) {
+	if !g.queued[name] {
+		g.queued[name] = true
+		g.queue = append(g.queue, name)
+	}
+}
+
+// gen returns the source of fakes for the named interfaces declared in src,
+// or all interfaces if names is empty, and for the interfaces returned by
+// their methods.
+func gen(filename string, src []byte, names []string) ([]byte, error) {
+	g := &generator{
+		fset:    token.NewFileSet(),
+		ifaces:  map[string]*ast.InterfaceType{},
+		std:     map[string]*ast.InterfaceType{},
+		imports: map[string]string{},
+		used:    map[string]bool{},
+		queued:  map[string]bool{},
+	}
+	var err error
+	if g.file, err = parser.ParseFile(g.fset, filename, src, 0); err != nil {
+		return nil, err
+	}
+	io, err := parser.ParseFile(g.fset, "io.go", stdInterfaces, 0)
+	if err != nil {
+		panic(err)
+	}
+	collectInterfaces(io, g.std)
+	all := collectInterfaces(g.file, g.ifaces)
+	for _, imp := range g.file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		name := path.Base(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		g.imports[name] = p
+	}
+
+	if len(names) == 0 {
+		names = all
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: no interfaces found", filename)
+	}
+	for _, name := range names {
+		if g.ifaces[name] == nil {
+			return nil, fmt.Errorf("%s: interface %s not found", filename, name)
+		}
+		g.need(name)
+	}
+	// Generating a fake may require fakes for the interfaces it returns.
+	for i := 0; i < len(g.queue); i++ {
+		if err := g.genFake(g.queue[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by errdare gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", g.file.Name.Name)
+	var std, other []string
+	for name := range g.used {
+		p := g.imports[name]
+		if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			other = append(other, importSpec(name, p))
+		} else {
+			std = append(std, importSpec(name, p))
+		}
+	}
+	other = append(other, `"github.com/mpvl/errdare/errtest"`)
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, p := range std {
+		fmt.Fprintf(&b, "\t%s\n", p)
+	}
+	if len(std) > 0 {
+		fmt.Fprintf(&b, "\n")
+	}
+	for _, p := range other {
+		fmt.Fprintf(&b, "\t%s\n", p)
+	}
+	fmt.Fprintf(&b, ")\n")
+	b.Write(g.buf.Bytes())
+	return format.Source(b.Bytes())
+}
+
+func importSpec(name, p string) string {
+	if path.Base(p) == name {
+		return strconv.Quote(p)
+	}
+	return name + " " + strconv.Quote(p)
+}
+
+// collectInterfaces adds the interfaces declared in f to m and returns their
+// names in order of declaration.
+func collectInterfaces(f *ast.File, m map[string]*ast.InterfaceType) (names []string) {
+	for _, d := range f.Decls {
+		d, ok := d.(*ast.GenDecl)
+		if !ok || d.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range d.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if it, ok := spec.Type.(*ast.InterfaceType); ok && spec.TypeParams == nil {
+				m[spec.Name.Name] = it
+				names = append(names, spec.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// A method is a method of an interface.
+type method struct {
+	name string
+	typ  *ast.FuncType
+}
+
+// methods returns the methods of it, including those of embedded interfaces,
+// sorted by name. Embedded interfaces must be declared in the same file or be
+// one of the interfaces of stdInterfaces.
+func (g *generator) methods(it *ast.InterfaceType, std bool) ([]method, error) {
+	var ms []method
+	for _, f := range it.Methods.List {
+		if ft, ok := f.Type.(*ast.FuncType); ok {
+			for _, n := range f.Names {
+				ms = append(ms, method{n.Name, ft})
+			}
+			continue
+		}
+		var embedded *ast.InterfaceType
+		switch x := f.Type.(type) {
+		case *ast.Ident:
+			if std {
+				embedded = g.std[x.Name]
+			} else {
+				embedded = g.ifaces[x.Name]
+			}
+		case *ast.SelectorExpr:
+			if id, ok := x.X.(*ast.Ident); ok && g.imports[id.Name] == "io" {
+				embedded, std = g.std[x.Sel.Name], true
+			}
+		}
+		if embedded == nil {
+			return nil, fmt.Errorf("%s: unsupported embedded interface %s",
+				g.fset.Position(f.Pos()), types.ExprString(f.Type))
+		}
+		sub, err := g.methods(embedded, std)
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, sub...)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].name < ms[j].name })
+	return ms, nil
+}
+
+// hasClose reports whether the named interface has a Close method.
+func (g *generator) hasClose(name string) bool {
+	ms, err := g.methods(g.ifaces[name], false)
+	if err != nil {
+		return false
+	}
+	for _, m := range ms {
+		if m.name == "Close" {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// typ returns the source of the type expression x, recording the packages
+// it uses.
+func (g *generator) typ(x ast.Expr) string {
+	ast.Inspect(x, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && g.imports[id.Name] != "" {
+				g.used[id.Name] = true
+			}
+		}
+		return true
+	})
+	return types.ExprString(x)
+}
+
+func isError(x ast.Expr) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "error"
+}
+
+func (g *generator) genFake(name string) error {
+	ms, err := g.methods(g.ifaces[name], false)
+	if err != nil {
+		return err
+	}
+	sim := "Sim" + name
+	g.printf(`
+// %[1]s is a %[2]s backed by an errtest.Simulation.
+type %[1]s struct {
+	s   *errtest.Simulation
+	key string
+}
+
+// New%[1]s returns a %[2]s that simulates the calls to its methods with s.
+// The keys of the steps of these calls are the given key followed by a period
+// and the name of the method.
+func New%[1]s(s *errtest.Simulation, key string) *%[1]s {
+	return &%[1]s{s: s, key: key}
+}
+
+// Key returns the key of f.
+func (f *%[1]s) Key() string { return f.key }
+`, sim, name)
+	for _, m := range ms {
+		g.genMethod(sim, m)
+	}
+	return nil
+}
+
+func (g *generator) genMethod(sim string, m method) {
+	var params []string
+	if m.typ.Params != nil {
+		for _, f := range m.typ.Params.List {
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				p := fmt.Sprintf("p%d", len(params))
+				params = append(params, p+" "+g.typ(f.Type))
+			}
+		}
+	}
+	var results []ast.Expr
+	if m.typ.Results != nil {
+		for _, f := range m.typ.Results.List {
+			n := len(f.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				results = append(results, f.Type)
+			}
+		}
+	}
+	var res []string
+	errIndex := -1
+	for i, r := range results {
+		name := fmt.Sprintf("r%d", i)
+		if isError(r) && i == len(results)-1 {
+			name, errIndex = "err", i
+		}
+		res = append(res, name+" "+g.typ(r))
+	}
+	g.printf("\nfunc (f *%s) %s(%s) (%s) {\n", sim, m.name, strings.Join(params, ", "), strings.Join(res, ", "))
+	defer g.printf("}\n")
+
+	returnsErr := errIndex >= 0
+	switch {
+	case m.name == "Close" && len(params) == 0 && returnsErr && len(results) == 1:
+		g.printf("\treturn f.s.Close(f.key)\n")
+		return
+	case m.name == "CloseWithError" && len(params) == 1 && isError(m.typ.Params.List[0].Type) &&
+		returnsErr && len(results) == 1:
+		g.printf("\treturn f.s.CloseWithError(f.key, p0)\n")
+		return
+	}
+
+	key := fmt.Sprintf("f.key + %q", "."+m.name)
+	// A method returning a generated interface opens a value.
+	if len(results) > 0 && len(results) <= 2 && (len(results) == 1 || returnsErr) {
+		if id, ok := results[0].(*ast.Ident); ok && g.ifaces[id.Name] != nil {
+			g.need(id.Name)
+			opts := []string{"errtest.Iterate()", "errtest.StoreKey(&key)"}
+			if !g.hasClose(id.Name) {
+				opts = append(opts, "errtest.NoClose()")
+			}
+			if !returnsErr {
+				opts = append(opts, "errtest.NoError()")
+			}
+			g.printf("\tkey := %s\n", key)
+			open := "f.s.Open(" + strings.Join(append([]string{"key"}, opts...), ", ") + ")"
+			if returnsErr {
+				g.printf("\terr = %s\n", open)
+			} else {
+				g.printf("\t%s\n", open)
+			}
+			g.printf("\treturn NewSim%s(f.s, key)", id.Name)
+			if returnsErr {
+				g.printf(", err")
+			}
+			g.printf("\n")
+			return
+		}
+	}
+	if returnsErr {
+		g.printf("\terr = f.s.Open(%s, errtest.Iterate(), errtest.NoClose())\n", key)
+	} else {
+		g.printf("\tf.s.Open(%s, errtest.Iterate(), errtest.NoError(), errtest.NoClose())\n", key)
+	}
+	if len(results) > 0 {
+		g.printf("\treturn\n")
+	}
+}
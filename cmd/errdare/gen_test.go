@@ -0,0 +1,136 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+const storageSrc = `package storage
+
+import (
+	"context"
+	"io"
+)
+
+type Client interface {
+	io.Closer
+	Bucket(name string) Bucket
+}
+
+type Bucket interface {
+	NewReader(ctx context.Context, name string) (Reader, error)
+	NewWriter(ctx context.Context, name string) Writer
+	Delete(ctx context.Context, names ...string) error
+	Name() string
+}
+
+type Reader interface {
+	io.ReadCloser
+}
+
+type Writer interface {
+	io.Writer
+	Close() error
+	CloseWithError(err error) error
+}
+`
+
+func TestGen(t *testing.T) {
+	src, err := gen("storage.go", []byte(storageSrc), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fakes must implement the interfaces.
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, src := range map[string]string{
+		"storage.go": storageSrc,
+		"gen.go":     string(src),
+		"check.go": `package storage
+
+var (
+	_ Client = (*SimClient)(nil)
+	_ Bucket = (*SimBucket)(nil)
+	_ Reader = (*SimReader)(nil)
+	_ Writer = (*SimWriter)(nil)
+)
+`,
+	} {
+		f, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, src)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("storage", fset, files, nil); err != nil {
+		t.Fatalf("generated code does not type check: %v\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"// Code generated by errdare gen. DO NOT EDIT.",
+		`return f.s.Close(f.key)`,
+		`return f.s.CloseWithError(f.key, p0)`,
+		`f.s.Open(key, errtest.Iterate(), errtest.StoreKey(&key), errtest.NoError())`,
+		`err = f.s.Open(key, errtest.Iterate(), errtest.StoreKey(&key))`,
+		`return NewSimReader(f.s, key), err`,
+		`err = f.s.Open(f.key+".Delete", errtest.Iterate(), errtest.NoClose())`,
+		`f.s.Open(f.key+".Name", errtest.Iterate(), errtest.NoError(), errtest.NoClose())`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenReturned(t *testing.T) {
+	src, err := gen("storage.go", []byte(storageSrc), []string{"Client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"type SimClient", "type SimBucket", "type SimReader", "type SimWriter"} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenErrors(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		src   string
+		names []string
+		err   string
+	}{{
+		desc: "no interfaces",
+		src:  "package p\n\ntype T struct{}\n",
+		err:  "no interfaces found",
+	}, {
+		desc:  "unknown interface",
+		src:   "package p\n\ntype I interface{ M() }\n",
+		names: []string{"J"},
+		err:   "interface J not found",
+	}, {
+		desc: "unsupported embedding",
+		src:  "package p\n\nimport \"fmt\"\n\ntype I interface{ fmt.Stringer }\n",
+		err:  "unsupported embedded interface fmt.Stringer",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := gen("p.go", []byte(tc.src), tc.names)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("got error %v; want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command errdare runs a dare against a function in a Go package.
+//
+// Usage:
+//
+//	errdare -dare=name [flags] package function
+//	errdare gen [flags] file.go
+//
+// The function must have the signature of the solution passed to the Run
+// function of the dare. For instance, for the CloudStorage dare it must be a
+// func(t *errdare.CloudStorage) error. Scaled dares, like Pipeline, are run
+// with the size given by the -n flag. The dares are those registered by
+// package errdare. The command runs the dare as a test of the package,
+// without modifying it, and exits with a non-zero status if the solution
+// fails.
+//
+// The gen subcommand generates fakes for the interfaces declared in a file,
+// backed by an errtest.Simulation, so that dares can be written for any API.
+// Run errdare gen -help for details.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/mpvl/errdare"
+)
+
+var (
+	dare     = flag.String("dare", "", "name of the dare to run: "+strings.Join(dareNames(), ", "))
+	size     = flag.Int("n", 3, "size of scaled dares, like Pipeline")
+	pedantic = flag.Bool("pedantic", false, "use the strictest interpretation of the dare")
+	verbose  = flag.Bool("v", false, "print the result of each scenario")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: errdare -dare=name [flags] package function\n")
+	fmt.Fprintf(os.Stderr, "       errdare gen [flags] file.go\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("errdare: ")
+	if len(os.Args) > 1 && os.Args[1] == "gen" {
+		if err := genMain(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	flag.Usage = usage
+	flag.Parse()
+	d := errdare.Lookup(*dare)
+	if flag.NArg() != 2 || d == nil {
+		usage()
+	}
+	code, err := run(d, flag.Arg(0), flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(code)
+}
+
+// dareNames returns the names of the registered dares.
+func dareNames() []string {
+	var names []string
+	for _, d := range errdare.Dares() {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+// A target identifies the function to run the dare against.
+type target struct {
+	Dir        string
+	ImportPath string
+	Name       string // package name
+	Func       string
+	Dare       string
+	Size       int // size of a scaled dare, or 0
+	Pedantic   bool
+}
+
+// run runs the dare d against function fn in package pkg and returns the exit
+// code of the test.
+func run(d *errdare.Dare, pkg, fn string) (int, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}\t{{.ImportPath}}\t{{.Name}}", pkg).Output()
+	if err != nil {
+		return 0, fmt.Errorf("go list %s: %v", pkg, err)
+	}
+	f := strings.Split(strings.TrimSpace(string(out)), "\t")
+	if len(f) != 3 {
+		return 0, fmt.Errorf("go list %s: unexpected output %q", pkg, out)
+	}
+	t := target{
+		Dir:        f[0],
+		ImportPath: f[1],
+		Name:       f[2],
+		Func:       fn,
+		Dare:       d.Name,
+		Pedantic:   *pedantic,
+	}
+	if d.Matches("scaled") {
+		t.Size = *size
+	}
+	src, err := generate(t)
+	if err != nil {
+		return 0, err
+	}
+
+	// Add the generated test to the package with an overlay, so that the
+	// package itself is not modified.
+	tmp, err := os.MkdirTemp("", "errdare")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "errdare_test.go")
+	if err := os.WriteFile(file, src, 0666); err != nil {
+		return 0, err
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(t.Dir, "zz_errdare_generated_test.go"): file},
+	})
+	if err != nil {
+		return 0, err
+	}
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err := os.WriteFile(overlayFile, overlay, 0666); err != nil {
+		return 0, err
+	}
+
+	args := []string{"test", "-overlay=" + overlayFile, "-run=^TestErrdare$"}
+	if *verbose {
+		args = append(args, "-v")
+	}
+	cmd := exec.Command("go", append(args, t.ImportPath)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if e, ok := err.(*exec.ExitError); ok {
+			return e.ExitCode(), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by errdare. DO NOT EDIT.
+
+package {{.Name}}_test
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare"
+	"github.com/mpvl/errdare/errtest"
+	target {{printf "%q" .ImportPath}}
+)
+
+func TestErrdare(t *testing.T) {
+	cfg := &errtest.Config{IgnorePanicOrder: true}
+	{{- if .Pedantic}}
+	cfg = errtest.Pedantic
+	{{- end}}
+	errdare.Run{{.Dare}}(t, cfg, {{with .Size}}{{.}}, {{end}}target.{{.Func}})
+}
+`))
+
+// generate returns the source of a test that runs the dare against t.
+func generate(t target) ([]byte, error) {
+	var b bytes.Buffer
+	if err := testTemplate.Execute(&b, t); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := generate(target{
+		ImportPath: "example.com/solutions",
+		Name:       "solutions",
+		Func:       "Storage",
+		Dare:       "CloudStorage",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", src, 0); err != nil {
+		t.Fatalf("generated invalid Go: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"package solutions_test",
+		`target "example.com/solutions"`,
+		"errdare.RunCloudStorage(t, cfg, target.Storage)",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source does not contain %q:\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateScaled(t *testing.T) {
+	src, err := generate(target{
+		ImportPath: "example.com/solutions",
+		Name:       "solutions",
+		Func:       "Pipe",
+		Dare:       "Pipeline",
+		Size:       5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "errdare.RunPipeline(t, cfg, 5, target.Pipe)"; !strings.Contains(string(src), want) {
+		t.Errorf("generated source does not contain %q:\n%s", want, src)
+	}
+}
+
+func TestDareNames(t *testing.T) {
+	names := strings.Join(dareNames(), ",")
+	for _, want := range []string{"CloudStorage", "Pipeline", "Upload"} {
+		if !strings.Contains(names, want) {
+			t.Errorf("dare %s is not listed in %s", want, names)
+		}
+	}
+}
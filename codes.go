@@ -0,0 +1,141 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Code classifies the errors returned by ErrorCodes.Delete.
+type Code int
+
+// Codes of a CodeError.
+const (
+	// Unavailable indicates a transient failure: the call must be retried.
+	Unavailable Code = iota
+
+	// NotFound indicates that there was nothing to delete: the error must be
+	// ignored.
+	NotFound
+
+	// PermissionDenied indicates a permanent failure: the error must be
+	// returned.
+	PermissionDenied
+)
+
+var codeNames = []string{"unavailable", "not found", "permission denied"}
+
+func (c Code) String() string { return codeNames[c] }
+
+// A CodeError is an error carrying a Code. It is wrapped by the errors
+// returned by ErrorCodes.Delete and can be extracted with errors.As.
+type CodeError struct {
+	code      Code
+	inspected bool
+}
+
+func (e *CodeError) Error() string { return e.code.String() }
+
+// Code returns the code of the error.
+func (e *CodeError) Code() Code {
+	e.inspected = true
+	return e.code
+}
+
+// The ErrorCodes challenge: delete an object and commit the deletion. Any
+// error returned by Delete wraps a *CodeError, which must be extracted with
+// errors.As to decide how to proceed:
+//
+//   - for Unavailable, Delete must be called again, after which the solution
+//     proceeds as before;
+//   - for NotFound, the error must be ignored;
+//   - for PermissionDenied, the error must be returned.
+//
+// The code of every error returned by Delete must be inspected. Commit must
+// be called once the deletion succeeded or was found to be unnecessary.
+//
+// A simple, but incorrect, implementation is:
+//
+//	func TestErrorCodes(t *testing.T) {
+//		errdare.RunErrorCodes(t, nil, func(c *errdare.ErrorCodes) error {
+//			if err := c.Delete(); err != nil {
+//				return err // not all errors are fatal
+//			}
+//			return c.Commit()
+//		})
+//	}
+type ErrorCodes struct {
+	s       *errtest.Simulation
+	calls   int
+	pending bool // an Unavailable error was returned but not yet retried
+	issued  []*CodeError
+}
+
+func init() {
+	Register("ErrorCodes", Info{
+		Description: "delete an object, handling each error according to its code",
+		Tags:        []string{"inspect"},
+		Difficulty:  Intermediate,
+		Concepts:    []string{"errors.As", "classifying errors", "retries"},
+	}, nil)
+}
+
+// RunErrorCodes runs the ErrorCodes dare as a test. The options, if any,
+// override cfg for this dare only.
+func RunErrorCodes(t *testing.T, cfg *errtest.Config, f func(c *ErrorCodes) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		c := &ErrorCodes{s: s}
+		err := f(c)
+		for _, ce := range c.issued {
+			if !ce.inspected {
+				s.Fatalf("the code of a %q error was not inspected", ce)
+			}
+		}
+		return mustCall(s, err, "commit")
+	})
+}
+
+// Delete deletes the object. Any error it returns wraps a *CodeError. Delete
+// may only be called again to retry after an Unavailable error, which does
+// not recur.
+func (c *ErrorCodes) Delete() error {
+	prefix, codes := "delete", []Code{Unavailable, NotFound, PermissionDenied}
+	c.calls++
+	switch {
+	case c.calls == 2 && c.pending:
+		prefix, codes = "retry", codes[1:]
+	case c.calls > 1:
+		c.s.Fatalf("Delete called again without an Unavailable error")
+	}
+	c.pending = false
+	for _, code := range codes {
+		ce := &CodeError{code: code}
+		opts := []errtest.Option{errtest.NoPanic(), errtest.Wrap(ce)}
+		if code != PermissionDenied {
+			opts = append(opts, errtest.IgnoreError())
+		}
+		if err := e(c.s, prefix+"."+codeKeys[code], opts...); err != nil {
+			c.issued = append(c.issued, ce)
+			c.pending = code == Unavailable
+			return err
+		}
+	}
+	return nil
+}
+
+var codeKeys = []string{"unavailable", "notFound", "permissionDenied"}
+
+// Commit commits the deletion. It may return an error.
+func (c *ErrorCodes) Commit() error {
+	switch {
+	case c.calls == 0:
+		c.s.Fatalf("Commit called before Delete")
+	case c.pending:
+		c.s.Fatalf("Commit called without retrying Delete after an Unavailable error")
+	}
+	return e(c.s, "commit")
+}
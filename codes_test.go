@@ -0,0 +1,77 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestErrorCodes(t *testing.T) {
+	// handle returns a solution that handles the codes with the given
+	// functions, which report whether to retry and the error to return.
+	handle := func(f func(err error, ce *CodeError) (bool, error)) func(c *ErrorCodes) error {
+		return func(c *ErrorCodes) error {
+			for {
+				err := c.Delete()
+				var ce *CodeError
+				if err != nil && errors.As(err, &ce) {
+					var retry bool
+					if retry, err = f(err, ce); retry {
+						continue
+					}
+				}
+				if err != nil {
+					return err
+				}
+				return c.Commit()
+			}
+		}
+	}
+	testCases := []struct {
+		desc string
+		f    func(c *ErrorCodes) error
+	}{{
+		desc: "not inspected",
+		f: handle(func(err error, ce *CodeError) (bool, error) {
+			return false, nil
+		}),
+	}, {
+		desc: "no retry",
+		f: handle(func(err error, ce *CodeError) (bool, error) {
+			if ce.Code() == PermissionDenied {
+				return false, err
+			}
+			return false, nil
+		}),
+	}, {
+		desc: "not found returned",
+		f: handle(func(err error, ce *CodeError) (bool, error) {
+			return ce.Code() == Unavailable, err
+		}),
+	}, {
+		desc: "denied ignored",
+		f: handle(func(err error, ce *CodeError) (bool, error) {
+			return ce.Code() == Unavailable, nil
+		}),
+	}, {
+		desc: "commit skipped",
+		f: func(c *ErrorCodes) error {
+			err := c.Delete()
+			var ce *CodeError
+			if errors.As(err, &ce) && ce.Code() == PermissionDenied {
+				return err
+			}
+			return nil
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			RunErrorCodes(t, nil, tc.f, errtest.WithExpectFailure())
+		})
+	}
+}
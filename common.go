@@ -5,18 +5,55 @@
 package errdare
 
 import (
+	"fmt"
 	"io"
+	"reflect"
+	"strconv"
 
 	"github.com/mpvl/errdare/errtest"
 )
 
+// require fails the current scenario if v is not the value opened for key or
+// if it may not be used: it was closed, failed to open, or is a partial value
+// returned along with an error.
 func require(s *errtest.Simulation, v Value, key string) {
+	requireKey(s, v, key)
+	s.Use(key)
+}
+
+// requireKey fails the current scenario if v is not the value opened for key.
+func requireKey(s *errtest.Simulation, v Value, key string) {
+	if isNil(v) {
+		s.Fatalf("got nil Value; want %s", describe(s, key))
+	}
 	if v.key() != key {
-		s.Fatalf("got %q; want %q", v.key(), key)
+		s.Fatalf("got %s; want %s", describe(s, v.key()), describe(s, key))
+	}
+}
+
+// isNil reports whether v is nil or holds a nil pointer, as is the case for
+// uninitialized variables and values returned along with an error.
+func isNil(v Value) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// describe returns key quoted and followed by the description of the call
+// that produced it, if any.
+func describe(s *errtest.Simulation, key string) string {
+	if desc := s.Description(key); desc != "" {
+		return fmt.Sprintf("%q (%s)", key, desc)
 	}
+	return strconv.Quote(key)
 }
 
+// mustCall requires the steps with the given keys to be executed if the dare
+// completes without faults and returns err.
 func mustCall(s *errtest.Simulation, err error, keys ...string) error {
+	s.MustReach(keys...)
 	return err
 }
 
@@ -35,17 +72,24 @@ type Client interface {
 	io.Closer
 }
 
-// A Writer is a Value with a Close and CloseWithError method.
+// A Writer is a Value with a Write, Close, and CloseWithError method.
+//
+// Each call to Write is a step keyed by the key of the Writer followed by
+// ".write", which may fail or panic.
 type Writer interface {
 	Value
-	io.Closer
+	io.WriteCloser
 	CloseWithError(err error) error
 }
 
-// A Reader is a Value with a Close method.
+// A Reader is a Value with a Read and Close method.
+//
+// The first call to Read is a step keyed by the key of the Reader followed by
+// ".read", which may fail or panic. If it succeeds, it returns the key of the
+// Reader as its contents. Subsequent calls return io.EOF.
 type Reader interface {
 	Value
-	io.Closer
+	io.ReadCloser
 }
 
 // An Aborter is a Value with a Close and Abort method.
@@ -55,20 +99,20 @@ type Aborter interface {
 	Abort(err error)
 }
 
+// A value is a Resource that implements Client, Writer, Reader, and Aborter.
 type value struct {
-	s         *errtest.Simulation
-	keyStr    string
-	closeOpts []errtest.Option
+	*errtest.Resource[struct{}]
+	eof bool // the contents were read
 }
 
 func ve(s *errtest.Simulation, key string, opts ...errtest.Option) (*value, error) {
-	err := s.Open(key, opts...)
-	return &value{s, key, nil}, err
+	r, err := errtest.OpenResource(s, key, struct{}{}, opts...)
+	return &value{Resource: r}, err
 }
 
 func v(s *errtest.Simulation, key string, opts ...errtest.Option) *value {
-	s.Open(key, append(opts, errtest.NoError())...)
-	return &value{s, key, nil}
+	r, _ := errtest.OpenResource(s, key, struct{}{}, append(opts, errtest.NoError())...)
+	return &value{Resource: r}
 }
 
 func e(s *errtest.Simulation, key string, opts ...errtest.Option) error {
@@ -79,16 +123,26 @@ func do(s *errtest.Simulation, key string, opts ...errtest.Option) {
 	s.Open(key, append(opts, errtest.NoError(), errtest.NoClose())...)
 }
 
-func (v *value) key() string { return v.keyStr }
+func (v *value) key() string { return v.Key() }
 
-func (v *value) Close() error {
-	return v.s.Close(v.key(), v.closeOpts...)
+func (v *value) Read(p []byte) (n int, err error) {
+	if v.eof {
+		return 0, io.EOF
+	}
+	if err := v.Simulation().Op(v.Key(), "read"); err != nil {
+		return 0, err
+	}
+	v.eof = true
+	return copy(p, v.Key()), nil
 }
 
-func (v *value) CloseWithError(err error) error {
-	return v.s.CloseWithError(v.key(), err, v.closeOpts...)
+func (v *value) Write(p []byte) (n int, err error) {
+	if err := v.Simulation().Op(v.Key(), "write"); err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
 func (v *value) Abort(err error) {
-	v.s.Close(v.key(), v.closeOpts...)
+	v.Close()
 }
@@ -0,0 +1,132 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"bufio"
+	"io"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestReadWrite(t *testing.T) {
+	opts := []errtest.Option{errtest.NoError(), errtest.NoPanic(), errtest.CloseOptions(errtest.NoError(), errtest.NoPanic())}
+	testCases := []struct {
+		desc string
+		f    func(w Writer, r Reader) error
+	}{{
+		desc: "io.Copy",
+		f: func(w Writer, r Reader) error {
+			_, err := io.Copy(w, r)
+			return err
+		},
+	}, {
+		desc: "bufio",
+		f: func(w Writer, r Reader) error {
+			bw := bufio.NewWriter(w)
+			if _, err := bufio.NewReader(r).WriteTo(bw); err != nil {
+				return err
+			}
+			return bw.Flush()
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			results := errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+				w := v(s, "writer", opts...)
+				defer w.Close()
+				r := v(s, "reader", opts...)
+				defer r.Close()
+				return tc.f(w, r)
+			})
+			// The read and write may each succeed, fail, or panic, and the
+			// write is only reached if the read succeeds.
+			if got, want := len(results.Scenarios), 5; got != want {
+				t.Errorf("got %d scenarios; want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestReadContents(t *testing.T) {
+	errtest.Run(t, nil, func(s *errtest.Simulation) error {
+		r := v(s, "reader", errtest.NoClose())
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if string(b) != "reader" {
+			t.Errorf("got %q; want %q", b, "reader")
+		}
+		return nil
+	})
+}
+
+func TestRequire(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(c *CloudStorage) error
+		want string
+	}{{
+		desc: "nil interface",
+		f: func(c *CloudStorage) error {
+			c.NewWriter(nil)
+			return nil
+		},
+		want: `got nil Value; want "client"`,
+	}, {
+		desc: "nil pointer",
+		f: func(c *CloudStorage) error {
+			var r *value
+			_, err := c.Copy(nil, r)
+			return err
+		},
+		want: `got nil Value; want "reader"`,
+	}, {
+		desc: "wrong value",
+		f: func(c *CloudStorage) error {
+			r, _ := c.NewReader()
+			defer r.Close()
+			c.NewWriter(r)
+			return nil
+		},
+		want: `got "reader" (the Reader returned by NewReader); want "client"`,
+	}, {
+		desc: "described",
+		f: func(c *CloudStorage) error {
+			cl, _ := c.NewClient()
+			defer cl.Close()
+			r, _ := c.NewReader()
+			defer r.Close()
+			_, err := c.Copy(r.(Writer), cl.(Reader))
+			return err
+		},
+		want: `got "client" (the Client returned by NewClient); want "reader" (the Reader returned by NewReader)`,
+	}, {
+		desc: "closed client",
+		f: func(c *CloudStorage) error {
+			cl, _ := c.NewClient()
+			cl.Close()
+			c.NewWriter(cl)
+			return nil
+		},
+		want: `writer created from closed client: "client" (the Client returned by NewClient) used after it was closed`,
+	}}
+	opts := []errtest.Option{errtest.NoError(), errtest.NoPanic(), errtest.CloseOptions(errtest.NoError(), errtest.NoPanic())}
+	cfg := &errtest.Config{KeyOptions: map[string][]errtest.Option{
+		"client": opts, "reader": opts,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			failures := errtest.RunStandalone(cfg, func(s *errtest.Simulation) error {
+				return tc.f(&CloudStorage{s})
+			})
+			if len(failures) != 1 || failures[0].Message != tc.want {
+				t.Errorf("got %v; want one failure %q", failures, tc.want)
+			}
+		})
+	}
+}
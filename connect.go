@@ -0,0 +1,94 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// The Connect challenge: dial a connection and run a query on it. Like many
+// constructors, Dial returns a nil *Conn along with an error. Calling Close
+// on a nil *Conn panics, so Close may only be deferred once the error of Dial
+// was checked. The error of closing the Conn may be ignored.
+//
+// A simple, but incorrect, implementation is:
+//
+//	func TestConnect(t *testing.T) {
+//		errdare.RunConnect(t, nil, func(c *errdare.Connect) error {
+//			conn, err := c.Dial()
+//			defer conn.Close() // conn is nil if err is not nil
+//			if err != nil {
+//				return err
+//			}
+//			return c.Query(conn)
+//		})
+//	}
+type Connect struct {
+	s *errtest.Simulation
+}
+
+// A Conn is the connection returned by Connect.Dial.
+type Conn struct {
+	v *value
+}
+
+// errNilConn is the panic value of closing a nil *Conn.
+var errNilConn = errors.New("errdare: Close called on nil *Conn")
+
+func init() {
+	Register("Connect", Info{
+		Description: "query a connection that is nil if dialing fails",
+		Tags:        []string{"close", "nil"},
+		Difficulty:  Beginner,
+		Concepts:    []string{"defer placement", "nil values on error"},
+	}, nil)
+}
+
+// RunConnect runs the Connect dare as a test. The options, if any, override
+// cfg for this dare only.
+func RunConnect(t *testing.T, cfg *errtest.Config, f func(c *Connect) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == errNilConn {
+					s.Fatalf("%v: Close was deferred before checking the error of Dial", r)
+				}
+				panic(r)
+			}
+		}()
+		return mustCall(s, f(&Connect{s}), "query")
+	})
+}
+
+// Dial returns a connection, which must be closed. It returns a nil *Conn if
+// it returns an error.
+func (c *Connect) Dial() (*Conn, error) {
+	v, err := ve(c.s, "conn",
+		errtest.Describe("the Conn returned by Dial"),
+		errtest.CloseOptions(errtest.NoPanic(), errtest.IgnoreError()))
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{v}, nil
+}
+
+// Query runs a query on conn.
+func (c *Connect) Query(conn *Conn) error {
+	require(c.s, conn, "conn")
+	return e(c.s, "query")
+}
+
+func (c *Conn) key() string { return c.v.key() }
+
+// Close closes the connection. It panics if c is nil.
+func (c *Conn) Close() error {
+	if c == nil {
+		panic(errNilConn)
+	}
+	return c.v.Close()
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestConnect(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(c *Connect) error
+	}{{
+		desc: "deferred before check",
+		f: func(c *Connect) error {
+			conn, err := c.Dial()
+			defer conn.Close()
+			if err != nil {
+				return err
+			}
+			return c.Query(conn)
+		},
+	}, {
+		desc: "not closed",
+		f: func(c *Connect) error {
+			conn, err := c.Dial()
+			if err != nil {
+				return err
+			}
+			return c.Query(conn)
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			RunConnect(t, nil, tc.f, errtest.WithExpectFailure())
+		})
+	}
+}
@@ -4,66 +4,210 @@
 
 package errdare
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
 
 const dareOn = false
 
-func TestCloudStorage(t *testing.T) {
-	RunCloudStorage(t, dareConfig(), func(t *CloudStorage) error {
-		c, err := t.NewClient()
-		if err != nil {
+func TestDares(t *testing.T) {
+	if *gradeFile == "" {
+		RunAll(t, dareConfig(), dareFilter()...)
+		return
+	}
+	grades := GradeAll(t, dareConfig(), dareFilter()...)
+	f, err := os.Create(*gradeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := WriteGrades(f, grades); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func init() {
+	Solve("CloudStorage", func(t *testing.T, cfg *errtest.Config) {
+		RunCloudStorage(t, cfg, func(t *CloudStorage) error {
+			c, err := t.NewClient()
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			r, err := t.NewReader()
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+
+			w := t.NewWriter(c)
+			defer func() { w.CloseWithError(err) }()
+
+			_, err = t.Copy(w, r)
 			return err
-		}
-		defer c.Close()
+		})
+	})
+
+	Solve("PipeConvert", func(t *testing.T, cfg *errtest.Config) {
+		RunPipeConvert(t, cfg, func(t *PipeConvert, r Reader) error {
+			pipeReader, pipeWriter := t.Pipe()
+			go func() {
+				var err error
+				defer func() { pipeWriter.CloseWithError(err) }()
+				scanner := t.NewScanner(r)
+				for t.Scan(scanner) {
+					err = t.WriteScanned(pipeWriter, scanner)
+					if err != nil {
+						return
+					}
+				}
+				err = t.ScanErr(scanner)
+			}()
+			return t.Wait(pipeReader)
+		})
+	})
+
+	Solve("TrickyCatch", func(t *testing.T, cfg *errtest.Config) {
+		RunTrickyCatch(t, cfg, func(t *TrickyCatch) (err error) {
+			w, err := t.NewWriter()
+			if err != nil {
+				return err
+			}
+			defer func() { w.CloseWithError(err) }() // Close may return error, even if err is not
 
-		r, err := t.NewReader()
-		if err != nil {
+			ww, err := t.NewWrapper(w)
+			if err != nil {
+				return err
+			}
+			defer ww.Close() // must catch error, but may also panic.
+
+			err = t.WriteSomething(ww)
 			return err
-		}
-		defer r.Close()
+		})
+	})
 
-		w := t.NewWriter(c)
-		defer func() { w.CloseWithError(err) }()
+	Solve("Pipeline", func(t *testing.T, cfg *errtest.Config) {
+		RunPipeline(t, cfg, 3, func(p *Pipeline) (err error) {
+			w, err := p.NewSink()
+			if err != nil {
+				return err
+			}
+			defer w.CloseWithError(err) // err is evaluated too early
 
-		_, err = t.Copy(w, r)
-		return err
+			for i := 0; i < p.N(); i++ {
+				if w, err = p.NewStage(w); err != nil {
+					return err
+				}
+				defer w.CloseWithError(err)
+			}
+			return p.Write(w)
+		})
 	})
-}
 
-func TestPipeConvert(t *testing.T) {
-	RunPipeConvert(t, dareConfig(), func(t *PipeConvert, r Reader) error {
-		pipeReader, pipeWriter := t.Pipe()
-		go func() {
-			var err error
-			defer func() { pipeWriter.CloseWithError(err) }()
-			scanner := t.NewScanner(r)
-			for t.Scan(scanner) {
-				err = t.WriteScanned(pipeWriter, scanner)
+	Solve("MultiReader", func(t *testing.T, cfg *errtest.Config) {
+		RunMultiReader(t, cfg, 3, func(m *MultiReader) error {
+			var rs []Reader
+			for i := 0; i < m.N(); i++ {
+				r, err := m.NewReader()
 				if err != nil {
-					return
+					return err
 				}
+				defer r.Close() // error is ignored
+				rs = append(rs, r)
 			}
-			err = t.ScanErr(scanner)
-		}()
-		return t.Wait(pipeReader)
+			return m.Read(m.Concat(rs...))
+		})
 	})
-}
-
-func TestTrickyCatch(t *testing.T) {
-	RunTrickyCatch(t, dareConfig(), func(t *TrickyCatch) (err error) {
-		w, err := t.NewWriter()
-		if err != nil {
+	Solve("ErrorCodes", func(t *testing.T, cfg *errtest.Config) {
+		RunErrorCodes(t, cfg, func(c *ErrorCodes) error {
+			if err := c.Delete(); err != nil {
+				return err // not all errors are fatal
+			}
+			return c.Commit()
+		})
+	})
+	Solve("GracefulShutdown", func(t *testing.T, cfg *errtest.Config) {
+		RunGracefulShutdown(t, cfg, func(g *GracefulShutdown) (err error) {
+			srv := g.Start()
+			defer func() {
+				g.Shutdown(srv)
+				if errD := g.Drain(); err == nil {
+					err = errD
+				}
+			}()
+			err = g.Process(srv)
+			if errors.Is(err, context.Canceled) {
+				return nil // only benign during shutdown
+			}
 			return err
-		}
-		defer func() { w.CloseWithError(err) }() // Close may return error, even if err is not
-
-		ww, err := t.NewWrapper(w)
-		if err != nil {
+		})
+	})
+	Solve("PartialResponse", func(t *testing.T, cfg *errtest.Config) {
+		RunPartialResponse(t, cfg, func(p *PartialResponse) error {
+			resp, err := p.Send()
+			if err != nil {
+				return err // resp must still be closed
+			}
+			defer resp.Close()
+			return p.ReadAll(resp)
+		})
+	})
+	Solve("BatchUpdate", func(t *testing.T, cfg *errtest.Config) {
+		RunBatchUpdate(t, cfg, 3, func(b *BatchUpdate) (err error) {
+			tx, err := b.Begin()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if errC := tx.CloseWithError(err); err == nil {
+					err = errC
+				}
+			}()
+			for i := 0; i < b.N(); i++ {
+				if err := b.Apply(tx, i); err != nil {
+					break // err shadows the named result
+				}
+			}
 			return err
-		}
-		defer ww.Close() // must catch error, but may also panic.
-
-		err = t.WriteSomething(ww)
-		return err
+		})
+	})
+	Solve("Connect", func(t *testing.T, cfg *errtest.Config) {
+		RunConnect(t, cfg, func(c *Connect) error {
+			conn, err := c.Dial()
+			defer conn.Close() // conn is nil if err is not nil
+			if err != nil {
+				return err
+			}
+			return c.Query(conn)
+		})
+	})
+	Solve("Upload", func(t *testing.T, cfg *errtest.Config) {
+		RunUpload(t, cfg, func(u *Upload) (err error) {
+			up, err := u.Begin()
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if errC := up.CloseWithError(err); err == nil {
+					err = errC
+				}
+			}()
+			part, err := u.NewPart(up)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if errC := part.Close(); err == nil { // flushes on failure
+					err = errC
+				}
+			}()
+			return u.Write(part)
+		})
 	})
 }
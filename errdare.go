@@ -9,7 +9,10 @@
 //
 // To fastest way to get started is to open dares_test.go, set dareOn to true,
 // and fix the tests until they pass. See the errdare.go file or the godoc
-// documentation for a description of each dare.
+// documentation for a description of each dare. The -dares flag selects dares
+// by name or tag, as in
+//
+//      go test -dares=close
 package errdare
 
 import (
@@ -56,9 +59,19 @@ type CloudStorage struct {
 	s *errtest.Simulation
 }
 
-// RunCloudStorage runs the CloudStorage dare as a test.
-func RunCloudStorage(t *testing.T, cfg *errtest.Config, f func(t *CloudStorage) error) {
-	errtest.Run(t, cfg, func(s *errtest.Simulation) error {
+func init() {
+	Register("CloudStorage", Info{
+		Description: "copy from a reader to a writer created from a client",
+		Tags:        []string{"storage", "close"},
+		Difficulty:  Beginner,
+		Concepts:    []string{"deferred close", "CloseWithError"},
+	}, nil)
+}
+
+// RunCloudStorage runs the CloudStorage dare as a test. The options, if any,
+// override cfg for this dare only.
+func RunCloudStorage(t *testing.T, cfg *errtest.Config, f func(t *CloudStorage) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
 		return mustCall(s, f(&CloudStorage{s}), "copy")
 	})
 }
@@ -66,23 +79,25 @@ func RunCloudStorage(t *testing.T, cfg *errtest.Config, f func(t *CloudStorage)
 // NewClient returns a client that must be closed. The error of the close may
 // be ignored.
 func (c *CloudStorage) NewClient() (Client, error) {
-	v, err := ve(c.s, "client")
-	v.closeOpts = append(v.closeOpts, errtest.IgnoreError())
-	return v, err
+	return ve(c.s, "client",
+		errtest.Describe("the Client returned by NewClient"),
+		errtest.CloseOptions(errtest.IgnoreError()))
 }
 
 // NewReader returns a reader. The caller must call Close on the reader.
 func (c *CloudStorage) NewReader() (Reader, error) {
-	return ve(c.s, "reader")
+	return ve(c.s, "reader", errtest.Describe("the Reader returned by NewReader"))
 }
 
-// NewWriter returns a writer. The caller must call CloseWithError with a
-// non-nil value if there was any error.
+// NewWriter returns a writer created from client, which must still be open.
+// The caller must call CloseWithError with a non-nil value if there was any
+// error.
 func (c *CloudStorage) NewWriter(client Client) Writer {
-	require(c.s, client, "client")
-	v := v(c.s, "writer")
-	v.closeOpts = append(v.closeOpts, errtest.NoError())
-	return v
+	requireKey(c.s, client, "client")
+	return v(c.s, "writer",
+		errtest.Describe("the Writer returned by NewWriter"),
+		errtest.RequiresDesc("client", "writer created from closed client"),
+		errtest.CloseOptions(errtest.NoError()))
 }
 
 // Copy takes a Reader and Writer and reports any error.
@@ -119,15 +134,31 @@ func (c *CloudStorage) Copy(w Writer, r Reader) (n int, err error) {
 //  	})
 //  }
 //
+// The goroutine writing to the pipe is started by the solution, not by the
+// dare, as passing its error or panic on to the reader of the pipe is the
+// point of the challenge. It may be started with a go statement, with Go,
+// which checks its panics, or in a group returned by NewGroup, whose Wait
+// returns its error or panic.
 type PipeConvert struct {
 	s       *errtest.Simulation
 	didScan bool
 	err     chan error
 }
 
-// RunPipeConvert runs the PipeConvert dare as a test.
-func RunPipeConvert(t *testing.T, cfg *errtest.Config, f func(t *PipeConvert, r Reader) error) {
-	errtest.Run(t, cfg, func(s *errtest.Simulation) error {
+func init() {
+	Register("PipeConvert", Info{
+		Description: "copy scanned input into a pipe written from a goroutine",
+		Tags:        []string{"pipe", "goroutine"},
+		Difficulty:  Intermediate,
+		Concepts:    []string{"errors across goroutines", "panics in goroutines"},
+		Source:      "https://github.com/cmars/represent/blob/e19ef73980e42e849cd026150516a5cdb9f827bc/pkg/represent/eol.go",
+	}, nil)
+}
+
+// RunPipeConvert runs the PipeConvert dare as a test. The options, if any,
+// override cfg for this dare only.
+func RunPipeConvert(t *testing.T, cfg *errtest.Config, f func(t *PipeConvert, r Reader) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
 		tc := &PipeConvert{
 			s:   s,
 			err: make(chan error, 1),
@@ -142,9 +173,12 @@ func (p *PipeConvert) Wait(r Reader) error {
 	require(p.s, r, "pipeReader")
 	select {
 	case err := <-p.err:
+		p.s.Close("pipeReader", errtest.NoError(), errtest.NoPanic())
+		p.s.Checkpoint("wait")
 		return err
-	case <-time.After(10 * time.Millisecond):
+	case <-p.s.Clock().After(10 * time.Millisecond):
 	}
+	p.s.Checkpoint("wait")
 	return r.Close()
 }
 
@@ -168,8 +202,8 @@ func (w *pipeWriter) CloseWithError(err error) error {
 // It must be closed with CloseWithError and a non-nil error if any error
 // occurs. The Reader must be passed to Wait to await completion.
 func (p *PipeConvert) Pipe() (Reader, Writer) {
-	pr := v(p.s, "pipeReader")
-	pw := v(p.s, "pipeWriter")
+	pr := v(p.s, "pipeReader", errtest.Describe("the Reader returned by Pipe"))
+	pw := v(p.s, "pipeWriter", errtest.Describe("the Writer returned by Pipe"))
 	return pr, &pipeWriter{pw, p}
 }
 
@@ -234,23 +268,35 @@ type TrickyCatch struct {
 	s *errtest.Simulation
 }
 
-func RunTrickyCatch(t *testing.T, cfg *errtest.Config, f func(t *TrickyCatch) error) {
-	errtest.Run(t, cfg, func(s *errtest.Simulation) error {
-		return mustCall(s, f(&TrickyCatch{s}), "write")
+func init() {
+	Register("TrickyCatch", Info{
+		Description: "write to a wrapped writer, closing both with the right error",
+		Tags:        []string{"close", "panic"},
+		Difficulty:  Advanced,
+		Concepts:    []string{"errors of deferred closes", "panics in deferred functions"},
+	}, nil)
+}
+
+// RunTrickyCatch runs the TrickyCatch dare as a test. The options, if any,
+// override cfg for this dare only.
+func RunTrickyCatch(t *testing.T, cfg *errtest.Config, f func(t *TrickyCatch) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&TrickyCatch{s}), "writeSomething")
 	})
 }
 
 // NewWriter returns a Writer. It must be closed with CloseWithError and a
 // non-nil error if any error occurred.
 func (t *TrickyCatch) NewWriter() (Writer, error) {
-	return ve(t.s, "writer")
+	return ve(t.s, "writer", errtest.Describe("the Writer returned by NewWriter"))
 }
 
 // NewWrapper returns a Writer, given the Writer returned by NewWriter. It must
-// be Closed and the error returned by the close must be observed.
+// be Closed and the error returned by the close must be observed. The close
+// may also panic.
 func (t *TrickyCatch) NewWrapper(w Writer) (Writer, error) {
 	require(t.s, w, "writer")
-	return ve(t.s, "wrapper") // no error close
+	return ve(t.s, "wrapper", errtest.Describe("the Writer returned by NewWrapper"))
 }
 
 // WriteSomething writes something to the Writer returned by NewWrapper.
@@ -6,37 +6,64 @@ package errdare
 
 import (
 	"flag"
+	"strings"
+	"testing"
 
 	"github.com/mpvl/errdare/errtest"
 )
 
 var (
-	enableDare = flag.Bool("dare", dareOn,
-		"enable testsing of dares, which includes failing tests")
+	flagConfig = errtest.RegisterFlags(flag.CommandLine)
 
-	panicOrder = flag.Bool("panic_order", false,
-		"require the first panic to be passed to an error referenced in defer")
+	dare = flag.Bool("dare", false,
+		"enable testing of dares, which includes failing tests; otherwise dares are expected to fail")
 
-	closeOnPanic = flag.Bool("panic_close", false,
-		"require closes to be called in case of panic")
+	filter = flag.String("dares", "",
+		"comma-separated names, difficulties, or tags of the dares to run; all dares are run if empty")
 
-	pedantic = flag.Bool("pedantic", false,
-		"strictest interpretation; overrides all other flags except wrapping")
+	gradeFile = flag.String("errdare.json", "",
+		"write the outcome of each dare and its scenarios as JSON to this file")
 )
 
-func config() *errtest.Config {
-	if *pedantic {
-		return errtest.Pedantic
-	}
-	c := &errtest.Config{
-		RequireCloseOnPanic: *closeOnPanic,
-		IgnorePanicOrder:    !*panicOrder,
+func init() {
+	if dareOn {
+		*dare = true
 	}
+}
+
+// config returns the configuration selected by the flags for tests that are
+// expected to pass.
+func config() *errtest.Config {
+	c := flagConfig()
+	c.Short = testing.Short()
 	return c
 }
 
+func dareFilter() []string {
+	if *filter == "" {
+		return nil
+	}
+	return strings.Split(*filter, ",")
+}
+
+// dareConfig returns the configuration selected by the flags for the dares,
+// which are expected to fail unless -dare is set.
 func dareConfig() *errtest.Config {
-	c := config()
-	c.SkipErrors = !*enableDare
+	c := flagConfig()
+	c.ExpectFailure = !*dare
+	c.Short = testing.Short()
 	return c
 }
+
+func TestRunOptions(t *testing.T) {
+	cfg := config()
+	// The solution does not close the client, which must be detected even
+	// though cfg does not expect failures.
+	RunCloudStorage(t, cfg, func(t *CloudStorage) error {
+		_, err := t.NewClient()
+		return err
+	}, errtest.WithExpectFailure())
+	if cfg.ExpectFailure {
+		t.Errorf("options modified the shared config")
+	}
+}
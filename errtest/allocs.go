@@ -0,0 +1,46 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "runtime"
+
+// measureAllocs reports the average number of heap allocations of running the
+// scenario of f without faults config.MeasureAllocs times. Like
+// testing.AllocsPerRun, it runs the scenario once beforehand to warm up and
+// limits GOMAXPROCS to 1 while measuring. Features of config that allocate on
+// behalf of the engine, such as logging and tracing, are disabled.
+func measureAllocs(config *Config, f func(s *Simulation) error) float64 {
+	c := *config
+	c.GoroutineGrace = 0
+	c.CaptureStacks = false
+	c.DetectCollected = false
+	c.OnStep = nil
+	c.OnEvent = nil
+	c.Logger = nil
+	c.BaseContext = nil
+	c.Interleavings = 0
+	c.ArtifactsDir = ""
+	sim := &Simulation{config: &c}
+	runOnce := func() {
+		sim.plan = nil
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sim.runScenario(&scenarioT{}, f)
+		}()
+		<-done
+	}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	runOnce()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.Mallocs
+	for i := 0; i < c.MeasureAllocs; i++ {
+		runOnce()
+	}
+	runtime.ReadMemStats(&m)
+	return float64(m.Mallocs-before) / float64(c.MeasureAllocs)
+}
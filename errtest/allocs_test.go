@@ -0,0 +1,41 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"strings"
+	"testing"
+)
+
+var allocSink []*int
+
+func TestMeasureAllocs(t *testing.T) {
+	measure := func(extra int) *Results {
+		return run(nil, (&Config{}).With(WithMeasureAllocs(20)), func(s *Simulation) error {
+			if err := s.Open("reader", NoPanic()); err != nil {
+				return err
+			}
+			defer s.Close("reader")
+			for i := 0; i < extra; i++ {
+				allocSink = append(allocSink[:0], new(int))
+			}
+			return nil
+		})
+	}
+	base := measure(0)
+	more := measure(10)
+	if base.Allocs <= 0 {
+		t.Fatalf("Allocs: got %v; want > 0", base.Allocs)
+	}
+	if d := more.Allocs - base.Allocs; d < 10 || d > 12 {
+		t.Errorf("difference in allocations: got %v; want about 10", d)
+	}
+	if s := base.Summary(); !strings.Contains(s, "allocations per run without faults") {
+		t.Errorf("summary %q does not mention allocations", s)
+	}
+	if r := run(nil, nil, func(s *Simulation) error { return nil }); r.Allocs != 0 {
+		t.Errorf("Allocs without MeasureAllocs: got %v; want 0", r.Allocs)
+	}
+}
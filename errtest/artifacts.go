@@ -0,0 +1,177 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// An artifact is the reproduction of a failed scenario written to
+// Config.ArtifactsDir.
+type artifact struct {
+	Test     string         `json:"test,omitempty"`
+	Scenario int            `json:"scenario"`
+	Schedule int            `json:"schedule,omitempty"`
+	Faults   []string       `json:"faults"`
+	Kind     string         `json:"kind"`
+	Message  string         `json:"message"`
+	Steps    []artifactStep `json:"steps"`
+}
+
+// An artifactStep describes a step of a failed scenario in the order of
+// execution. The stacks are only included if Config.CaptureStacks is set.
+type artifactStep struct {
+	Key         string   `json:"key"`
+	Mode        string   `json:"mode"`
+	Description string   `json:"description,omitempty"`
+	Closed      bool     `json:"closed,omitempty"`
+	OpenedAt    []string `json:"openedAt,omitempty"`
+	ClosedAt    []string `json:"closedAt,omitempty"`
+
+	mode Mode // the parsed Mode, when read by readArtifact
+}
+
+// newArtifact returns the reproduction of the failed scenario sc of the test
+// with the given name, whose steps are those executed most recently.
+func (s *Simulation) newArtifact(name string, sc Scenario) *artifact {
+	a := &artifact{
+		Test:     name,
+		Scenario: sc.Index,
+		Schedule: sc.Schedule,
+		Faults:   []string{},
+		Kind:     sc.Kind.String(),
+		Message:  sc.Message,
+		Steps:    []artifactStep{},
+	}
+	for _, st := range sc.Faults() {
+		a.Faults = append(a.Faults, st.String())
+	}
+	for _, f := range s.exec {
+		a.Steps = append(a.Steps, artifactStep{
+			Key:         f.key,
+			Mode:        f.mode().String(),
+			Description: f.desc,
+			Closed:      f.closed,
+			OpenedAt:    stackLines(f.openedAt),
+			ClosedAt:    stackLines(f.closedAt),
+		})
+	}
+	return a
+}
+
+// writeArtifact writes the reproduction of the failed scenario sc to a new
+// file in the artifacts directory of the simulation and logs its path to t.
+// The directory is created in Config.ArtifactsDir, as with os.MkdirTemp,
+// when the first scenario of the simulation fails.
+func (s *Simulation) writeArtifact(t reporter, sc Scenario) {
+	if s.config == nil || s.config.ArtifactsDir == "" || s.config.ExpectFailure || s.replayed != nil {
+		return
+	}
+	name := ""
+	if t != nil {
+		name = t.Name()
+	}
+	file, err := s.artifactFile(name, sc)
+	if err == nil {
+		var b []byte
+		b, err = json.MarshalIndent(s.newArtifact(name, sc), "", "\t")
+		if err == nil {
+			err = os.WriteFile(file, append(b, '\n'), 0o644)
+		}
+	}
+	if t == nil {
+		return
+	}
+	if err != nil {
+		t.Logf("errtest: could not write reproduction of scenario %d: %v", sc.Index, err)
+		return
+	}
+	t.Logf("errtest: reproduction of scenario %d written to %s", sc.Index, file)
+}
+
+// artifactFile returns the name of the file to which the reproduction of sc
+// is written, creating the artifacts directory of the simulation if needed.
+func (s *Simulation) artifactFile(name string, sc Scenario) (string, error) {
+	if s.artifactsDir == "" {
+		if err := os.MkdirAll(s.config.ArtifactsDir, 0o755); err != nil {
+			return "", err
+		}
+		pattern := strings.NewReplacer("/", "_", "\\", "_", "*", "_").Replace(name)
+		dir, err := os.MkdirTemp(s.config.ArtifactsDir, pattern+"-")
+		if err != nil {
+			return "", err
+		}
+		s.artifactsDir = dir
+	}
+	base := fmt.Sprintf("scenario-%d.json", sc.Index)
+	if sc.Schedule > 0 {
+		base = fmt.Sprintf("scenario-%d-schedule-%d.json", sc.Index, sc.Schedule)
+	}
+	return filepath.Join(s.artifactsDir, base), nil
+}
+
+// replay runs the scenario recorded in the reproduction Config.Replay, if it
+// was written by the test of t, and returns its results.
+func (s *Simulation) replay(t reporter, f func(s *Simulation) error) *Results {
+	r := &Results{}
+	a, err := readArtifact(s.config.Replay)
+	if err != nil {
+		if t != nil {
+			t.Errorf("errtest: could not replay scenario: %v", err)
+		}
+		return r
+	}
+	name := ""
+	if t != nil {
+		name = t.Name()
+	}
+	if a.Test != name {
+		if t != nil {
+			t.Logf("errtest: no scenarios run: %s reproduces a scenario of %s", s.config.Replay, a.Test)
+		}
+		return r
+	}
+	s.scenario, s.schedule = a.Scenario, a.Schedule
+	s.replayed = map[string]Mode{}
+	for _, st := range a.Steps {
+		s.replayed[st.Key] = st.mode
+	}
+	r.Scenarios = append(r.Scenarios, runSim(t, s, f))
+	return r
+}
+
+// readArtifact reads the reproduction written to file.
+func readArtifact(file string) (*artifact, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a := &artifact{}
+	if err := json.Unmarshal(b, a); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for i, st := range a.Steps {
+		m, ok := parseMode(st.Mode)
+		if !ok {
+			return nil, fmt.Errorf("%s: step %s has unknown mode %q", file, st.Key, st.Mode)
+		}
+		a.Steps[i].mode = m
+	}
+	return a, nil
+}
+
+// parseMode returns the Mode with the given name.
+func parseMode(name string) (Mode, bool) {
+	for _, m := range []Mode{ModeNoError, ModeError, ModePanic} {
+		if m.String() == name {
+			return m, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// artifactsTB is a recordTB with a name, which identifies the test of an
+// artifact.
+type artifactsTB struct{ *recordTB }
+
+func (artifactsTB) Name() string { return "TestArtifacts/reader" }
+
+// ignoreReaderError fails in the scenario in which the reader fails.
+func ignoreReaderError(s *Simulation) error {
+	if err := s.Open("reader", NoPanic(), Describe("the reader")); err != nil {
+		return nil // error not returned
+	}
+	return s.Close("reader", NoError(), NoPanic())
+}
+
+// artifactFiles returns the files of the reproductions logged to tb.
+func artifactFiles(tb artifactsTB) (files []string) {
+	const prefix = "errtest: reproduction of scenario 1 written to "
+	for _, l := range tb.logs {
+		if strings.HasPrefix(l, prefix) {
+			files = append(files, strings.TrimPrefix(l, prefix))
+		}
+	}
+	return files
+}
+
+func TestArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	tb := artifactsTB{&recordTB{}}
+	RunReport(tb, (&Config{CaptureStacks: true}).With(WithArtifacts(dir)), ignoreReaderError)
+	files := artifactFiles(tb)
+	if len(files) != 1 {
+		t.Fatalf("got logs %q; want one reproduction of scenario 1", tb.logs)
+	}
+	if got := filepath.Base(filepath.Dir(files[0])); !strings.HasPrefix(got, "TestArtifacts_reader-") {
+		t.Errorf("directory: got %q; want prefix %q", got, "TestArtifacts_reader-")
+	}
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a artifact
+	if err := json.Unmarshal(b, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Test != "TestArtifacts/reader" || a.Scenario != 1 || a.Kind != "WrongError" {
+		t.Errorf("got test %q, scenario %d, kind %s; want TestArtifacts/reader, 1, WrongError", a.Test, a.Scenario, a.Kind)
+	}
+	if want := []string{"reader=Error"}; !reflect.DeepEqual(a.Faults, want) {
+		t.Errorf("faults: got %q; want %q", a.Faults, want)
+	}
+	if len(a.Steps) != 1 || a.Steps[0].Description != "the reader" || len(a.Steps[0].OpenedAt) == 0 {
+		t.Errorf("got steps %+v; want the reader with the stack at which it was opened", a.Steps)
+	}
+
+	dir = t.TempDir()
+	tb = artifactsTB{&recordTB{}}
+	RunReport(tb, (&Config{}).With(WithArtifacts(dir)), func(s *Simulation) error {
+		return s.Open("reader", NoPanic(), NoClose())
+	})
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("got %d entries for passing scenarios; want none", len(entries))
+	}
+}
+
+func TestArtifactsExpectFailure(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{ExpectFailure: true, ArtifactsDir: dir}
+	RunReport(artifactsTB{&recordTB{}}, config, ignoreReaderError)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("got %d entries for expected failures; want none", len(entries))
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tb := artifactsTB{&recordTB{}}
+	RunReport(tb, &Config{ArtifactsDir: t.TempDir()}, ignoreReaderError)
+	files := artifactFiles(tb)
+	if len(files) != 1 {
+		t.Fatalf("got logs %q; want one reproduction of scenario 1", tb.logs)
+	}
+
+	var steps []string
+	config := &Config{
+		Replay: files[0],
+		OnStep: func(key string, mode Mode) { steps = append(steps, key+"="+mode.String()) },
+	}
+	tb = artifactsTB{&recordTB{}}
+	r := RunReport(tb, config, ignoreReaderError)
+	if len(r.Scenarios) != 1 || r.Scenarios[0].Index != 1 || r.Scenarios[0].Kind != WrongError {
+		t.Errorf("got scenarios %+v; want scenario 1 failing with WrongError", r.Scenarios)
+	}
+	if want := []string{"reader=Error"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("got steps %q; want %q", steps, want)
+	}
+
+	// Other tests run no scenarios.
+	r = RunReport(namedTB{&recordTB{}}, config, ignoreReaderError)
+	if len(r.Scenarios) != 0 {
+		t.Errorf("got %d scenarios for another test; want none", len(r.Scenarios))
+	}
+}
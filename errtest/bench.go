@@ -0,0 +1,24 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !playground
+
+package errtest
+
+import "testing"
+
+// Benchmark runs the scenario of f in which all steps succeed b.N times. It
+// can be used to compare the overhead of different error handling approaches
+// on identical simulations. The benchmark stops at the first failure.
+func Benchmark(b *testing.B, config *Config, f func(s *Simulation) error) {
+	// Reusing the simulation replays the steps of the first run with the same
+	// successful modes.
+	sim := &Simulation{config: config}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if sc := runSim(b, sim, f); sc.Failed {
+			b.FailNow()
+		}
+	}
+}
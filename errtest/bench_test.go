@@ -0,0 +1,21 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "testing"
+
+func BenchmarkSimulation(b *testing.B) {
+	Benchmark(b, nil, func(s *Simulation) (err error) {
+		if err := s.Open("reader"); err != nil {
+			return err
+		}
+		defer func() {
+			if errC := s.Close("reader"); err == nil {
+				err = errC
+			}
+		}()
+		return s.Open("copy", NoClose())
+	})
+}
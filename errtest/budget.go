@@ -0,0 +1,83 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A Truncation describes the scenarios that were not run because
+// Config.Budget was exceeded.
+type Truncation struct {
+	// Unexplored lists the faults that were not yet simulated at the steps
+	// of the last scenario that was run. Each of them would have started one
+	// or more scenarios.
+	Unexplored []Step
+
+	// Samples is the number of scenarios not drawn with Config.Samples.
+	Samples int
+
+	// Schedules is the number of schedules not run with
+	// Config.Interleavings.
+	Schedules int
+}
+
+func (t *Truncation) String() string {
+	var parts []string
+	if len(t.Unexplored) > 0 {
+		var faults []string
+		for _, st := range t.Unexplored {
+			faults = append(faults, st.String())
+		}
+		parts = append(parts, "faults not explored: "+strings.Join(faults, ", "))
+	}
+	if t.Samples > 0 {
+		parts = append(parts, fmt.Sprintf("%d samples not run", t.Samples))
+	}
+	if t.Schedules > 0 {
+		parts = append(parts, fmt.Sprintf("%d schedules not run", t.Schedules))
+	}
+	return "budget exceeded; " + strings.Join(parts, "; ")
+}
+
+// truncate records in r that the scenarios described by t were not run.
+func (r *Results) truncate(t Truncation) {
+	if r.Truncated == nil {
+		r.Truncated = &Truncation{}
+	}
+	r.Truncated.Unexplored = append(r.Truncated.Unexplored, t.Unexplored...)
+	r.Truncated.Samples += t.Samples
+	r.Truncated.Schedules += t.Schedules
+}
+
+// overBudget reports whether no new scenarios may be started because
+// Config.Budget was exceeded.
+func (s *Simulation) overBudget() bool {
+	return !s.deadline.IsZero() && time.Now().After(s.deadline)
+}
+
+// unexplored returns the faults that remain to be simulated for the steps of
+// s.plan, which holds the next scenario to run.
+func (s *Simulation) unexplored() []Step {
+	max := s.maxFaults()
+	var steps []Step
+	for i, p := range s.plan {
+		if max > 0 && faults(s.plan[:i]) >= max {
+			break
+		}
+		next := p.modeIndex + 1
+		if i == len(s.plan)-1 {
+			next = p.modeIndex
+		}
+		for _, m := range p.modes[next:] {
+			if m != ModeNoError {
+				steps = append(steps, Step{Key: p.key, Mode: m})
+			}
+		}
+	}
+	return steps
+}
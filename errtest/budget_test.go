@@ -0,0 +1,49 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	const budget = 100 * time.Millisecond
+	count := 0
+	f := func(s *Simulation) error {
+		count++
+		if count == 2 {
+			time.Sleep(budget + budget/2)
+		}
+		for _, key := range []string{"a", "b", "c"} {
+			if err := s.Open(key, NoPanic(), NoClose()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	r := run(nil, (&Config{}).With(WithBudget(budget)), f)
+	if got := len(r.Scenarios); got != 2 {
+		t.Errorf("scenarios: got %d; want 2", got)
+	}
+	if r.Truncated == nil {
+		t.Fatal("Truncated: got nil; want the scenarios that were not run")
+	}
+	want := []Step{{"a", ModeError}, {"b", ModeError}}
+	if got := r.Truncated.Unexplored; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexplored: got %v; want %v", got, want)
+	}
+	if s := r.Summary(); !strings.Contains(s, "faults not explored: a=Error, b=Error") {
+		t.Errorf("summary %q does not report the unexplored faults", s)
+	}
+
+	count = 0
+	r = run(nil, (&Config{}).With(WithBudget(time.Hour)), f)
+	if len(r.Scenarios) != 4 || r.Truncated != nil {
+		t.Errorf("within budget: got %d scenarios, truncated %v; want 4 scenarios", len(r.Scenarios), r.Truncated)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A cachedFrame records a step of a passed scenario, including the modes
+// that were available for it, so that enumeration can continue from it.
+type cachedFrame struct {
+	Key   string `json:"key"`
+	Modes []Mode `json:"modes"`
+	Index int    `json:"index"`
+}
+
+// A scenarioCache records the scenarios of a simulation that passed, indexed
+// by a hash of their steps.
+type scenarioCache struct {
+	file   string
+	fast   bool
+	passed map[string][]cachedFrame
+
+	// index holds the passed scenarios by their steps, for lookups in fast
+	// mode.
+	index cacheNode
+}
+
+// A cacheStep is a step of a cached scenario with the mode selected for it.
+type cacheStep struct {
+	key   string
+	index int
+}
+
+// A cacheNode is a node of the prefix tree of passed scenarios. The path from
+// the root to a node holds the steps of the scenarios below it.
+type cacheNode struct {
+	children map[cacheStep]*cacheNode
+	frames   []cachedFrame // the scenario ending at this node, if any
+}
+
+// node returns the node for the given frames, creating it if create is set,
+// or nil if it does not exist.
+func (n *cacheNode) node(frames []cachedFrame, create bool) *cacheNode {
+	for _, f := range frames {
+		step := cacheStep{f.Key, f.Index}
+		next := n.children[step]
+		if next == nil {
+			if !create {
+				return nil
+			}
+			if n.children == nil {
+				n.children = map[cacheStep]*cacheNode{}
+			}
+			next = &cacheNode{}
+			n.children[step] = next
+		}
+		n = next
+	}
+	return n
+}
+
+// first returns the scenario at or below n that selects the first mode for
+// all its steps below n, preferring shorter scenarios and then steps with
+// lower keys, so that lookups do not depend on the order of insertion.
+func (n *cacheNode) first() []cachedFrame {
+	if n.frames != nil {
+		return n.frames
+	}
+	var keys []string
+	for step := range n.children {
+		if step.index == 0 {
+			keys = append(keys, step.key)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if frames := n.children[cacheStep{k, 0}].first(); frames != nil {
+			return frames
+		}
+	}
+	return nil
+}
+
+func (c *scenarioCache) add(frames []cachedFrame) {
+	c.passed[hashFrames(frames)] = frames
+	c.index.node(frames, true).frames = frames
+}
+
+func (c *scenarioCache) remove(frames []cachedFrame) {
+	delete(c.passed, hashFrames(frames))
+	if n := c.index.node(frames, false); n != nil {
+		n.frames = nil
+	}
+}
+
+// openCache loads the cache for the given schedule of the simulation f run by
+// t, or returns nil if caching is disabled. A simulation is identified by the
+// name of the test, the name of f, the identity of the configuration, the
+// schedule, and the contents of the test binary.
+func openCache(t reporter, config *Config, schedule int, f func(s *Simulation) error) *scenarioCache {
+	if config == nil || config.CacheDir == "" || config.Samples > 0 {
+		return nil
+	}
+	id := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	if t != nil {
+		id = t.Name() + "\n" + id
+	}
+	id += "\n" + config.cacheIdentity() + "\nschedule " + strconv.Itoa(schedule) + "\n" + executableHash()
+	sum := sha256.Sum256([]byte(id))
+	c := &scenarioCache{
+		file:   filepath.Join(config.CacheDir, hex.EncodeToString(sum[:])+".json"),
+		fast:   config.OnlyNew,
+		passed: map[string][]cachedFrame{},
+	}
+	if b, err := os.ReadFile(c.file); err == nil {
+		var passed map[string][]cachedFrame
+		json.Unmarshal(b, &passed)
+		for _, frames := range passed {
+			c.add(frames)
+		}
+	}
+	return c
+}
+
+// cacheIdentity returns a description of the settings of c that may affect
+// the outcome of a scenario. Functions, which cannot be compared, are only
+// identified by whether they are set, and clocks by their type.
+func (c *Config) cacheIdentity() string {
+	var keyOptions []string
+	for key, opts := range c.KeyOptions {
+		keyOptions = append(keyOptions, key+" "+optionsIdentity(opts))
+	}
+	sort.Strings(keyOptions)
+	return fmt.Sprintf("%+v", struct {
+		CacheKey                                         string
+		IgnorePanicOrder, RequireCloseOnPanic            bool
+		SkipErrors, RequireRepanic, ForbidRecover        bool
+		DetectCollected, AllowBranching, Short           bool
+		RequireChecked, LastErrorWins, AllowWrapping     bool
+		CheckMessages, Priority, SkipScenario            bool
+		MaxFaults, MaxSteps, MaxWrapDepth, Interleavings int
+		Seed                                             int64
+		GoroutineGrace                                   time.Duration
+		Clock                                            string
+		Filter, KeyOptions                               []string
+	}{
+		c.CacheKey,
+		c.IgnorePanicOrder, c.RequireCloseOnPanic,
+		c.SkipErrors, c.RequireRepanic, c.ForbidRecover,
+		c.DetectCollected, c.AllowBranching, c.Short,
+		c.RequireChecked, c.LastErrorWins, c.AllowWrapping,
+		c.CheckMessages, c.Priority != nil, c.SkipScenario != nil,
+		c.MaxFaults, c.MaxSteps, c.MaxWrapDepth, c.Interleavings,
+		c.Seed,
+		c.GoroutineGrace,
+		fmt.Sprintf("%T", c.Clock),
+		c.Filter, keyOptions,
+	})
+}
+
+// optionsIdentity returns a description of the effect of opts on a step.
+// Wrapped errors are identified by their type and message.
+func optionsIdentity(opts []Option) string {
+	if len(opts) == 0 {
+		return ""
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var requires []string
+	for _, r := range o.requires {
+		requires = append(requires, r.key+"/"+r.desc)
+	}
+	wrap := ""
+	if o.wrap != nil {
+		wrap = fmt.Sprintf("%T %v", o.wrap, o.wrap)
+	}
+	return fmt.Sprintf("%+v", struct {
+		NoClose, IgnoreError, Iterate, Partial, NotOwned, AbortFirst bool
+		NoError, NoPanic, NetErr, Timeout, Temporary                 bool
+		Desc, Wrap, CloseOpts                                        string
+		Weights                                                      map[Mode]float64
+		Requires                                                     []string
+	}{
+		o.noClose, o.ignoreError, o.iterate, o.partial, o.notOwned, o.abortFirst,
+		o.noError, o.noPanic, o.netErr, o.timeout, o.temporary,
+		o.desc, wrap, optionsIdentity(o.closeOpts),
+		o.weights,
+		requires,
+	})
+}
+
+var (
+	executableOnce sync.Once
+	executableSum  string
+)
+
+// executableHash returns a hash of the contents of the running binary, which
+// changes with any change to the code under test, or the empty string if the
+// binary cannot be read.
+func executableHash() string {
+	executableOnce.Do(func() {
+		name, err := os.Executable()
+		if err != nil {
+			return
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err == nil {
+			executableSum = hex.EncodeToString(h.Sum(nil))
+		}
+	})
+	return executableSum
+}
+
+func hashFrames(frames []cachedFrame) string {
+	h := sha256.New()
+	for _, f := range frames {
+		h.Write([]byte(f.Key + "\x00" + strconv.Itoa(f.Index) + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup returns a passed scenario that is the continuation of the given
+// planned frames. Steps not yet planned are run with the first mode.
+func (c *scenarioCache) lookup(plan []planned) ([]cachedFrame, bool) {
+	n := &c.index
+	for _, p := range plan {
+		if n = n.children[cacheStep{p.key, p.modeIndex}]; n == nil {
+			return nil, false
+		}
+	}
+	frames := n.first()
+	return frames, frames != nil
+}
+
+// runSim runs the next scenario of s, unless the cache is in fast mode and
+// the scenario is known to have passed before.
+func (c *scenarioCache) runSim(t reporter, s *Simulation, f func(s *Simulation) error) Scenario {
+	if c == nil {
+		return runSim(t, s, f)
+	}
+	if c.fast {
+		if frames, ok := c.lookup(s.plan); ok {
+			s.plan = s.plan[:0]
+			s.exec = nil
+			for _, f := range frames {
+				p := planned{key: f.Key, modes: f.Modes, modeIndex: f.Index}
+				s.plan = append(s.plan, p)
+				s.exec = append(s.exec, frame{planned: p})
+			}
+			sc := s.current()
+			sc.Cached = true
+			s.scenario++
+			return sc
+		}
+	}
+	sc := runSim(t, s, f)
+	var frames []cachedFrame
+	for _, f := range s.exec {
+		frames = append(frames, cachedFrame{Key: f.key, Modes: f.modes, Index: f.modeIndex})
+	}
+	if sc.Failed || sc.Skipped {
+		c.remove(frames)
+	} else {
+		c.add(frames)
+	}
+	return sc
+}
+
+func (c *scenarioCache) save() error {
+	if c == nil {
+		return nil
+	}
+	b, err := json.Marshal(c.passed)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0o755); err != nil {
+		return err
+	}
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+// DefaultCacheDir returns a directory suitable for Config.CacheDir, or an
+// empty string if no user cache directory is available.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil || strings.TrimSpace(dir) == "" {
+		return ""
+	}
+	return filepath.Join(dir, "errtest")
+}
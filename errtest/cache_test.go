@@ -0,0 +1,143 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	failing := true
+	f := func(s *Simulation) (err error) {
+		if err := s.Open("reader", NoPanic()); err != nil {
+			return err
+		}
+		defer func() {
+			if errC := s.Close("reader", NoPanic()); err == nil && !failing {
+				err = errC
+			}
+		}()
+		return s.Open("copy", NoPanic(), NoClose())
+	}
+	config := &Config{CacheDir: t.TempDir(), OnlyNew: true}
+
+	count := func(r *Results) (run, cached, failed int) {
+		for _, sc := range r.Scenarios {
+			switch {
+			case sc.Cached:
+				cached++
+			case sc.Failed:
+				failed++
+				run++
+			default:
+				run++
+			}
+		}
+		return
+	}
+	check := func(r *Results, wantRun, wantCached, wantFailed int) {
+		t.Helper()
+		run, cached, failed := count(r)
+		if run != wantRun || cached != wantCached || failed != wantFailed {
+			t.Errorf("got run=%d cached=%d failed=%d; want %d, %d, %d",
+				run, cached, failed, wantRun, wantCached, wantFailed)
+		}
+	}
+
+	r := run(nil, config, f)
+	check(r, 5, 0, 1)
+
+	failing = false
+	r = run(nil, config, f)
+	check(r, 1, 4, 0)
+
+	r = run(nil, config, f)
+	check(r, 0, 5, 0)
+
+	// Scenarios that passed under a weaker configuration or for another
+	// version of the code under test are run again.
+	stricter := config.With(WithRequireRepanic(true))
+	r = run(nil, stricter, f)
+	check(r, 5, 0, 0)
+	r = run(nil, config.With(WithCacheKey("dare@v2")), f)
+	check(r, 5, 0, 0)
+	r = run(nil, config, f)
+	check(r, 0, 5, 0)
+
+	config.OnlyNew = false
+	r = run(nil, config, f)
+	check(r, 5, 0, 0)
+
+	// Each schedule is cached separately.
+	interleaved := &Config{CacheDir: t.TempDir(), OnlyNew: true, Interleavings: 2}
+	r = run(nil, interleaved, f)
+	check(r, 10, 0, 0)
+	r = run(nil, interleaved, f)
+	check(r, 0, 10, 0)
+}
+
+func TestCacheIdentity(t *testing.T) {
+	base := func() *Config {
+		return &Config{KeyOptions: map[string][]Option{"reader": {NoPanic()}}}
+	}
+	configs := map[string]func(c *Config){
+		"key option":      func(c *Config) { c.KeyOptions["reader"] = []Option{NoError()} },
+		"close option":    func(c *Config) { c.KeyOptions["reader"] = []Option{NoPanic(), CloseOptions(NoError())} },
+		"wrapped error":   func(c *Config) { c.KeyOptions["reader"] = []Option{NoPanic(), Wrap(io.EOF)} },
+		"goroutine grace": func(c *Config) { c.GoroutineGrace = time.Second },
+		"clock":           func(c *Config) { c.Clock = NewFakeClock(time.Time{}) },
+	}
+	want := base().cacheIdentity()
+	if got := base().cacheIdentity(); got != want {
+		t.Errorf("identity of equal configs differs:\n%s\n%s", got, want)
+	}
+	for name, change := range configs {
+		c := base()
+		change(c)
+		if c.cacheIdentity() == want {
+			t.Errorf("%s: identity did not change", name)
+		}
+	}
+}
+
+func TestCacheLookup(t *testing.T) {
+	c := &scenarioCache{passed: map[string][]cachedFrame{}}
+	c.add([]cachedFrame{{Key: "a"}, {Key: "c"}})
+	c.add([]cachedFrame{{Key: "a"}, {Key: "b", Index: 1}})
+	c.add([]cachedFrame{{Key: "a"}, {Key: "b"}, {Key: "d"}})
+	c.add([]cachedFrame{{Key: "x", Index: 1}})
+
+	testCases := []struct {
+		plan []planned
+		want string
+	}{
+		{nil, "a b d"},
+		{[]planned{{key: "a"}}, "a b d"},
+		{[]planned{{key: "a"}, {key: "b", modeIndex: 1}}, "a b"},
+		{[]planned{{key: "a"}, {key: "c"}}, "a c"},
+		{[]planned{{key: "x", modeIndex: 1}}, "x"},
+		{[]planned{{key: "x"}}, ""},
+		{[]planned{{key: "a"}, {key: "b"}, {key: "d"}, {key: "e"}}, ""},
+	}
+	for _, tc := range testCases {
+		var got []string
+		if frames, ok := c.lookup(tc.plan); ok {
+			for _, f := range frames {
+				got = append(got, f.Key)
+			}
+		}
+		if s := strings.Join(got, " "); s != tc.want {
+			t.Errorf("lookup(%v) = %q; want %q", tc.plan, s, tc.want)
+		}
+	}
+
+	c.remove([]cachedFrame{{Key: "a"}, {Key: "b"}, {Key: "d"}})
+	if frames, _ := c.lookup(nil); len(frames) != 2 || frames[1].Key != "c" {
+		t.Errorf("lookup after remove = %v; want a c", frames)
+	}
+}
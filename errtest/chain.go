@@ -0,0 +1,118 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A chainNode describes an error and the errors it wraps, for the purpose of
+// comparing error chains.
+type chainNode struct {
+	Type    string
+	Message string
+	Wraps   []chainNode
+}
+
+// chain returns the chain of err, or nil if err is nil.
+func chain(err error) *chainNode {
+	if err == nil {
+		return nil
+	}
+	n := &chainNode{Type: fmt.Sprintf("%T", err), Message: err.Error()}
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if w := e.Unwrap(); w != nil {
+			wrapped = []error{w}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+	for _, w := range wrapped {
+		if w != nil {
+			n.Wraps = append(n.Wraps, *chain(w))
+		}
+	}
+	return n
+}
+
+// isFlat reports whether err does not wrap any other error.
+func isFlat(err error) bool {
+	n := chain(err)
+	return n == nil || len(n.Wraps) == 0
+}
+
+// chainDiff returns a diff of the chains of want and got if either of them
+// wraps other errors. Otherwise, the errors are adequately described by their
+// messages and chainDiff returns "".
+func chainDiff(got, want error) string {
+	if isFlat(got) && isFlat(want) {
+		return ""
+	}
+	return "error chains differ (-want +got):\n" + diffLines(chain(want).lines(0), chain(got).lines(0))
+}
+
+// lines returns a line for n and each of the errors it wraps, indented by
+// their depth in the chain.
+func (n *chainNode) lines(depth int) []string {
+	if n == nil {
+		return []string{"<nil>"}
+	}
+	lines := []string{fmt.Sprintf("%s%s: %q", strings.Repeat("\t", depth), n.Type, n.Message)}
+	for i := range n.Wraps {
+		lines = append(lines, n.Wraps[i].lines(depth+1)...)
+	}
+	return lines
+}
+
+// diffLines returns a line-by-line diff of want and got, in which lines only
+// in want are prefixed with "-", lines only in got with "+", and common lines
+// with a space.
+func diffLines(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of want[i:]
+	// and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	b := &strings.Builder{}
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			fmt.Fprintf(b, "  %s\n", want[i])
+			i++
+			j++
+		case j == len(got) || i < len(want) && lcs[i+1][j] >= lcs[i][j+1]:
+			fmt.Fprintf(b, "- %s\n", want[i])
+			i++
+		default:
+			fmt.Fprintf(b, "+ %s\n", got[j])
+			j++
+		}
+	}
+	return b.String()
+}
+
+// logChainDiff logs a diff of the chains of got and the error the simulation
+// must return, if their messages alone do not tell the difference. The
+// failure message itself only reports the messages, so that it fits on a
+// single line in reports.
+func (s *Simulation) logChainDiff(got error) {
+	if d := chainDiff(got, s.mustErr); d != "" {
+		s.testT.Logf("%s", d)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestChainDiff(t *testing.T) {
+	base := errors.New("base")
+	wrapped := fmt.Errorf("op: %w", base)
+	testCases := []struct {
+		desc      string
+		got, want error
+		contains  []string // empty if no diff is expected
+	}{{
+		desc: "flat",
+		got:  errors.New("a"),
+		want: errors.New("b"),
+	}, {
+		desc: "nil",
+		got:  nil,
+		want: base,
+	}, {
+		desc:     "wrapped",
+		got:      base,
+		want:     wrapped,
+		contains: []string{"error chains differ (-want +got):", "op: base", "*fmt.wrapError"},
+	}, {
+		desc:     "joined",
+		got:      errors.Join(base, errors.New("other")),
+		want:     wrapped,
+		contains: []string{"other", "*errors.joinError"},
+	}, {
+		desc: "lines",
+		got:  base,
+		want: wrapped,
+		contains: []string{
+			"- *fmt.wrapError: \"op: base\"\n" +
+				"- \t*errors.errorString: \"base\"\n" +
+				"+ *errors.errorString: \"base\"\n",
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := chainDiff(tc.got, tc.want)
+			if len(tc.contains) == 0 {
+				if got != "" {
+					t.Errorf("got diff %q; want none", got)
+				}
+				return
+			}
+			for _, s := range tc.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("diff does not contain %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLogChainDiff(t *testing.T) {
+	tb := &recordTB{}
+	RunReport(tb, nil, func(s *Simulation) error {
+		if err := s.Open("reader", NoPanic(), NoClose()); err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		return nil
+	})
+	want := "simulation did not return the correct error: got read: reader: Error; want reader: Error"
+	if len(tb.errs) != 1 || tb.errs[0] != want {
+		t.Errorf("errors: got %q; want [%q]", tb.errs, want)
+	}
+	found := false
+	for _, l := range tb.logs {
+		found = found || strings.HasPrefix(l, "error chains differ (-want +got):")
+	}
+	if !found {
+		t.Errorf("logs do not contain a diff of the error chains: %q", tb.logs)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "math/rand"
+
+// A Chooser selects the mode of a newly encountered step. It is passed the
+// weights of the modes allowed for the step and returns the index of the
+// selected mode.
+type Chooser func(weights []float64) int
+
+// RunScenario runs a single scenario of f in which choose selects the mode of
+// each step. It allows scenarios to be driven by external generators, such as
+// those of property-based testing packages. Failures are not reported, but
+// are recorded in the returned Scenario.
+func RunScenario(config *Config, choose Chooser, f func(s *Simulation) error) Scenario {
+	s := &Simulation{
+		config: config,
+		choose: choose,
+	}
+	return runSim(nil, s, f)
+}
+
+// byteChooser returns a mode selector that consumes one byte of data per
+// step.
+func byteChooser(data []byte) func(weights []float64) int {
+	return func(weights []float64) int {
+		if len(data) == 0 {
+			return 0
+		}
+		b := data[0]
+		data = data[1:]
+		return int(b) % len(weights)
+	}
+}
+
+// weightedChooser returns a mode selector that picks modes randomly in
+// proportion to their weights.
+func weightedChooser(rnd *rand.Rand) func(weights []float64) int {
+	return func(weights []float64) int {
+		total := 0.0
+		for _, w := range weights {
+			total += w
+		}
+		if total <= 0 {
+			return 0
+		}
+		x := rnd.Float64() * total
+		for i, w := range weights {
+			if x < w {
+				return i
+			}
+			x -= w
+		}
+		return len(weights) - 1
+	}
+}
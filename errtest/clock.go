@@ -0,0 +1,85 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Clock provides the time to simulations. Dares should use the Clock of a
+// Simulation for time-based coordination so that it can be replaced with a
+// deterministic implementation. See Config.Clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Clock returns the Clock of the simulation: Config.Clock, if set, or the
+// system clock otherwise.
+func (s *Simulation) Clock() Clock {
+	if s.config != nil && s.config.Clock != nil {
+		return s.config.Clock
+	}
+	return realClock{}
+}
+
+// A FakeClock is a Clock that only advances when Advance is called.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	when time.Time
+	c    chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the current time once the clock was
+// advanced by at least d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := fakeTimer{when: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t.c
+	}
+	c.timers = append(c.timers, t)
+	return t.c
+}
+
+// Advance advances the clock by d and fires all timers that expire.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	for len(c.timers) > 0 && !c.timers[0].when.After(c.now) {
+		c.timers[0].c <- c.now
+		c.timers = c.timers[1:]
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+	s := &Simulation{config: &Config{Clock: c}}
+	if s.Clock() != c {
+		t.Fatalf("Clock did not return Config.Clock")
+	}
+	short := c.After(time.Second)
+	long := c.After(time.Minute)
+
+	c.Advance(30 * time.Second)
+	select {
+	case got := <-short:
+		if want := start.Add(30 * time.Second); !got.Equal(want) {
+			t.Errorf("got %v; want %v", got, want)
+		}
+	default:
+		t.Errorf("timer did not fire")
+	}
+	select {
+	case <-long:
+		t.Errorf("timer fired early")
+	default:
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case <-long:
+	default:
+		t.Errorf("timer did not fire")
+	}
+	if got, want := c.Now(), start.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("Now: got %v; want %v", got, want)
+	}
+}
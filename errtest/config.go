@@ -0,0 +1,213 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// A ConfigOption modifies a Config.
+type ConfigOption func(c *Config)
+
+// NewConfig returns a new Config with the given options applied in order. It
+// returns an error if the resulting combination is invalid.
+func NewConfig(opts ...ConfigOption) (*Config, error) {
+	c := &Config{}
+	for _, o := range opts {
+		o(c)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// With returns a copy of c with the given options applied in order. It
+// returns c itself if there are no options. A nil c is treated as an empty
+// Config.
+func (c *Config) With(opts ...ConfigOption) *Config {
+	if len(opts) == 0 {
+		return c
+	}
+	n := &Config{}
+	if c != nil {
+		*n = *c
+	}
+	if n.KeyOptions != nil {
+		// Options may add to KeyOptions, which must not modify c.
+		m := make(map[string][]Option, len(n.KeyOptions))
+		for k, v := range n.KeyOptions {
+			m[k] = v[:len(v):len(v)]
+		}
+		n.KeyOptions = m
+	}
+	for _, o := range opts {
+		o(n)
+	}
+	return n
+}
+
+// Validate reports whether c is a valid combination of settings.
+func (c *Config) Validate() error {
+	if c.SkipErrors && c.ContinueOnFailure {
+		return errors.New("errtest: SkipErrors and ContinueOnFailure are mutually exclusive")
+	}
+	if c.ExpectFailure && c.ContinueOnFailure {
+		return errors.New("errtest: ExpectFailure and ContinueOnFailure are mutually exclusive")
+	}
+	if c.MaxSteps < 0 {
+		return errors.New("errtest: MaxSteps must not be negative")
+	}
+	if c.GoroutineGrace < 0 {
+		return errors.New("errtest: GoroutineGrace must not be negative")
+	}
+	if c.MaxFaults < 0 {
+		return errors.New("errtest: MaxFaults must not be negative")
+	}
+	if c.ProgressInterval < 0 {
+		return errors.New("errtest: ProgressInterval must not be negative")
+	}
+	return nil
+}
+
+// WithPedantic selects the strictest interpretation of all settings.
+func WithPedantic() ConfigOption {
+	return func(c *Config) {
+		c.IgnorePanicOrder = false
+		c.RequireCloseOnPanic = true
+	}
+}
+
+// WithRelaxed allows solutions to not preserve the order of panics.
+func WithRelaxed() ConfigOption {
+	return func(c *Config) { c.IgnorePanicOrder = true }
+}
+
+// WithIgnorePanicOrder sets Config.IgnorePanicOrder.
+func WithIgnorePanicOrder(ignore bool) ConfigOption {
+	return func(c *Config) { c.IgnorePanicOrder = ignore }
+}
+
+// WithRequireCloseOnPanic sets Config.RequireCloseOnPanic.
+func WithRequireCloseOnPanic(require bool) ConfigOption {
+	return func(c *Config) { c.RequireCloseOnPanic = require }
+}
+
+// WithRequireRepanic sets Config.RequireRepanic.
+func WithRequireRepanic(require bool) ConfigOption {
+	return func(c *Config) { c.RequireRepanic = require }
+}
+
+// WithForbidRecover sets Config.ForbidRecover.
+func WithForbidRecover(forbid bool) ConfigOption {
+	return func(c *Config) { c.ForbidRecover = forbid }
+}
+
+// WithSkipErrors causes failed scenarios to be skipped.
+func WithSkipErrors() ConfigOption {
+	return func(c *Config) { c.SkipErrors = true }
+}
+
+// WithContinueOnFailure causes all failures to be reported together.
+func WithContinueOnFailure() ConfigOption {
+	return func(c *Config) { c.ContinueOnFailure = true }
+}
+
+// WithExpectFailure causes a run to pass only if a scenario fails.
+func WithExpectFailure() ConfigOption {
+	return func(c *Config) { c.ExpectFailure = true }
+}
+
+// WithGoroutineGrace enables goroutine leak detection with the given grace
+// period.
+func WithGoroutineGrace(d time.Duration) ConfigOption {
+	return func(c *Config) { c.GoroutineGrace = d }
+}
+
+// WithKeyOptions adds options for the given key to Config.KeyOptions.
+func WithKeyOptions(key string, opts ...Option) ConfigOption {
+	return func(c *Config) {
+		if c.KeyOptions == nil {
+			c.KeyOptions = map[string][]Option{}
+		}
+		c.KeyOptions[key] = append(c.KeyOptions[key], opts...)
+	}
+}
+
+// WithMaxSteps limits the number of steps per scenario.
+func WithMaxSteps(n int) ConfigOption {
+	return func(c *Config) { c.MaxSteps = n }
+}
+
+// WithLastErrorWins gives later errors precedence over earlier ones.
+func WithLastErrorWins() ConfigOption {
+	return func(c *Config) { c.LastErrorWins = true }
+}
+
+// WithAllowWrapping accepts errors that wrap the expected error.
+func WithAllowWrapping() ConfigOption {
+	return func(c *Config) { c.AllowWrapping = true }
+}
+
+// WithMaxFaults sets Config.MaxFaults.
+func WithMaxFaults(n int) ConfigOption {
+	return func(c *Config) { c.MaxFaults = n }
+}
+
+// WithLogger sets Config.Logger.
+func WithLogger(l *slog.Logger) ConfigOption {
+	return func(c *Config) { c.Logger = l }
+}
+
+// WithFilter sets Config.Filter to run only the scenarios with any of the
+// given tags.
+func WithFilter(tags ...string) ConfigOption {
+	return func(c *Config) { c.Filter = tags }
+}
+
+// WithGolden sets Config.GoldenDir and Config.UpdateGolden.
+func WithGolden(dir string, update bool) ConfigOption {
+	return func(c *Config) {
+		c.GoldenDir = dir
+		c.UpdateGolden = update
+	}
+}
+
+// WithCacheKey sets Config.CacheKey.
+func WithCacheKey(key string) ConfigOption {
+	return func(c *Config) { c.CacheKey = key }
+}
+
+// WithArtifacts sets Config.ArtifactsDir.
+func WithArtifacts(dir string) ConfigOption {
+	return func(c *Config) { c.ArtifactsDir = dir }
+}
+
+// WithReplay sets Config.Replay.
+func WithReplay(file string) ConfigOption {
+	return func(c *Config) { c.Replay = file }
+}
+
+// WithBudget sets Config.Budget.
+func WithBudget(d time.Duration) ConfigOption {
+	return func(c *Config) { c.Budget = d }
+}
+
+// WithMeasureAllocs sets Config.MeasureAllocs to measure allocations over n
+// runs.
+func WithMeasureAllocs(n int) ConfigOption {
+	return func(c *Config) { c.MeasureAllocs = n }
+}
+
+// WithSamples runs n randomly chosen scenarios, using the given seed, instead
+// of enumerating all of them.
+func WithSamples(n int, seed int64) ConfigOption {
+	return func(c *Config) {
+		c.Samples = n
+		c.Seed = seed
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewConfig(t *testing.T) {
+	testCases := []struct {
+		desc string
+		opts []ConfigOption
+		want *Config
+		err  string
+	}{{
+		desc: "empty",
+		want: &Config{},
+	}, {
+		desc: "pedantic",
+		opts: []ConfigOption{WithRelaxed(), WithPedantic()},
+		want: Pedantic,
+	}, {
+		desc: "later options override earlier ones",
+		opts: []ConfigOption{WithPedantic(), WithRequireCloseOnPanic(false)},
+		want: &Config{},
+	}, {
+		desc: "skip errors",
+		opts: []ConfigOption{WithSkipErrors()},
+		want: SkipErrors,
+	}, {
+		desc: "incompatible",
+		opts: []ConfigOption{WithSkipErrors(), WithContinueOnFailure()},
+		err:  "errtest: SkipErrors and ContinueOnFailure are mutually exclusive",
+	}, {
+		desc: "negative grace",
+		opts: []ConfigOption{WithGoroutineGrace(-1)},
+		err:  "errtest: GoroutineGrace must not be negative",
+	}, {
+		desc: "samples",
+		opts: []ConfigOption{WithSamples(10, 3)},
+		want: &Config{Samples: 10, Seed: 3},
+	}, {
+		desc: "negative max faults",
+		opts: []ConfigOption{WithMaxFaults(-1)},
+		err:  "errtest: MaxFaults must not be negative",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c, err := NewConfig(tc.opts...)
+			if err != nil {
+				if err.Error() != tc.err {
+					t.Errorf("error: got %q; want %q", err, tc.err)
+				}
+				return
+			}
+			if tc.err != "" {
+				t.Errorf("got no error; want %q", tc.err)
+			}
+			if !reflect.DeepEqual(c, tc.want) {
+				t.Errorf("got %+v; want %+v", c, tc.want)
+			}
+		})
+	}
+}
+
+func TestWith(t *testing.T) {
+	base := &Config{KeyOptions: map[string][]Option{"a": {NoError()}}}
+	c := base.With(WithPedantic(), WithKeyOptions("a", NoPanic()), WithKeyOptions("b", NoPanic()))
+	if !c.RequireCloseOnPanic || len(c.KeyOptions["a"]) != 2 || len(c.KeyOptions["b"]) != 1 {
+		t.Errorf("options not applied: %+v", c)
+	}
+	if base.RequireCloseOnPanic || len(base.KeyOptions["a"]) != 1 || len(base.KeyOptions) != 1 {
+		t.Errorf("base config modified: %+v", base)
+	}
+	if got := base.With(); got != base {
+		t.Errorf("With without options returned a copy")
+	}
+	var nilConfig *Config
+	if got := nilConfig.With(WithRelaxed()); got == nil || !got.IgnorePanicOrder {
+		t.Errorf("got %+v; want IgnorePanicOrder", got)
+	}
+}
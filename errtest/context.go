@@ -0,0 +1,60 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "context"
+
+// Context returns a context for the current scenario. It is canceled once an
+// error that may not be ignored or a panic is simulated, with that fault as
+// its cause, when Cancel simulates a cancellation, when a goroutine started
+// with Go fails the scenario, and at the end of the scenario.
+func (s *Simulation) Context() context.Context {
+	defer s.lock()()
+	if s.ctx == nil {
+		parent := context.Background()
+		if s.config != nil && s.config.BaseContext != nil {
+			parent = s.config.BaseContext()
+		}
+		s.ctx, s.cancel = context.WithCancelCause(parent)
+		switch {
+		case s.stopped:
+			s.cancel(errStopped)
+		case s.mustErr != nil:
+			s.cancel(s.mustErr)
+		}
+	}
+	return s.ctx
+}
+
+// cancelContext cancels the context of the current scenario, if any, with
+// the given cause.
+func (s *Simulation) cancelContext(cause error) {
+	defer s.lock()()
+	if s.cancel != nil {
+		s.cancel(cause)
+	}
+}
+
+// Cancel executes a step with the given key that may simulate the
+// cancellation of the context returned by Context, as when a client goes away
+// while the solution is working on its behalf. The step never panics and
+// needs no close.
+//
+// If the step simulates a cancellation, the context is canceled with
+// context.Canceled as its cause and, unless an earlier fault takes
+// precedence, the scenario must return context.Canceled. Any further step
+// other than a close then fails the scenario with IgnoredCancel: a solution
+// must observe the context and stop working once it is canceled.
+func (s *Simulation) Cancel(key string, opts ...Option) {
+	defer s.step()()
+	if err := s.Open(key, append(opts, NoPanic(), NoClose(), IgnoreError())...); err == nil {
+		return
+	}
+	s.canceledBy = key
+	s.cancelContext(context.Canceled)
+	if s.mustErr == nil {
+		s.mustErr = context.Canceled
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	var got []error
+	Run(t, nil, func(s *Simulation) error {
+		ctx := s.Context()
+		err := s.Open("reader", NoPanic(), NoClose())
+		select {
+		case <-ctx.Done():
+			got = append(got, context.Cause(ctx))
+		default:
+			got = append(got, nil)
+		}
+		return err
+	})
+	if len(got) != 2 || got[0] != nil || got[1] == nil || got[1].Error() != "reader: Error" {
+		t.Errorf("got causes %v; want [<nil> reader: Error]", got)
+	}
+
+	var ctx context.Context
+	Run(t, nil, func(s *Simulation) error {
+		ctx = s.Context()
+		return nil
+	})
+	if ctx.Err() == nil {
+		t.Errorf("context not canceled at end of scenario")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config *Config
+		f      func(s *Simulation) error
+		want   []FailureKind
+	}{{
+		desc: "observed",
+		f: func(s *Simulation) error {
+			ctx := s.Context()
+			s.Cancel("client")
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return s.Open("work", NoPanic(), NoClose())
+		},
+	}, {
+		desc:   "observed and wrapped",
+		config: &Config{AllowWrapping: true},
+		f: func(s *Simulation) error {
+			s.Cancel("client")
+			if err := s.Context().Err(); err != nil {
+				return fmt.Errorf("work: %w", err)
+			}
+			return nil
+		},
+	}, {
+		desc: "continued work",
+		f: func(s *Simulation) error {
+			s.Cancel("client")
+			return s.Open("work", NoPanic(), NoClose())
+		},
+		want: []FailureKind{IgnoredCancel},
+	}, {
+		desc: "error not returned",
+		f: func(s *Simulation) error {
+			s.Cancel("client")
+			return nil
+		},
+		want: []FailureKind{WrongError},
+	}, {
+		desc: "close after cancel",
+		f: func(s *Simulation) error {
+			opts := []Option{NoError(), NoPanic(), CloseOptions(NoError(), NoPanic())}
+			if err := s.Open("conn", opts...); err != nil {
+				return err
+			}
+			defer s.Close("conn")
+			s.Cancel("client")
+			return s.Context().Err()
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []FailureKind
+			for _, f := range RunStandalone(tc.config, tc.f) {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Describe sets a human-oriented description of a step, such as "the call to
+// storage.NewReader". Diagnostics mention the description along with the key
+// of the step. See also Config.Descriptions.
+func Describe(desc string) Option {
+	return func(o *options) { o.desc = desc }
+}
+
+// Description returns the description of the step with the given key, or ""
+// if it has none or was not executed in the current scenario. Descriptions in
+// Config.Descriptions take precedence over those set with Describe.
+func (s *Simulation) Description(key string) string {
+	defer s.lock()()
+	if s.config != nil {
+		if desc := s.config.Descriptions[key]; desc != "" {
+			return desc
+		}
+	}
+	for _, f := range s.exec {
+		if f.key == key && f.desc != "" {
+			return f.desc
+		}
+	}
+	return ""
+}
+
+// quote returns key quoted and followed by its description, if any.
+func (s *Simulation) quote(key string) string {
+	desc := s.Description(key)
+	if desc == "" {
+		return strconv.Quote(key)
+	}
+	return fmt.Sprintf("%q (%s)", key, desc)
+}
@@ -5,16 +5,285 @@
 package errtest
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"testing"
+	"io"
+	"log/slog"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // A Config is used to configure a simulation.
 type Config struct {
+	// IgnorePanicOrder accepts any simulated panic or panic error, such as
+	// one created with NewPanicError, in place of the expected one, whether
+	// it is returned or propagates out of the simulation function. Panics
+	// with other values still fail with ChangedPanic unless they wrap the
+	// expected panic.
 	IgnorePanicOrder    bool
 	RequireCloseOnPanic bool
 
 	SkipErrors bool // call Skip on testing.T for any error it encounters.
+
+	// RequireRepanic requires simulated panics to propagate out of the
+	// simulation function. A solution may recover a panic to run cleanups,
+	// for instance to pass it to CloseWithError, but must then panic again
+	// instead of returning it as an error. Panics reaching a goroutine
+	// started with Simulation.Go count as propagated.
+	RequireRepanic bool
+
+	// ForbidRecover requires simulated panics to propagate untouched out of
+	// the simulation function, as is expected of library code that must not
+	// swallow panics: deferred functions may run, but the last simulated
+	// panic must leave the simulation function as the value with which it was
+	// raised. It applies to panics of steps executed by the goroutine running
+	// the simulation function. A panic that is recovered and raised again
+	// with the same value cannot be told apart from one that propagated, and
+	// is accepted.
+	ForbidRecover bool
+
+	// ContinueOnFailure runs all scenarios without reporting failures
+	// individually. Instead, all failed scenarios are reported together once
+	// all scenarios have run.
+	ContinueOnFailure bool
+
+	// ExpectFailure inverts the outcome of Run: it fails only if none of the
+	// scenarios fail. See ExpectFailure.
+	ExpectFailure bool
+
+	// GoroutineGrace, if positive, enables the detection of leaked
+	// goroutines. A scenario fails if goroutines it started, directly or
+	// through other goroutines, are still running after this grace period.
+	// Goroutines of other tests are not considered. Neither are goroutines
+	// started by a goroutine that finished before the end of the scenario.
+	GoroutineGrace time.Duration
+
+	// Clock, if not nil, is the Clock returned by Simulation.Clock. Setting
+	// it to a FakeClock makes time-based coordination in dares
+	// deterministic.
+	Clock Clock
+
+	// CaptureStacks records the stack of each Open and Close and includes
+	// the relevant locations in diagnostics.
+	CaptureStacks bool
+
+	// DetectCollected enables the detection of values registered with
+	// Simulation.Track that were opened, were not closed, and are unreachable
+	// at the end of a scenario. Unlike RequireCloseOnPanic, it allows values
+	// that are still referenced, for instance by the caller, to be closed
+	// later. Reachability is determined by running garbage collections,
+	// for up to a second, until no more tracked values are collected, so
+	// values may be missed if the garbage collector is slow to finalize them.
+	DetectCollected bool
+
+	// Descriptions holds human-oriented descriptions of keys, such as "the
+	// call to storage.NewReader", which are mentioned in diagnostics along
+	// with the keys. See also Describe.
+	Descriptions map[string]string
+
+	// AllowBranching allows a simulation to execute different steps when
+	// replaying the steps of an earlier scenario, for instance because it
+	// retries or falls back based on state outside the simulation. Instead of
+	// failing with a non-deterministic simulation, enumeration continues
+	// along the new branch, and the scenario that was planned instead is run
+	// once the new branch is exhausted. Each diverging plan is set aside at
+	// most once, so a branch that is never taken again is not retried
+	// indefinitely.
+	AllowBranching bool
+
+	// KeyOptions holds options for specific keys. They are applied after the
+	// options passed to Open or Close. Options for closes are keyed by the
+	// key of the closed value followed by ".close".
+	KeyOptions map[string][]Option
+
+	// Samples, if positive, runs the given number of randomly chosen
+	// scenarios instead of enumerating all of them. Modes are chosen
+	// according to the Weights option of each step, using a random source
+	// seeded with Seed.
+	Samples int
+	Seed    int64
+
+	// ProgressInterval, if positive, reports the number of scenarios run so
+	// far at most once per interval, so that long enumerations can be told
+	// apart from hung ones. Progress is passed to OnProgress, if not nil, or
+	// logged otherwise. The total number of scenarios passed to OnProgress is
+	// 0 if it is not known in advance.
+	ProgressInterval time.Duration
+	OnProgress       func(done, total int)
+
+	// ShowHints logs a hint when a simulation fails, based on the kind of its
+	// first failure. Each failure of the same kind reveals the next hint, so
+	// hints get progressively more revealing. It is intended for workshops
+	// and other educational use.
+	ShowHints bool
+
+	// Hints, if not nil, replaces the hints of DefaultHints for the kinds of
+	// failures it contains.
+	Hints map[FailureKind][]string
+
+	// Short limits the enumeration to the scenario without faults and the
+	// scenarios with a single fault, skipping combinations of faults. It is
+	// typically set to testing.Short().
+	Short bool
+
+	// MaxFaults, if positive, limits the number of faults simulated in a
+	// single scenario. It overrides the limit of one fault set by Short, so
+	// that Short and MaxFaults of 2 also run the scenarios in which, for
+	// instance, both a body operation and a later close fail. By default,
+	// all combinations of faults are run.
+	MaxFaults int
+
+	// MaxSteps, if positive, limits the number of steps that may be executed
+	// in a single scenario. This guards against solutions that loop forever.
+	MaxSteps int
+
+	// RequireChecked requires that every simulated error that may not be
+	// ignored is either returned, passed to CloseWithError, or passed to
+	// Simulation.Checked. This catches solutions that discard an error but
+	// return the correct error by coincidence.
+	RequireChecked bool
+
+	// By default, the first error encountered in a scenario must be returned,
+	// unless it is followed by a panic. If LastErrorWins is set, each error
+	// takes precedence over earlier errors instead. Panics always take
+	// precedence over errors.
+	LastErrorWins bool
+
+	// Priority, if not nil, determines which fault must be returned by a
+	// scenario. It overrides LastErrorWins.
+	Priority Priority
+
+	// AllowWrapping accepts errors that wrap the expected error, as reported
+	// by errors.Is, instead of only the expected error itself.
+	AllowWrapping bool
+
+	// MaxWrapDepth, if positive, limits the number of times the returned
+	// error may wrap the expected error. It only applies with AllowWrapping.
+	MaxWrapDepth int
+
+	// CheckMessages requires each layer wrapping the expected error to add
+	// context to its message that differs from that added by the layer it
+	// wraps, as in "open config: read: EOF" rather than "read: read: EOF",
+	// or a layer that adds nothing at all. It only applies with
+	// AllowWrapping.
+	CheckMessages bool
+
+	// OnStep, if not nil, is called for each executed step with the mode
+	// simulated for it.
+	OnStep func(key string, mode Mode)
+
+	// Logger, if not nil, receives a structured record of every simulation
+	// event: the start and end of each scenario and each failure at
+	// slog.LevelInfo, and each executed step, including closes, at
+	// slog.LevelDebug. All records have a "scenario" attribute holding the
+	// index of the scenario.
+	Logger *slog.Logger
+
+	// BaseContext, if not nil, returns the parent of the context returned by
+	// Simulation.Context for the current scenario. It allows observers, such
+	// as the tracer of package oteltest, to pass values to the solution.
+	BaseContext func() context.Context
+
+	// OnScenarioEnd, if not nil, is called with the outcome of each scenario.
+	OnScenarioEnd func(sc Scenario)
+
+	// OnEvent, if not nil, is called for each simulation event: the start
+	// and end of each scenario, each executed step, and each failure. This
+	// allows live visualizations and other tools to follow a run as it
+	// progresses. OnEvent is called synchronously from the goroutine that
+	// caused the event and must not call methods of the Simulation.
+	OnEvent func(e Event)
+
+	// Interleavings, if positive, runs the goroutines started with
+	// Simulation.Go under a scheduler that lets a single goroutine run at a
+	// time and chooses which one proceeds at each simulation step, so that
+	// their interleaving is reproducible. All scenarios are run once for
+	// each of the given number of schedules. Schedule 0 favors the goroutine
+	// started first; later schedules choose randomly, seeded with Seed and
+	// the schedule number. A goroutine that blocks before reaching its next
+	// step, for instance because it waits for another goroutine, lets the
+	// others proceed. Schedules are reproducible as long as such goroutines
+	// are started with Simulation.Go.
+	Interleavings int
+
+	// Filter, if not empty, restricts the scenarios that are reported to
+	// those with any of the given tags, such as "panic" or "close-error".
+	// Other scenarios are still run, as their faults are only known once
+	// they complete, but are skipped. See Scenario.Tags.
+	Filter []string
+
+	// SkipScenario, if not nil, is called for a scenario before it reports a
+	// failure and once it completes. If it returns true, the scenario is
+	// skipped instead. The scenario passed holds the steps executed so far.
+	SkipScenario func(sc Scenario) bool
+
+	// CacheDir, if not empty, is the directory in which the scenarios that
+	// passed are recorded across runs. If OnlyNew is also set, scenarios
+	// that passed before are not run again, so that only previously failed
+	// and new scenarios are run. See DefaultCacheDir. Recorded scenarios are
+	// keyed by the test, the settings of the Config that affect outcomes,
+	// the contents of the test binary, and CacheKey, so that scenarios that
+	// passed under a weaker configuration or an older version of the code
+	// under test are run again.
+	CacheDir string
+	OnlyNew  bool
+
+	// CacheKey, if not empty, further identifies the code under test in the
+	// cache, such as the ID of a dare, which includes its version.
+	CacheKey string
+
+	// GoldenDir, if not empty, is the directory holding golden files that
+	// record the outcome of each scenario of a test, keyed by its steps. If
+	// UpdateGolden is set, Run rewrites the golden file of the test, unless
+	// the enumeration was truncated; otherwise it reports an error if any
+	// outcome differs from the recorded one. This allows authors of error
+	// handling packages to detect behavioral changes of their helpers across
+	// releases.
+	GoldenDir    string
+	UpdateGolden bool
+
+	// ArtifactsDir, if not empty, is a directory in which a reproduction of
+	// each failed scenario is written as JSON: its faults, the failure, and
+	// the executed steps, with the stacks at which they were opened and
+	// closed if CaptureStacks is set. The files of a simulation are written
+	// to a new directory in ArtifactsDir named after the test, and their
+	// paths are logged, so that they can be attached to bug reports. No
+	// reproductions are written with ExpectFailure, whose failures are
+	// intended.
+	ArtifactsDir string
+
+	// Replay, if not empty, is the path of a reproduction written to
+	// ArtifactsDir. Run then runs only the scenario recorded in it, with the
+	// same faults and schedule, so that a failure can be debugged in
+	// isolation. Simulations of tests other than the one that wrote the
+	// reproduction run no scenarios.
+	Replay string
+
+	// Coverage, if not nil, is called after each scenario to correlate
+	// scenarios with code coverage. It should report the fraction of
+	// statements covered so far, as testing.Coverage does when tests are run
+	// with -cover. The coverage gained by each scenario is recorded in
+	// Scenario.CoverageGain.
+	Coverage func() float64
+
+	// MeasureAllocs, if positive, is the number of times the scenario
+	// without faults is run again after all scenarios have run to measure the
+	// average number of heap allocations per run, which is recorded in
+	// Results.Allocs.
+	MeasureAllocs int
+
+	// Budget, if positive, limits the wall-clock time spent running the
+	// scenarios of a simulation. Once it is exceeded, no new scenarios are
+	// started and Results.Truncated reports which faults were not explored,
+	// so that large simulations degrade to partial coverage instead of
+	// timing out. A scenario that is running is not interrupted.
+	Budget time.Duration
 }
 
 // These Config values are some common values
@@ -35,25 +304,33 @@ var (
 // Another case: error friendly, like EOF, but panic may override this.
 // in general: override friendly error with mean error.
 
-type mode int
+// A Mode describes the outcome simulated for a single step.
+type Mode int
 
 const (
-	modeNoError mode = iota
-	modeError
-	modePanic
+	ModeNoError Mode = iota // the step succeeds
+	ModeError               // the step returns an error
+	ModePanic               // the step panics
 )
 
-func (m mode) String() string {
-	return map[mode]string{
-		modeNoError: "NoError",
-		modePanic:   "Panic",
-		modeError:   "Error",
+func (m Mode) String() string {
+	return map[Mode]string{
+		ModeNoError: "NoError",
+		ModePanic:   "Panic",
+		ModeError:   "Error",
 	}[m]
 }
 
 type simError struct {
-	mode mode
+	mode Mode
 	key  string
+
+	// state, if not nil, tracks whether the error was inspected.
+	state *errState
+}
+
+type errState struct {
+	checked bool
 }
 
 type fatalError struct {
@@ -62,9 +339,42 @@ type fatalError struct {
 
 func (e simError) Error() string { return fmt.Sprintf("%s: %s", e.key, e.mode) }
 
+func (e simError) step() Step { return Step{Key: e.key, Mode: e.mode} }
+
+func (e simError) sim() simError { return e }
+
+// asSimError reports the simulated error underlying err, if any.
+func asSimError(err error) (simError, bool) {
+	var e interface{ sim() simError }
+	if errors.As(err, &e) {
+		return e.sim(), true
+	}
+	return simError{}, false
+}
+
+// A wrappedError is a simulated error that wraps another error.
+// See the Wrap option.
+type wrappedError struct {
+	simError
+	err error
+}
+
+func (e *wrappedError) Unwrap() error { return e.err }
+
+// A netError is a simulated error that implements net.Error.
+// See the NetError option.
+type netError struct {
+	wrappedError
+	timeout   bool
+	temporary bool
+}
+
+func (e *netError) Timeout() bool   { return e.timeout }
+func (e *netError) Temporary() bool { return e.temporary }
+
 // NewPanicError returns a new error that is identifiable as a panic error.
 func NewPanicError(msg string) error {
-	return simError{mode: modePanic, key: msg}
+	return simError{mode: ModePanic, key: msg}
 }
 
 // An Option configures a simulation.
@@ -75,12 +385,53 @@ type options struct {
 
 	noError bool
 	noPanic bool
+
+	// weights holds the relative probabilities of each mode when modes are
+	// chosen randomly. The default weight of a mode is 1.
+	weights map[Mode]float64
+
+	// wrap, netErr, timeout, and temporary determine the type of the
+	// simulated error.
+	wrap      error
+	netErr    bool
+	timeout   bool
+	temporary bool
+
+	// requires holds the values that must be open when the step is
+	// executed.
+	requires []requirement
+
+	// storeKey, if not nil, receives the key with which the step is
+	// recorded.
+	storeKey *string
+}
+
+// newError returns e as the type of error selected by the options.
+func (o *options) newError(e simError) error {
+	switch {
+	case o.netErr:
+		return &netError{wrappedError{e, o.wrap}, o.timeout, o.temporary}
+	case o.wrap != nil:
+		return &wrappedError{e, o.wrap}
+	}
+	return e
 }
 
 func NoClose() Option {
 	return func(o *options) { o.noClose = true }
 }
 
+// MustNotClose declares a value that is not owned by the simulation function,
+// such as an http.ResponseWriter or a connection borrowed from a pool. Like
+// NoClose, the value need not be closed, but closing it fails the scenario
+// with NotOwned.
+func MustNotClose() Option {
+	return func(o *options) {
+		o.noClose = true
+		o.notOwned = true
+	}
+}
+
 func NoError() Option {
 	return func(o *options) { o.noError = true }
 }
@@ -93,30 +444,244 @@ func IgnoreError() Option {
 	return func(o *options) { o.ignoreError = true }
 }
 
+// Iterate allows a key to be opened more than once in a scenario, as is
+// needed for statements in a loop. Each subsequent opening of the key is
+// recorded as key#n, where n is the number of earlier openings. Closing an
+// iterated key closes its most recently opened iteration that is still open.
+func Iterate() Option {
+	return func(o *options) { o.iterate = true }
+}
+
+// AbortFirst declares a value, such as a transaction or an upload, that is
+// aborted by closing it with an error. On success, values opened after it
+// must be closed before it, as usual, so that they are, for instance, flushed
+// before it is committed. Once a simulated fault must be returned, however, it
+// must be aborted before the values opened after it are closed, so that
+// closing them does not flush incomplete data into it.
+func AbortFirst() Option {
+	return func(o *options) { o.abortFirst = true }
+}
+
+// Partial makes a step that fails with an error return a partial value along
+// with it, as some APIs do. Such a value must still be closed, but must not
+// otherwise be used. See Simulation.Use.
+func Partial() Option {
+	return func(o *options) { o.partial = true }
+}
+
+// Requires declares that the step uses the values opened for the given keys,
+// such as a writer created from a client. Executing the step fails the
+// scenario with UseAfterClose if any of these values was closed or failed to
+// open, as if Simulation.Use were called for each of them.
+func Requires(keys ...string) Option {
+	return func(o *options) {
+		for _, k := range keys {
+			o.requires = append(o.requires, requirement{key: k})
+		}
+	}
+}
+
+// StoreKey stores in *p the key with which the step is recorded. It differs
+// from the key passed to Open for iterated steps, so that fakes of values
+// opened in a loop can be keyed, and closed, by the iteration they belong to.
+func StoreKey(p *string) Option {
+	return func(o *options) { o.storeKey = p }
+}
+
+// RequiresDesc is like Requires for a single key, but describes the
+// dependency in the failure reported for a value that may not be used, as in
+// "writer created from closed client".
+func RequiresDesc(key, desc string) Option {
+	return func(o *options) { o.requires = append(o.requires, requirement{key, desc}) }
+}
+
+// A requirement is a value that must be open when a step is executed. desc,
+// if not empty, describes the dependency.
+type requirement struct {
+	key  string
+	desc string
+}
+
+// CloseOptions sets options that apply to each close of the opened value, in
+// addition to those passed to Close or CloseWithError. For instance,
+// CloseOptions(NoError()) declares a value whose close may only succeed or
+// panic, while CloseOptions(NoPanic(), IgnoreError()) declares a value whose
+// close may return an error that may be ignored.
+func CloseOptions(opts ...Option) Option {
+	return func(o *options) { o.closeOpts = append(o.closeOpts, opts...) }
+}
+
+// Wrap makes the simulated error of a step wrap err, so that errors.Is and
+// errors.As report err as part of its chain.
+func Wrap(err error) Option {
+	return func(o *options) { o.wrap = err }
+}
+
+// NetError makes the simulated error of a step implement net.Error, with
+// Timeout and Temporary reporting the given values.
+func NetError(timeout, temporary bool) Option {
+	return func(o *options) {
+		o.netErr = true
+		o.timeout = timeout
+		o.temporary = temporary
+	}
+}
+
+// Weights sets the relative probabilities with which the success, error, and
+// panic modes are chosen when scenarios are sampled randomly.
+func Weights(noError, err, panic float64) Option {
+	return func(o *options) {
+		o.weights = map[Mode]float64{
+			ModeNoError: noError,
+			ModeError:   err,
+			ModePanic:   panic,
+		}
+	}
+}
+
 // func OnClose(f func(err error)) Option {
 // 	return func(fr *frame) { fr.onClose = f }
 // }
 
+// A planned step is an entry in the plan for the current and upcoming
+// scenarios. The plan is the only state retained across scenarios: it lists
+// the steps of the current scenario and the mode selected for each.
+type planned struct {
+	key       string
+	modes     []Mode
+	modeIndex int
+}
+
+func (p *planned) mode() Mode { return p.modes[p.modeIndex] }
+
+// A frame holds the execution state of a step of the current scenario.
+// Frames are discarded at the end of each scenario.
 type frame struct {
-	key         string
-	modes       []mode
-	modeIndex   int
+	planned
+
 	noClose     bool
 	ignoreError bool
+	iterate     bool
+	partial     bool
+	notOwned    bool
+	abortFirst  bool
+	desc        string
+	closed      bool
+	closeOpts   []Option
+
+	// openedAt and closedAt hold the stacks at which the frame was opened
+	// and closed if Config.CaptureStacks is set.
+	openedAt []uintptr
+	closedAt []uintptr
 	// onClose   func(err error)
 }
 
 type Simulation struct {
-	testT  *testing.T
+	testT  tester
 	fatalf func(format string, args ...interface{})
 	config *Config
 
-	runIndex int
-	run      []frame
+	// mu guards the state of the current scenario, which may be accessed by
+	// goroutines started with Go. owner is the runtime ID of the goroutine
+	// holding mu, which allows the methods of Simulation to call each other.
+	mu    sync.Mutex
+	owner atomic.Int64
+
+	scenario int
+	plan     []planned
+	exec     []frame // executed steps of the current scenario
+	steps    int     // number of steps executed in the current scenario
+
+	// branches holds the plans that were set aside because a scenario took
+	// a different branch, to be run once the current plan is exhausted, and
+	// diverged holds all plans ever set aside. See Config.AllowBranching.
+	branches [][]planned
+	diverged map[string]bool
+
+	// choose, if not nil, selects the index of the mode with which a newly
+	// encountered step is run, given the weights of the available modes.
+	choose func(weights []float64) int
+
+	// deadline, if not zero, is the time after which no new scenarios are
+	// started. See Config.Budget.
+	deadline time.Time
+
+	// artifactsDir is the directory to which reproductions of the failed
+	// scenarios of the simulation are written, once created. See
+	// Config.ArtifactsDir.
+	artifactsDir string
+
+	// replayed, if not nil, holds the modes of the steps of the scenario that
+	// is replayed. See Config.Replay.
+	replayed map[string]Mode
+
+	// message and kind describe the first failure reported for the current
+	// scenario.
+	message string
+	kind    FailureKind
 
 	// mustErr is the error that must be returned by the simulation function.
-	// This is always nil or a simError.
+	// This is always nil or a simulated error.
 	mustErr error
+
+	// issued holds the errors returned by Open in the current scenario that
+	// may not be ignored.
+	issued []simError
+
+	// logged holds the failure messages logged so far under
+	// Config.SkipErrors.
+	logged map[string]bool
+
+	collector collector
+
+	// mustReach holds the keys that must be executed in the current
+	// scenario if it completes without faults. See MustReach.
+	mustReach []string
+
+	// ctx is the context of the current scenario. See Context.
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	// goroutines tracks the goroutines started in the current scenario. See
+	// Go.
+	goroutines *goroutineSet
+
+	// canceledBy is the key of the step that simulated the cancellation of
+	// the context of the current scenario, if any. See Cancel.
+	canceledBy string
+
+	// schedule is the number of the schedule of the current scenario and
+	// sched its scheduler, if any. See Config.Interleavings.
+	schedule int
+	sched    *scheduler
+
+	// skipReason is the reason passed to SkipScenario in the current
+	// scenario, if any.
+	skipReason string
+
+	// raised is the last simulated panic raised by the goroutine running the
+	// simulation function, which has ID mainGoid, in the current scenario.
+	// It is only recorded with Config.ForbidRecover.
+	raised   *simError
+	mainGoid int64
+
+	// stopped reports whether another goroutine stopped the current
+	// scenario, which the goroutine running the simulation function must
+	// still report, and fatal is the failure to report, if any. See stop.
+	stopped bool
+	fatal   string
+}
+
+// skipScenario reports whether sc is to be skipped as selected by
+// Config.Filter and Config.SkipScenario.
+func (s *Simulation) skipScenario(sc Scenario) bool {
+	if s.config == nil {
+		return false
+	}
+	if !sc.Matches(s.config.Filter...) {
+		return true
+	}
+	return s.config.SkipScenario != nil && s.config.SkipScenario(sc)
 }
 
 func (s *Simulation) ignorePanicOrder() bool {
@@ -126,6 +691,13 @@ func (s *Simulation) ignorePanicOrder() bool {
 	return s.config.IgnorePanicOrder
 }
 
+func (s *Simulation) allowBranching() bool {
+	if s.config == nil {
+		return false
+	}
+	return s.config.AllowBranching
+}
+
 func (s *Simulation) skipErrors() bool {
 	if s.config == nil {
 		return false
@@ -133,15 +705,116 @@ func (s *Simulation) skipErrors() bool {
 	return s.config.SkipErrors
 }
 
-// Run runs simulations by repeatedly calling s until all possible scenarios of
-// a simulation are covered.
-func Run(t *testing.T, config *Config, f func(s *Simulation) error) {
+// groups returns a line for each group of failures of r.
+func groups(r *Results) string {
+	b := &strings.Builder{}
+	for _, g := range r.Groups() {
+		fmt.Fprintln(b, g)
+	}
+	return b.String()
+}
+
+// logProgress returns a copy of config that logs progress to t, if progress
+// is to be reported and no other destination was set.
+func logProgress(t reporter, config *Config) *Config {
+	if config == nil || config.ProgressInterval <= 0 || config.OnProgress != nil {
+		return config
+	}
+	c := *config
+	p := newProgress(t, &c, 0)
+	c.OnProgress = p.report
+	return &c
+}
+
+// RunStandalone runs all scenarios of f without relying on a testing.T and
+// returns the failures, if any. It allows simulations to be embedded in tools
+// other than tests.
+func RunStandalone(config *Config, f func(s *Simulation) error) []Failure {
+	return run(nil, config, f).Failures()
+}
+
+// RunWriter runs all scenarios of f and writes the failures, if any, and a
+// summary to w, in the same format as RunReport with ContinueOnFailure. Like
+// RunStandalone, it does not rely on a testing.T, so that it can be used in
+// programs built with the playground build tag, such as exercises on the Go
+// Playground:
+//
+//	func main() {
+//		if r := errtest.RunWriter(os.Stdout, nil, solution); r.Failed() > 0 {
+//			os.Exit(1)
+//		}
+//	}
+func RunWriter(w io.Writer, config *Config, f func(s *Simulation) error) *Results {
+	r := run(nil, config, f)
+	fmt.Fprintf(w, "%s%s\n", groups(r), r.Summary())
+	return r
+}
+
+// run runs all scenarios of f. Failures are reported to t, if t is not nil.
+// With Config.Interleavings, all scenarios are run for each schedule.
+func run(t reporter, config *Config, f func(s *Simulation) error) *Results {
 	sim := &Simulation{
 		config: config,
 	}
-	runSim(t, sim, f)
+	r := &Results{}
+	schedules := 1
+	if config != nil && config.Interleavings > 0 {
+		schedules = config.Interleavings
+	}
+	if config != nil && config.Budget > 0 {
+		sim.deadline = time.Now().Add(config.Budget)
+	}
+	if config != nil && config.Replay != "" {
+		return sim.replay(t, f)
+	}
+	for sim.schedule = 0; sim.schedule < schedules; sim.schedule++ {
+		if sim.schedule > 0 && sim.overBudget() {
+			r.truncate(Truncation{Schedules: schedules - sim.schedule})
+			break
+		}
+		sim.plan = nil
+		sim.branches = nil
+		sim.diverged = nil
+		runSchedule(t, sim, r, f)
+	}
+	if config != nil && config.MeasureAllocs > 0 {
+		r.Allocs = measureAllocs(config, f)
+	}
+	return r
+}
+
+// runSchedule runs all scenarios of f for the current schedule of sim and
+// adds them to r.
+func runSchedule(t reporter, sim *Simulation, r *Results, f func(s *Simulation) error) {
+	config := sim.config
+	if config != nil && config.Samples > 0 {
+		p := newProgress(t, config, config.Samples)
+		sim.choose = weightedChooser(rand.New(rand.NewSource(config.Seed)))
+		for i := 0; i < config.Samples; i++ {
+			if i > 0 && sim.overBudget() {
+				r.truncate(Truncation{Samples: config.Samples - i})
+				return
+			}
+			sim.plan = sim.plan[:0]
+			r.Scenarios = append(r.Scenarios, runSim(t, sim, f))
+			p.update(len(r.Scenarios))
+		}
+		return
+	}
+	p := newProgress(t, config, 0)
+	c := openCache(t, config, sim.schedule, f)
+	r.Scenarios = append(r.Scenarios, c.runSim(t, sim, f))
+	p.update(len(r.Scenarios))
 	for sim.incRun() {
-		runSim(t, sim, f)
+		if sim.overBudget() {
+			r.truncate(Truncation{Unexplored: sim.unexplored()})
+			break
+		}
+		r.Scenarios = append(r.Scenarios, c.runSim(t, sim, f))
+		p.update(len(r.Scenarios))
+	}
+	if err := c.save(); err != nil && t != nil {
+		t.Logf("errtest: could not save cache: %v", err)
 	}
 }
 
@@ -151,154 +824,851 @@ func isPanic(err error) bool {
 	}
 	switch e := err.(type) {
 	case simError:
-		return e.mode == modePanic
+		return e.mode == ModePanic
 	case interface{ IsPanic() bool }:
 		return e.IsPanic()
 	}
 	return false
 }
 
-func runSim(t *testing.T, s *Simulation, f func(s *Simulation) error) {
-	t.Run("", func(t *testing.T) {
-		s.runIndex = 0
-		s.mustErr = nil
-		s.testT = t
-		s.fatalf = t.Fatalf
-		var err error
-		defer func() {
-			if r := recover(); r != nil {
-				if _, ok := r.(simError); !ok {
-					if !s.config.IgnorePanicOrder {
-						panic(r)
-					}
-					err = simError{mode: modePanic, key: "user"}
-				}
-				// TODO: be pedantic and check that we have the right kind of
-				// panic?
-				if s.mustErr == nil || !isPanic(s.mustErr) {
-					s.Fatalf("simulation panicked unexpectedly")
-				}
+// A reporter is the subset of testing.TB used to report on a simulation. The
+// engine depends on the testing package only through the functions that
+// accept a testing.TB, so that it can be built without it with the playground
+// build tag.
+type reporter interface {
+	Name() string
+	Log(args ...interface{})
+	Logf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// A tester is the subset of testing.TB used to report on a single scenario.
+type tester interface {
+	Fatalf(format string, args ...interface{})
+	Logf(format string, args ...interface{})
+	SkipNow()
+}
+
+// scenarioT runs a scenario on behalf of a testing.TB that does not support
+// subtests, such as a *testing.B, or without any testing.TB at all. Failures
+// are reported to the parent, if any.
+type scenarioT struct {
+	tb      reporter
+	failed  bool
+	skipped bool
+}
+
+func (t *scenarioT) Fatalf(format string, args ...interface{}) {
+	t.failed = true
+	if t.tb != nil {
+		t.tb.Errorf(format, args...)
+	}
+	runtime.Goexit()
+}
+
+func (t *scenarioT) Logf(format string, args ...interface{}) {
+	if t.tb != nil {
+		t.tb.Logf(format, args...)
+	}
+}
+
+func (t *scenarioT) SkipNow() {
+	t.skipped = true
+	runtime.Goexit()
+}
+
+func runSim(t reporter, s *Simulation, f func(s *Simulation) error) Scenario {
+	var ok, skipped, isT bool
+	var coverage float64
+	if s.config != nil && s.config.Coverage != nil {
+		coverage = s.config.Coverage()
+	}
+	if ok, skipped, isT = runSubtest(t, s, f); !isT {
+		st := &scenarioT{tb: t}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.runScenario(st, f)
+		}()
+		<-done
+		ok, skipped = !st.failed, st.skipped
+	}
+	defer s.lock()()
+	sc := s.current()
+	sc.Failed = !ok || s.message != ""
+	sc.Skipped = skipped
+	sc.SkipReason = s.skipReason
+	sc.Message = s.message
+	sc.Kind = s.kind
+	if sc.Failed && sc.Kind == NoFailure {
+		sc.Kind = OtherFailure
+	}
+	if s.config != nil && s.config.Coverage != nil {
+		sc.CoverageGain = s.config.Coverage() - coverage
+	}
+	if !sc.Skipped && s.skipScenario(sc) {
+		sc.Skipped = true
+	}
+	if sc.Failed && !sc.Skipped {
+		s.writeArtifact(t, sc)
+	}
+	s.scenario++
+	s.emitScenarioEnd(sc)
+	return sc
+}
+
+// CurrentMode reports the mode simulated for the step with the given key in
+// the current scenario. It reports false if no such step was executed yet.
+func (s *Simulation) CurrentMode(key string) (Mode, bool) {
+	defer s.lock()()
+	for _, f := range s.exec {
+		if f.key == key {
+			return f.mode(), true
+		}
+	}
+	return ModeNoError, false
+}
+
+// Scenario returns the scenario being run with the steps executed so far.
+func (s *Simulation) Scenario() Scenario {
+	defer s.lock()()
+	return s.current()
+}
+
+// current returns the scenario being run with the steps executed so far.
+func (s *Simulation) current() Scenario {
+	sc := Scenario{Index: s.scenario, Schedule: s.schedule}
+	for _, fr := range s.exec {
+		sc.Steps = append(sc.Steps, Step{Key: fr.key, Mode: fr.mode()})
+	}
+	return sc
+}
+
+// runScenario runs a single scenario of f, reporting failures to t.
+func (s *Simulation) runScenario(t tester, f func(s *Simulation) error) {
+	unlock := s.lock()
+	s.exec = nil
+	s.steps = 0
+	s.mustErr = nil
+	s.issued = nil
+	s.message = ""
+	s.kind = NoFailure
+	s.ctx, s.cancel = nil, nil
+	s.mustReach = nil
+	s.goroutines = &goroutineSet{}
+	s.sched = nil
+	if s.config != nil && s.config.Interleavings > 0 {
+		s.sched = newScheduler(s.schedule, s.config.Seed)
+	}
+	s.canceledBy = ""
+	s.skipReason = ""
+	s.raised = nil
+	s.mainGoid = goid()
+	s.stopped, s.fatal = false, ""
+	s.testT = t
+	s.emit(Event{Kind: EventScenarioStart})
+	s.fatalf = t.Fatalf
+	unlock()
+	var before map[string]string
+	var root int64
+	if s.config != nil && s.config.GoroutineGrace > 0 {
+		before, root = goroutines(), goid()
+	}
+	var err error
+	defer s.cancelContext(context.Canceled)
+	defer func() {
+		r := recover()
+		s.sched.exit(mainGoroutine)
+		goPanic := s.checkGoroutines()
+		// Goroutines that outlived the grace period may still run steps.
+		defer s.lock()()
+		s.reportStopped()
+		if s.skipReason != "" {
+			return // the solution opted out of the scenario
+		}
+		s.checkRecover(r)
+		if r != nil {
+			se, isSim := r.(simError)
+			switch {
+			case isSim && (s.ignorePanicOrder() || s.mustErr == nil || !isPanic(s.mustErr) || s.simulated(se)):
+				// A simulated panic of this scenario, possibly raised by a
+				// close while panicking, propagated, or a panic was not
+				// expected at all.
+			case s.wrapsPanic(r):
+				// The simulated panic was raised again wrapped in an error,
+				// which preserves it.
+			case s.ignorePanicOrder() && isPanicError(r):
+				// Any panic error may stand in for the simulated one.
+				err = simError{mode: ModePanic, key: "user"}
+			case s.mustErr != nil && isPanic(s.mustErr):
+				s.fail(ChangedPanic, "simulated panic %v was raised again as %v, which does not wrap it", s.mustErr, r)
+			default:
+				panic(r)
+			}
+			if s.mustErr == nil || !isPanic(s.mustErr) {
+				s.fail(UnexpectedPanic, "simulation panicked unexpectedly")
 			}
-			if err != s.mustErr {
-				if s.mustErr == nil || !isPanic(s.mustErr) {
-					s.Fatalf("simulation did not return the correct error: got %v; want %v", err, s.mustErr)
+			if s.config != nil && s.config.RequireCloseOnPanic {
+				if keys := s.unclosed(); len(keys) > 0 {
+					s.fail(Leak, "not closed after panic: %s%s", strings.Join(keys, ", "), s.unclosedAt())
 				}
 			}
-		}()
-		err = f(s)
-	})
+		}
+		if r == nil && !goPanic {
+			s.checkRepanic()
+		}
+		if !s.isMustErr(err) {
+			if s.mustErr == nil || !isPanic(s.mustErr) {
+				s.logChainDiff(err)
+				s.fail(WrongError, "simulation did not return the correct error: got %v; want %v", err, s.mustErr)
+			}
+		}
+		if goPanic && r == nil && !s.isMustErr(err) {
+			s.logChainDiff(err)
+			s.fail(WrongError, "panic in goroutine was not passed on: got %v; want %v", err, s.mustErr)
+		}
+		// Only check for leaks if the scenario completed without failures.
+		if r == nil && s.message == "" {
+			if keys := s.unclosed(); len(keys) > 0 {
+				s.fail(Leak, "not closed: %s%s", strings.Join(keys, ", "), s.unclosedAt())
+			}
+		}
+		if r == nil && s.message == "" && err == nil && s.mustErr == nil {
+			if keys := s.unreached(); len(keys) > 0 {
+				s.fail(Unreached, "not reached: %s", strings.Join(keys, ", "))
+			}
+		}
+		if r == nil && s.message == "" {
+			s.checkWrapping(err)
+		}
+		if s.message == "" && s.config != nil && s.config.RequireChecked {
+			s.Checked(err)
+			if errs := s.unchecked(); len(errs) > 0 {
+				s.fail(Unchecked, "errors not checked: %s", strings.Join(errs, ", "))
+			}
+		}
+		if s.message == "" && s.config != nil && s.config.DetectCollected {
+			if keys := s.collectedUnclosed(); len(keys) > 0 {
+				s.fail(Leak, "garbage collected without being closed: %s", strings.Join(keys, ", "))
+			}
+		}
+		if before != nil && s.message == "" {
+			// Goroutines waiting for the context to be done are not leaked.
+			s.cancelContext(context.Canceled)
+			if leaked := leakedGoroutines(before, root, s.config.GoroutineGrace); len(leaked) > 0 {
+				s.fail(Leak, "%d goroutine(s) still running at end of scenario:\n%s",
+					len(leaked), strings.Join(leaked, "\n\n"))
+			}
+		}
+	}()
+	err = f(s)
+}
+
+// unclosed returns the quoted keys of all frames of the current scenario that
+// still need to be closed.
+func (s *Simulation) unclosed() (keys []string) {
+	for _, f := range s.exec {
+		if !f.noClose {
+			keys = append(keys, s.quote(f.key))
+		}
+	}
+	return keys
 }
 
 func (s *Simulation) incRun() bool {
-	for len(s.run) > 0 {
-		p := len(s.run) - 1
-		s.run[p].modeIndex++
-		if s.run[p].modeIndex != len(s.run[p].modes) {
+	max := s.maxFaults()
+	for len(s.plan) > 0 {
+		p := len(s.plan) - 1
+		if max > 0 && faults(s.plan[:p]) >= max {
+			s.plan = s.plan[:p]
+			continue
+		}
+		s.plan[p].modeIndex++
+		if s.plan[p].modeIndex != len(s.plan[p].modes) {
 			return true
 		}
-		s.run = s.run[:p]
+		s.plan = s.plan[:p]
+	}
+	if len(s.branches) > 0 {
+		s.plan = s.branches[0]
+		s.branches = s.branches[1:]
+		return true
 	}
 	return false
 }
 
-func (s *Simulation) setMustError(m mode, key string) error {
-	err := simError{m, key}
+// setAside schedules plan to be run once the current plan is exhausted,
+// unless it was set aside before.
+func (s *Simulation) setAside(plan []planned) {
+	var b strings.Builder
+	for _, p := range plan {
+		fmt.Fprintf(&b, "%s=%d\x00", p.key, p.modeIndex)
+	}
+	if s.diverged[b.String()] {
+		return
+	}
+	if s.diverged == nil {
+		s.diverged = map[string]bool{}
+	}
+	s.diverged[b.String()] = true
+	s.branches = append(s.branches, append([]planned(nil), plan...))
+}
+
+// maxFaults returns the maximum number of faults per scenario, or 0 if there
+// is no limit.
+func (s *Simulation) maxFaults() int {
+	switch {
+	case s.config == nil:
+	case s.config.MaxFaults > 0:
+		return s.config.MaxFaults
+	case s.config.Short:
+		return 1
+	}
+	return 0
+}
+
+// faults returns the number of the given steps that simulate a fault.
+func faults(plan []planned) (n int) {
+	for _, f := range plan {
+		if f.modeIndex > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Simulation) setMustError(err error) error {
+	s.cancelContext(err)
 	if s.mustErr == nil {
 		s.mustErr = err
-	} else if e := s.mustErr.(simError); m == modePanic && e.mode != modePanic {
-		s.mustErr = err
+	} else {
+		cur, _ := asSimError(s.mustErr)
+		next, _ := asSimError(err)
+		if s.priority()(cur.step(), next.step()) {
+			s.mustErr = err
+		}
 	}
 	return err
 }
 
+func (s *Simulation) priority() Priority {
+	switch {
+	case s.config == nil:
+	case s.config.Priority != nil:
+		return s.config.Priority
+	case s.config.LastErrorWins:
+		return LastError
+	}
+	return FirstError
+}
+
+// isMustErr reports whether err is the error that must be returned or, if
+// Config.AllowWrapping is set, wraps it.
+func (s *Simulation) isMustErr(err error) bool {
+	if err == s.mustErr {
+		return true
+	}
+	if s.config == nil || !s.config.AllowWrapping || err == nil || s.mustErr == nil {
+		return false
+	}
+	return errors.Is(err, s.mustErr)
+}
+
+// Checked marks err as inspected by the solution. See Config.RequireChecked.
+func (s *Simulation) Checked(err error) {
+	defer s.lock()()
+	if e, ok := asSimError(err); ok && e.state != nil {
+		e.state.checked = true
+	}
+}
+
+// unchecked returns the errors returned by the current scenario that were
+// never inspected.
+func (s *Simulation) unchecked() (errs []string) {
+	for _, e := range s.issued {
+		if !e.state.checked {
+			errs = append(errs, strconv.Quote(e.Error()))
+		}
+	}
+	return errs
+}
+
+// Fatalf reports a failure of the current scenario and stops it. The failure
+// is reported with kind OtherFailure.
 func (s *Simulation) Fatalf(format string, args ...interface{}) {
+	s.fail(OtherFailure, format, args...)
+}
+
+// fail reports a failure of the given kind and stops the current scenario.
+func (s *Simulation) fail(kind FailureKind, format string, args ...interface{}) {
+	defer s.lock()()
+	if s.skipScenario(s.current()) {
+		s.stop()
+	}
+	s.emit(Event{Kind: EventFailure, Failure: kind, Message: fmt.Sprintf(format, args...)})
+	if s.message == "" {
+		s.message = fmt.Sprintf(format, args...)
+		s.kind = kind
+	}
 	if s.skipErrors() {
-		s.testT.Logf(format, args...)
+		// Only log the first occurrence of each message; the remaining
+		// occurrences are summarized once all scenarios have run.
+		msg := fmt.Sprintf(format, args...)
+		if !s.logged[msg] {
+			if s.logged == nil {
+				s.logged = map[string]bool{}
+			}
+			s.logged[msg] = true
+			s.testT.Logf("%s", msg)
+		}
+	} else if goid() != s.mainGoid {
+		if s.fatal == "" {
+			s.fatal = fmt.Sprintf(format, args...)
+		}
 	} else {
 		s.fatalf(format, args...)
 	}
+	s.stop()
+}
+
+// stop stops the current scenario. Only the goroutine running the simulation
+// function may stop the test. Any other goroutine, such as one started with
+// Go, records that the scenario was stopped and exits instead; the simulation
+// function reports the failure at its next step or once it returns. The
+// context of the scenario is canceled, so that a simulation function waiting
+// for it does not block forever.
+func (s *Simulation) stop() {
+	if goid() == s.mainGoid {
+		s.testT.SkipNow()
+		return
+	}
+	s.stopped = true
+	if s.cancel != nil {
+		s.cancel(errStopped)
+	}
+	runtime.Goexit()
+}
+
+// errStopped is the cause of the cancellation of the context of a scenario
+// that was stopped by a goroutine other than the one running the simulation
+// function.
+var errStopped = errors.New("errtest: scenario stopped by a failure in another goroutine")
+
+// reportStopped reports the failure recorded by another goroutine that
+// stopped the current scenario, if any, when called from the goroutine
+// running the simulation function.
+func (s *Simulation) reportStopped() {
+	defer s.lock()()
+	if !s.stopped || goid() != s.mainGoid {
+		return
+	}
+	if s.fatal != "" {
+		s.fatalf("%s", s.fatal)
+	}
+	s.testT.SkipNow()
+}
+
+// SkipScenario skips the current scenario for the given reason. The scenario
+// is recorded as skipped in Results instead of passing or failing, which
+// allows a solution to opt out of classes of scenarios it intentionally does
+// not handle, as in
+//
+//	if err := s.Open("reader", errtest.NoPanic()); err != nil {
+//		s.SkipScenario("errors of reader are retried by the caller")
+//	}
+//
+// Failures reported before SkipScenario is called are not undone. Like
+// testing.T.SkipNow, SkipScenario must be called from the goroutine running
+// the simulation function.
+func (s *Simulation) SkipScenario(reason string) {
+	defer s.lock()()
+	s.skipReason = reason
+	s.testT.Logf("skipped: %s", reason)
 	s.testT.SkipNow()
 }
 
 func (s *Simulation) Open(key string, opts ...Option) error {
+	defer s.step()()
+	if s.canceledBy != "" {
+		s.fail(IgnoredCancel, "%s executed after the context was canceled by %s", s.quote(key), s.quote(s.canceledBy))
+		return nil
+	}
+	return s.open(key, opts...)
+}
+
+// Reopen is like Open, but allows a key to be opened again once its earlier
+// openings were closed or failed, as is needed for solutions that reconnect or
+// retry. Each opening is a new generation of the key, recorded as key#n,
+// where n is the number of earlier generations, and closed with Close(key).
+// Generations take part in the checks for close order and leaks like any
+// other value. Reopening a key while an earlier generation is still open
+// fails the scenario with a Leak.
+func (s *Simulation) Reopen(key string, opts ...Option) error {
+	defer s.step()()
+	n := 0
+	for _, f := range s.exec {
+		if !f.is(key) {
+			continue
+		}
+		if !f.noClose {
+			s.fail(Leak, "%s reopened while %s is still open%s", s.quote(key), s.quote(f.key), where("opened at", f.openedAt))
+			return nil
+		}
+		n++
+	}
+	if n > 0 {
+		key = fmt.Sprintf("%s#%d", key, n)
+	}
+	// Iterate marks the frame as a generation of the key, so that
+	// Close(key) matches it.
+	return s.Open(key, append(opts, Iterate())...)
+}
+
+// open executes the step with the given key. Unlike Open, it is also used
+// for closes.
+func (s *Simulation) open(key string, opts ...Option) error {
+	defer s.step()()
+	s.steps++
+	if s.config != nil && s.config.MaxSteps > 0 && s.steps > s.config.MaxSteps {
+		s.fail(TooManySteps, "exceeded %d simulation steps at %s", s.config.MaxSteps, s.quote(key))
+		return nil
+	}
 	o := options{
-		frame: frame{key: key},
+		frame: frame{key: key, openedAt: s.callers()},
 	}
 	for _, fn := range opts {
 		fn(&o)
 	}
-	o.modes = append(o.modes, modeNoError)
+	if s.config != nil {
+		for _, fn := range s.config.KeyOptions[key] {
+			fn(&o)
+		}
+	}
+	for _, r := range o.requires {
+		if !s.use(r.key, r.desc) {
+			return nil
+		}
+	}
+	if o.iterate {
+		key = s.iterationKey(key)
+		o.key = key
+	}
+	if o.storeKey != nil {
+		*o.storeKey = key
+	}
+	o.modes = append(o.modes, ModeNoError)
 	if !o.noError {
-		o.modes = append(o.modes, modeError)
+		o.modes = append(o.modes, ModeError)
 	}
 	if !o.noPanic {
-		o.modes = append(o.modes, modePanic)
+		o.modes = append(o.modes, ModePanic)
+	}
+	i := len(s.exec)
+	if i < len(s.plan) && s.plan[i].key != key && s.allowBranching() {
+		// The simulation took a different path than before. Set the planned
+		// scenario aside and continue along the new branch.
+		s.setAside(s.plan)
+		s.plan = s.plan[:i]
 	}
-	if s.runIndex == len(s.run) {
+	if i == len(s.plan) {
 		// New entry. Ensure that a statement with this key wasn't already
 		// executed.
-		for _, f := range s.run {
+		for _, f := range s.exec {
 			if f.key == key {
-				s.Fatalf("statement %q was already executed", key)
+				s.fail(DuplicateStep, "statement %s was already executed%s", s.quote(key), where("first executed at", f.openedAt))
 				return nil
 			}
 		}
-		s.run = append(s.run, o.frame)
+		if s.choose != nil {
+			weights := make([]float64, len(o.modes))
+			for i, m := range o.modes {
+				weights[i] = 1
+				if w, ok := o.weights[m]; ok {
+					weights[i] = w
+				}
+			}
+			o.modeIndex = s.choose(weights)
+		} else if m, ok := s.replayed[key]; ok {
+			for j, mode := range o.modes {
+				if mode == m {
+					o.modeIndex = j
+				}
+			}
+		}
+		s.plan = append(s.plan, o.planned)
 	} else {
 		// Simulation of a variation of a previous run. Expect the same key as
 		// before.
-		if s.run[s.runIndex].key != key {
-			s.Fatalf("non-deterministic simulation at %q", key)
+		if s.plan[i].key != key {
+			s.fail(NonDeterministic, "non-deterministic simulation at %s", s.quote(key))
 			return nil
 		}
-		o.frame.modeIndex = s.run[s.runIndex].modeIndex
-		s.run[s.runIndex] = o.frame
+		s.plan[i].modes = o.modes
+		o.modeIndex = s.plan[i].modeIndex
 	}
-	defer func() { s.runIndex++ }()
-	switch f := s.run[s.runIndex]; f.modes[f.modeIndex] {
-	case modeError:
-		s.run[s.runIndex].noClose = true
-		if !f.ignoreError {
-			s.setMustError(modeError, key)
+	s.exec = append(s.exec, o.frame)
+	f := s.exec[i]
+	s.emitStep(key, f.mode())
+	switch f.mode() {
+	case ModeError:
+		s.exec[i].noClose = s.exec[i].noClose || !f.partial
+		e := simError{mode: ModeError, key: key}
+		if f.ignoreError {
+			return o.newError(e)
 		}
+		e.state = &errState{}
+		s.issued = append(s.issued, e)
 		// fmt.Println(key, "errr")
-		return simError{modeError, key}
-	case modePanic:
+		return s.setMustError(o.newError(e))
+	case ModePanic:
 		// fmt.Println(key, "panic")
-		s.run[s.runIndex].noClose = true
-		panic(s.setMustError(modePanic, key))
+		s.exec[i].noClose = true
+		e := simError{mode: ModePanic, key: key}
+		if s.forbidRecover() && goid() == s.mainGoid {
+			s.raised = &e
+		}
+		panic(s.setMustError(e))
 	}
 	// fmt.Println(key, "success")
 	return nil
 }
 
+// Use records a use, other than closing it, of the value opened for the given
+// key. It fails the scenario with UseAfterClose if the value was closed or
+// failed to open, or with UsedPartial if it is a partial value returned along
+// with an error. See Partial.
+func (s *Simulation) Use(key string) {
+	defer s.lock()()
+	s.use(key, "")
+}
+
+// use implements Use, reporting whether the scenario may continue.
+// A non-empty desc describes the dependency on the value in failures.
+func (s *Simulation) use(key, desc string) bool {
+	if desc != "" {
+		desc += ": "
+	}
+	for i := len(s.exec) - 1; i >= 0; i-- {
+		f := &s.exec[i]
+		if !f.is(key) {
+			continue
+		}
+		switch {
+		case f.partial && f.mode() == ModeError:
+			s.fail(UsedPartial, "%s%s used after it was returned along with an error%s", desc, s.quote(key), where("opened at", f.openedAt))
+			return false
+		case f.closed:
+			s.fail(UseAfterClose, "%s%s used after it was closed%s", desc, s.quote(key), where("closed at", f.closedAt))
+			return false
+		case f.mode() != ModeNoError:
+			s.fail(UseAfterClose, "%s%s used after it failed to open%s", desc, s.quote(key), where("opened at", f.openedAt))
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+// Op executes the operation op, such as "read" or "write", on the value opened
+// for key. It fails the scenario with Misuse if no value was opened for key,
+// and with UseAfterClose if that value was closed or failed to open.
+//
+// Op is a convenience wrapper around Open: the operation is not part of the
+// value, but an independent step keyed by key, a dot, and op, as in
+// "writer.write", with a generation suffix, as in "writer.write#1", for later
+// executions. It may fail or panic like any other step, but needs no close.
+// Scenarios, Config.KeyOptions, golden files, and fault statistics refer to
+// operations by these keys. The step is described as the operation on the
+// value in diagnostics.
+func (s *Simulation) Op(key, op string, opts ...Option) error {
+	defer s.step()()
+	opened := false
+	for _, f := range s.exec {
+		opened = opened || f.is(key)
+	}
+	if !opened {
+		s.fail(Misuse, "%s on %s, which was not opened", op, s.quote(key))
+		return nil
+	}
+	if !s.use(key, "") {
+		return nil
+	}
+	desc := fmt.Sprintf("%s on %s", op, s.quote(key))
+	opts = append([]Option{Describe(desc)}, opts...)
+	return s.Open(key+"."+op, append(opts, Iterate(), NoClose())...)
+}
+
+// Checkpoint records that the step with the given key was executed. Unlike
+// Open, it never simulates a fault and needs no close. Together with
+// MustReach, it allows dares to require steps that do not involve resources.
+func (s *Simulation) Checkpoint(key string) {
+	s.Open(key, NoError(), NoPanic(), NoClose())
+}
+
+// MustReach declares that the steps with the given keys must be executed if
+// the current scenario completes without faults. Keys may be declared at any
+// point during the scenario; they are checked once it completes.
+func (s *Simulation) MustReach(keys ...string) {
+	defer s.lock()()
+	s.mustReach = append(s.mustReach, keys...)
+}
+
+// unreached returns the quoted keys passed to MustReach that were not
+// executed in the current scenario.
+func (s *Simulation) unreached() (keys []string) {
+	for _, key := range s.mustReach {
+		found := false
+		for _, f := range s.exec {
+			found = found || f.is(key)
+		}
+		if !found {
+			keys = append(keys, s.quote(key))
+		}
+	}
+	return keys
+}
+
+// TransferClose transfers the responsibility of closing the value opened with
+// key from to the value opened with key to. After this, closing to counts as
+// closing from, and from may no longer be closed. It models wrappers that take
+// ownership of the value they wrap, like gzip.NewWriter or tls.Client.
+func (s *Simulation) TransferClose(from, to string) {
+	defer s.lock()()
+	p, q := -1, -1
+	for i, f := range s.exec {
+		switch {
+		case f.noClose:
+		case f.key == from:
+			p = i
+		case f.key == to:
+			q = i
+		}
+	}
+	if p == -1 || q == -1 {
+		s.fail(Misuse, "cannot transfer close of %s to %s: both must be open", s.quote(from), s.quote(to))
+		return
+	}
+	s.exec[p].noClose = true
+}
+
+// iterationKey returns the key for the next iteration of key.
+func (s *Simulation) iterationKey(key string) string {
+	n := 0
+	for _, f := range s.exec {
+		if f.iterate && baseKey(f.key) == key {
+			n++
+		}
+	}
+	if n == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s#%d", key, n)
+}
+
+// baseKey returns key without its iteration suffix, if any.
+func baseKey(key string) string {
+	if i := strings.LastIndexByte(key, '#'); i >= 0 {
+		if _, err := strconv.Atoi(key[i+1:]); err == nil {
+			return key[:i]
+		}
+	}
+	return key
+}
+
+// abortsFirst reports whether the most recently opened value for key that is
+// still open was declared with AbortFirst.
+func (s *Simulation) abortsFirst(key string) bool {
+	for i := len(s.exec) - 1; i >= 0; i-- {
+		if f := &s.exec[i]; f.is(key) && !f.noClose {
+			return f.abortFirst
+		}
+	}
+	return false
+}
+
+// simulated reports whether e is a panic simulated by a step of the current
+// scenario, rather than one made up by the solution, such as with
+// NewPanicError.
+func (s *Simulation) simulated(e simError) bool {
+	for _, f := range s.exec {
+		if f.key == e.key && f.mode() == ModePanic {
+			return e.mode == ModePanic
+		}
+	}
+	return false
+}
+
+// is reports whether the frame was opened with key.
+func (f *frame) is(key string) bool {
+	return f.key == key || f.iterate && baseKey(f.key) == key
+}
+
 func (s *Simulation) Close(key string, opts ...Option) error {
 	return s.CloseWithError(key, s.mustErr, opts...)
 }
 
 func (s *Simulation) CloseWithError(key string, err error, opts ...Option) error {
-	p := len(s.run) - 1
+	defer s.step()()
+	s.Checked(err)
+	closedAt := s.callers()
+	for p := len(s.exec) - 1; p >= 0; p-- {
+		if f := s.exec[p]; f.is(key) {
+			if f.notOwned {
+				s.fail(NotOwned, "%s closed, but it is not owned by the simulation function%s%s", s.quote(key),
+					where("closed at", closedAt), where("obtained at", f.openedAt))
+				return nil
+			}
+			break
+		}
+	}
+	abort := s.mustErr != nil && s.abortsFirst(key)
+	p := len(s.exec) - 1
 	for ; p >= 0; p-- {
-		f := s.run[p]
+		f := s.exec[p]
 		if !f.noClose {
-			s.run[p].noClose = true
-			if f.key != key {
-				s.Fatalf("%q closed in wrong order (expected %q)", f.key, key)
+			if abort && !f.is(key) {
+				// Values opened after an aborted value are closed after it.
+				continue
+			}
+			s.exec[p].noClose = true
+			s.exec[p].closed = true
+			s.exec[p].closedAt = closedAt
+			if !f.is(key) {
+				if isChild(f.key, key) {
+					s.fail(WrongCloseOrder, "%s closed before its child %s%s%s", s.quote(key), s.quote(f.key),
+						where("closed at", closedAt), where("child opened at", f.openedAt))
+					return nil
+				}
+				s.fail(WrongCloseOrder, "%s closed in wrong order (expected %s)%s%s", s.quote(f.key), s.quote(key),
+					where("closed at", closedAt), where(s.quote(f.key)+" opened at", f.openedAt))
 				return nil
 			}
-			if err != s.mustErr {
+			if s.mustErr != nil && !f.abortFirst {
+				for _, g := range s.exec[:p] {
+					if g.abortFirst && !g.noClose {
+						s.fail(WrongCloseOrder, "%s closed before %s was aborted%s%s", s.quote(key), s.quote(g.key),
+							where("closed at", closedAt), where(s.quote(g.key)+" opened at", g.openedAt))
+						return nil
+					}
+				}
+			}
+			if !s.isMustErr(err) {
 				if !s.ignorePanicOrder() || !isPanic(err) || !isPanic(s.mustErr) {
-					s.Fatalf("close of %q with wrong error: got %v; want %v", key, err, s.mustErr)
+					s.logChainDiff(err)
+					s.fail(WrongError, "close of %s with wrong error: got %v; want %v", s.quote(key), err, s.mustErr)
 					return nil
 				}
 			}
-			return s.Open(key+".close", append(opts, NoClose())...)
+			closeOpts := append([]Option{}, f.closeOpts...)
+			closeOpts = append(closeOpts, opts...)
+			return s.open(f.key+".close", append(closeOpts, NoClose())...)
 		}
 		if f.key == key {
-			s.Fatalf("%q was already closed or should not be closed", key)
+			s.fail(DoubleClose, "%s was already closed or should not be closed%s%s", s.quote(key),
+				where("closed again at", closedAt), where("first closed at", f.closedAt))
 			return nil
 		}
 	}
-	s.Fatalf("unmatched close %q", key)
+	s.fail(Misuse, "unmatched close %s%s", s.quote(key), where("closed at", closedAt))
 	return nil
 }
@@ -5,10 +5,15 @@
 package errtest
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSimulation(t *testing.T) {
@@ -54,7 +59,7 @@ func TestSimulation(t *testing.T) {
 		desc:  "fail to ignore error",
 		count: 3,
 		f: func(s *Simulation) (err error) {
-			return s.Open("reader", IgnoreError())
+			return s.Open("reader", IgnoreError(), NoClose())
 		},
 		errs: "1:simulation did not return the correct error: got reader: Error; want <nil>\n",
 	}, {
@@ -94,8 +99,8 @@ func TestSimulation(t *testing.T) {
 		desc:  "incorrect error returned",
 		count: 7,
 		f: func(s *Simulation) (err error) {
-			err = s.Open("reader")
-			return s.Open("writer")
+			err = s.Open("reader", NoClose())
+			return s.Open("writer", NoClose())
 		},
 		errs: `3:simulation did not return the correct error: got <nil>; want reader: Error
 4:simulation did not return the correct error: got writer: Error; want reader: Error
@@ -176,6 +181,33 @@ func TestSimulation(t *testing.T) {
 3:close of "o1" with wrong error: got <nil>; want o2: Error
 3:simulation did not return the correct error: got <nil>; want o2: Error
 4:close of "o1" with wrong error: got <nil>; want o2: Panic
+`,
+	}, {
+		desc:   "not closed on panic",
+		config: Pedantic,
+		count:  2,
+		f: func(s *Simulation) (err error) {
+			s.Open("o1", NoError(), NoPanic())
+			s.Open("o2", NoError(), NoClose())
+			s.Close("o1", NoError(), NoPanic())
+			return nil
+		},
+		errs: `1:not closed after panic: "o1"
+`,
+	}, {
+		desc:  "not closed",
+		count: 3,
+		f: func(s *Simulation) (err error) {
+			s.Open("o1", NoError(), NoPanic())
+			s.Open("o2", NoError(), NoPanic())
+			if err := s.Open("o3", NoClose()); err != nil {
+				return err
+			}
+			s.Close("o2", NoError(), NoPanic())
+			return nil
+		},
+		errs: `0:not closed: "o1"
+1:not closed: "o1", "o2"
 `,
 	}, {
 		desc:  "duplicate entry",
@@ -192,9 +224,9 @@ func TestSimulation(t *testing.T) {
 		count: 3,
 		f: func(s *Simulation) (err error) {
 			if count != 2 {
-				return s.Open("reader")
+				return s.Open("reader", NoClose())
 			}
-			return s.Open("writer")
+			return s.Open("writer", NoClose())
 		},
 		errs: `1:non-deterministic simulation at "writer"
 `,
@@ -210,7 +242,7 @@ func TestSimulation(t *testing.T) {
 		t.Run(tc.desc, func(t *testing.T) {
 			count = 0
 			errs := ""
-			Run(t, nil, func(s *Simulation) error {
+			Run(t, tc.config, func(s *Simulation) error {
 				s.fatalf = func(format string, args ...interface{}) {
 
 					format = strconv.Itoa(count-1) + ":" + format + "\n"
@@ -229,3 +261,1411 @@ func TestSimulation(t *testing.T) {
 		})
 	}
 }
+
+func TestRunReport(t *testing.T) {
+	r := RunReport(t, SkipErrors, func(s *Simulation) error {
+		err := s.Open("reader", NoPanic())
+		if err != nil {
+			return err
+		}
+		s.Close("reader", NoPanic())
+		return nil
+	})
+	want := []Scenario{{
+		Index: 0,
+		Steps: []Step{{"reader", ModeNoError}, {"reader.close", ModeNoError}},
+	}, {
+		Index:   1,
+		Steps:   []Step{{"reader", ModeNoError}, {"reader.close", ModeError}},
+		Failed:  true,
+		Kind:    WrongError,
+		Message: "simulation did not return the correct error: got <nil>; want reader.close: Error",
+		Skipped: true,
+	}, {
+		Index: 2,
+		Steps: []Step{{"reader", ModeError}},
+	}}
+	if !reflect.DeepEqual(r.Scenarios, want) {
+		t.Errorf("got %+v; want %+v", r.Scenarios, want)
+	}
+	if got := r.Failed(); got != 1 {
+		t.Errorf("Failed: got %d; want 1", got)
+	}
+	wantSummary := "3 scenarios: 1 failed, 1 skipped\nfailures by fault:\n\treader.close=Error: 1 of 1\nall failures involve reader.close=Error"
+	if got := r.Summary(); got != wantSummary {
+		t.Errorf("Summary: got %q; want %q", got, wantSummary)
+	}
+	if got := r.Scenarios[1].Faults(); !reflect.DeepEqual(got, want[1].Steps[1:]) {
+		t.Errorf("Faults: got %v; want %v", got, want[1].Steps[1:])
+	}
+}
+
+// recordTB is a testing.TB that does not support subtests.
+type recordTB struct {
+	testing.TB
+	errs []string
+	logs []string
+}
+
+func (t *recordTB) Helper()                 {}
+func (t *recordTB) Log(args ...interface{}) {}
+func (t *recordTB) Logf(format string, args ...interface{}) {
+	t.logs = append(t.logs, fmt.Sprintf(format, args...))
+}
+func (t *recordTB) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRunTB(t *testing.T) {
+	tb := &recordTB{}
+	count := 0
+	r := RunReport(tb, nil, func(s *Simulation) error {
+		count++
+		s.Open("reader", NoPanic(), NoClose())
+		return nil
+	})
+	if count != 2 {
+		t.Errorf("count: got %d; want 2", count)
+	}
+	want := []string{"simulation did not return the correct error: got <nil>; want reader: Error"}
+	if !reflect.DeepEqual(tb.errs, want) {
+		t.Errorf("errors: got %q; want %q", tb.errs, want)
+	}
+	if !r.Scenarios[1].Failed {
+		t.Errorf("scenario 1 unexpectedly passed")
+	}
+}
+
+func TestRunStandalone(t *testing.T) {
+	failures := RunStandalone(nil, func(s *Simulation) error {
+		s.Open("reader", NoPanic(), NoClose())
+		return nil
+	})
+	want := []Failure{{
+		Scenario: 1,
+		Faults:   []Step{{"reader", ModeError}},
+		Kind:     WrongError,
+		Message:  "simulation did not return the correct error: got <nil>; want reader: Error",
+	}}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("got %v; want %v", failures, want)
+	}
+}
+
+func TestRunWriter(t *testing.T) {
+	b := &strings.Builder{}
+	r := RunWriter(b, nil, func(s *Simulation) error {
+		s.Open("reader", NoClose())
+		return nil
+	})
+	if got := r.Failed(); got != 1 {
+		t.Errorf("failed: got %d; want 1", got)
+	}
+	want := `scenario 1 [reader=Error]: simulation did not return the correct error: got <nil>; want reader: Error
+3 scenarios: 1 failed, 0 skipped
+failures by fault:
+	reader=Error: 1 of 1
+all failures involve reader=Error
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestContinueOnFailure(t *testing.T) {
+	tb := &recordTB{}
+	r := RunReport(tb, &Config{ContinueOnFailure: true}, func(s *Simulation) error {
+		s.Open("reader", NoClose())
+		return nil
+	})
+	if got := r.Failed(); got != 1 {
+		t.Errorf("failed: got %d; want 1", got)
+	}
+	want := []string{`scenario 1 [reader=Error]: simulation did not return the correct error: got <nil>; want reader: Error
+3 scenarios: 1 failed, 0 skipped
+failures by fault:
+	reader=Error: 1 of 1
+all failures involve reader=Error`}
+	if !reflect.DeepEqual(tb.errs, want) {
+		t.Errorf("errors:\ngot  %q\nwant %q", tb.errs, want)
+	}
+}
+
+func TestGoroutineLeak(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	config := &Config{GoroutineGrace: 10 * time.Millisecond}
+	failures := RunStandalone(config, func(s *Simulation) error {
+		err := s.Open("reader", NoPanic(), NoClose())
+		if err == nil {
+			go func() { <-block }()
+		}
+		return err
+	})
+	if len(failures) != 1 || failures[0].Scenario != 0 {
+		t.Fatalf("got %v; want failure for scenario 0", failures)
+	}
+	msg := failures[0].Message
+	if !strings.HasPrefix(msg, "1 goroutine(s) still running") || !strings.Contains(msg, "TestGoroutineLeak") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestGoroutineLeakOwnership(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	// other starts goroutines outside of the scenario while it runs.
+	other := make(chan chan struct{})
+	go func() {
+		for done := range other {
+			go func() { <-block }()
+			close(done)
+		}
+	}()
+	defer close(other)
+	config := &Config{GoroutineGrace: 10 * time.Millisecond}
+	failures := RunStandalone(config, func(s *Simulation) error {
+		done := make(chan struct{})
+		other <- done
+		<-done
+		err := s.Open("reader", NoPanic(), NoClose())
+		if err == nil {
+			started := make(chan struct{})
+			go func() {
+				go func() { <-block }()
+				close(started)
+				<-block
+			}()
+			<-started
+		}
+		return err
+	})
+	if len(failures) != 1 || failures[0].Scenario != 0 {
+		t.Fatalf("got %v; want failure for scenario 0", failures)
+	}
+	if msg := failures[0].Message; !strings.HasPrefix(msg, "2 goroutine(s) still running") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestGoroutineLeakContext(t *testing.T) {
+	config := &Config{GoroutineGrace: 10 * time.Millisecond}
+	failures := RunStandalone(config, func(s *Simulation) error {
+		ctx := s.Context()
+		go func() { <-ctx.Done() }()
+		return s.Open("reader", NoPanic(), NoClose())
+	})
+	if len(failures) != 0 {
+		t.Errorf("got %v; want no failures", failures)
+	}
+}
+
+func TestGoroutineLeakGo(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	config := &Config{GoroutineGrace: 10 * time.Millisecond}
+	failures := RunStandalone(config, func(s *Simulation) error {
+		err := s.Open("reader", NoPanic(), NoClose())
+		if err == nil {
+			s.Go(func() { <-block })
+		}
+		return err
+	})
+	if len(failures) != 1 || failures[0].Scenario != 0 {
+		t.Fatalf("got %v; want failure for scenario 0", failures)
+	}
+	// The engine waits for the goroutine without starting any of its own.
+	if msg := failures[0].Message; !strings.HasPrefix(msg, "1 goroutine(s) still running") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+// TestGoConcurrentSteps checks that steps of goroutines started with Go may
+// run concurrently with those of the simulation function. Run with -race.
+func TestGoConcurrentSteps(t *testing.T) {
+	failures := RunStandalone(nil, func(s *Simulation) error {
+		errc := make(chan error, 1)
+		s.Go(func() {
+			for i := 0; i < 3; i++ {
+				s.Checkpoint(fmt.Sprint("go", i))
+			}
+			errc <- s.Open("w", NoError(), NoPanic(), NoClose())
+		})
+		for i := 0; i < 3; i++ {
+			s.Checkpoint(fmt.Sprint("main", i))
+			s.CurrentMode("w")
+		}
+		return <-errc
+	})
+	if len(failures) != 0 {
+		t.Errorf("got %v; want no failures", failures)
+	}
+}
+
+func TestGo(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config *Config
+		f      func(s *Simulation) error
+		want   []FailureKind
+	}{{
+		desc: "panic passed on",
+		f: func(s *Simulation) error {
+			errc := make(chan error, 1)
+			s.Go(func() {
+				defer func() {
+					if r := recover(); r != nil {
+						errc <- r.(error)
+						panic(r)
+					}
+				}()
+				errc <- s.Open("a", NoClose())
+			})
+			return <-errc
+		},
+	}, {
+		desc: "panic not passed on",
+		f: func(s *Simulation) error {
+			done := make(chan error, 1)
+			s.Go(func() {
+				defer close(done)
+				done <- s.Open("a", NoClose())
+			})
+			return <-done
+		},
+		want: []FailureKind{WrongError},
+	}, {
+		desc: "unexpected panic",
+		f: func(s *Simulation) error {
+			s.Go(func() { panic("bug") })
+			return nil
+		},
+		want: []FailureKind{UnexpectedPanic},
+	}, {
+		desc:   "unexpected panic ignoring panic order",
+		config: Relaxed,
+		f: func(s *Simulation) error {
+			s.Go(func() { panic("bug") })
+			return nil
+		},
+		want: []FailureKind{UnexpectedPanic},
+	}, {
+		desc: "failure reported at next step",
+		f: func(s *Simulation) error {
+			ctx := s.Context()
+			s.Go(func() { s.Fatalf("bad") })
+			<-ctx.Done()
+			return s.Open("a", NoClose())
+		},
+		want: []FailureKind{OtherFailure},
+	}, {
+		desc:   "failure reported on return",
+		config: &Config{MaxSteps: 10},
+		f: func(s *Simulation) error {
+			s.Go(func() {
+				for i := 0; ; i++ {
+					s.Open(strconv.Itoa(i), NoError(), NoPanic(), NoClose())
+				}
+			})
+			<-s.Context().Done()
+			return nil
+		},
+		want: []FailureKind{TooManySteps},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []FailureKind
+			for _, f := range RunStandalone(tc.config, tc.f) {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeyOptions(t *testing.T) {
+	config, err := NewConfig(
+		WithKeyOptions("reader", NoPanic()),
+		WithKeyOptions("reader.close", NoError(), NoPanic()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := RunReport(t, config, func(s *Simulation) error {
+		if err := s.Open("reader"); err != nil {
+			return err
+		}
+		return s.Close("reader")
+	})
+	if got := len(r.Scenarios); got != 2 {
+		t.Errorf("got %d scenarios; want 2", got)
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	failures := RunStandalone(&Config{MaxSteps: 10}, func(s *Simulation) error {
+		for i := 0; ; i++ {
+			s.Open(strconv.Itoa(i), NoError(), NoPanic(), NoClose())
+		}
+	})
+	want := []Failure{{Kind: TooManySteps, Message: `exceeded 10 simulation steps at "10"`}}
+	if !reflect.DeepEqual(failures, want) {
+		t.Errorf("got %v; want %v", failures, want)
+	}
+}
+
+func TestRequireChecked(t *testing.T) {
+	config := &Config{RequireChecked: true}
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		want []Failure
+	}{{
+		desc: "discarded",
+		f: func(s *Simulation) (err error) {
+			err = s.Open("o1", NoPanic(), NoClose())
+			if errC := s.Open("o2", NoPanic(), NoClose()); err == nil {
+				err = errC
+			}
+			return err
+		},
+		want: []Failure{{
+			Scenario: 3,
+			Faults:   []Step{{"o1", ModeError}, {"o2", ModeError}},
+			Kind:     Unchecked,
+			Message:  `errors not checked: "o2: Error"`,
+		}},
+	}, {
+		desc: "checked",
+		f: func(s *Simulation) (err error) {
+			err = s.Open("o1", NoPanic(), NoClose())
+			errC := s.Open("o2", NoPanic(), NoClose())
+			s.Checked(errC)
+			if err == nil {
+				err = errC
+			}
+			return err
+		},
+	}, {
+		desc: "ignored",
+		f: func(s *Simulation) (err error) {
+			s.Open("o1", IgnoreError(), NoPanic(), NoClose())
+			return nil
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			failures := RunStandalone(config, tc.f)
+			if !reflect.DeepEqual(failures, tc.want) {
+				t.Errorf("got %v; want %v", failures, tc.want)
+			}
+		})
+	}
+}
+
+func TestErrorPrecedence(t *testing.T) {
+	// f returns the first error it encounters, wrapped if wrap is true.
+	f := func(wrap bool) func(s *Simulation) error {
+		return func(s *Simulation) (err error) {
+			err = s.Open("o1", NoPanic(), NoClose())
+			if errC := s.Open("o2", NoPanic(), NoClose()); err == nil {
+				err = errC
+			}
+			if wrap && err != nil {
+				err = fmt.Errorf("wrapped: %w", err)
+			}
+			return err
+		}
+	}
+	testCases := []struct {
+		desc   string
+		config *Config
+		wrap   bool
+		want   []Failure
+	}{{
+		desc: "first error",
+	}, {
+		desc:   "last error wins",
+		config: &Config{LastErrorWins: true},
+		want: []Failure{{
+			Scenario: 3,
+			Faults:   []Step{{"o1", ModeError}, {"o2", ModeError}},
+			Kind:     WrongError,
+			Message:  "simulation did not return the correct error: got o1: Error; want o2: Error",
+		}},
+	}, {
+		desc: "wrapping not allowed",
+		wrap: true,
+		want: []Failure{{
+			Scenario: 1,
+			Faults:   []Step{{"o2", ModeError}},
+			Kind:     WrongError,
+			Message:  "simulation did not return the correct error: got wrapped: o2: Error; want o2: Error",
+		}, {
+			Scenario: 2,
+			Faults:   []Step{{"o1", ModeError}},
+			Kind:     WrongError,
+			Message:  "simulation did not return the correct error: got wrapped: o1: Error; want o1: Error",
+		}, {
+			Scenario: 3,
+			Faults:   []Step{{"o1", ModeError}, {"o2", ModeError}},
+			Kind:     WrongError,
+			Message:  "simulation did not return the correct error: got wrapped: o1: Error; want o1: Error",
+		}},
+	}, {
+		desc:   "wrapping allowed",
+		config: &Config{AllowWrapping: true},
+		wrap:   true,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			failures := RunStandalone(tc.config, f(tc.wrap))
+			if !reflect.DeepEqual(failures, tc.want) {
+				t.Errorf("got %v; want %v", failures, tc.want)
+			}
+		})
+	}
+}
+
+func TestPriority(t *testing.T) {
+	close := Step{"o1.close", ModeError}
+	body := Step{"o2", ModeError}
+	panicking := Step{"o3", ModePanic}
+	testCases := []struct {
+		p         Priority
+		cur, next Step
+		want      bool
+	}{
+		{FirstError, body, close, false},
+		{FirstError, close, body, false},
+		{FirstError, body, panicking, true},
+		{FirstError, panicking, body, false},
+		{LastError, body, close, true},
+		{LastError, panicking, body, false},
+		{BodyOverClose, close, body, true},
+		{BodyOverClose, body, close, false},
+		{BodyOverClose, panicking, body, false},
+	}
+	for i, tc := range testCases {
+		if got := tc.p(tc.cur, tc.next); got != tc.want {
+			t.Errorf("%d: got %v; want %v", i, got, tc.want)
+		}
+	}
+
+	failures := RunStandalone(&Config{Priority: BodyOverClose}, func(s *Simulation) (err error) {
+		s.Open("o1", NoError(), NoPanic())
+		errC := s.Close("o1", NoPanic())
+		err = s.Open("o2", NoPanic(), NoClose())
+		if err == nil {
+			err = errC
+		}
+		return err
+	})
+	if len(failures) != 0 {
+		t.Errorf("unexpected failures %v", failures)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	config := &Config{
+		OnStep: func(key string, mode Mode) {
+			events = append(events, key+"="+mode.String())
+		},
+		OnScenarioEnd: func(sc Scenario) {
+			events = append(events, fmt.Sprintf("end %d failed=%v", sc.Index, sc.Failed))
+		},
+	}
+	RunStandalone(config, func(s *Simulation) error {
+		s.Open("reader", NoPanic(), NoClose())
+		return nil
+	})
+	want := []string{
+		"reader=NoError",
+		"end 0 failed=false",
+		"reader=Error",
+		"end 1 failed=true",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %q; want %q", events, want)
+	}
+}
+
+func TestExpectFailure(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		errs []string
+	}{{
+		desc: "failing solution",
+		f: func(s *Simulation) error {
+			s.Open("reader", NoPanic(), NoClose())
+			return nil
+		},
+	}, {
+		desc: "correct solution",
+		f: func(s *Simulation) error {
+			return s.Open("reader", NoPanic(), NoClose())
+		},
+		errs: []string{"all 2 scenarios passed; expected at least one failure"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tb := &recordTB{}
+			ExpectFailure(tb, nil, tc.f)
+			if !reflect.DeepEqual(tb.errs, tc.errs) {
+				t.Errorf("ExpectFailure: got %q; want %q", tb.errs, tc.errs)
+			}
+			tb = &recordTB{}
+			Run(tb, &Config{ExpectFailure: true}, tc.f)
+			if !reflect.DeepEqual(tb.errs, tc.errs) {
+				t.Errorf("Run: got %q; want %q", tb.errs, tc.errs)
+			}
+		})
+	}
+}
+
+func TestSkipScenario(t *testing.T) {
+	config := &Config{
+		SkipScenario: func(sc Scenario) bool {
+			for _, st := range sc.Steps {
+				if st.Key == "writer" && st.Mode == ModeError {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	r := RunReport(t, config, func(s *Simulation) error {
+		err := s.Open("reader", NoPanic(), NoClose())
+		if err != nil {
+			return err
+		}
+		s.Open("writer", NoPanic(), NoClose()) // error not returned
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprintf("%v failed=%v skipped=%v", sc.Steps, sc.Failed, sc.Skipped))
+	}
+	want := []string{
+		"[reader=NoError writer=NoError] failed=false skipped=false",
+		"[reader=NoError writer=Error] failed=false skipped=true",
+		"[reader=Error] failed=false skipped=false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestSkipScenarioMethod(t *testing.T) {
+	r := run(nil, nil, func(s *Simulation) error {
+		if err := s.Open("reader", NoPanic(), NoClose()); err != nil {
+			s.SkipScenario("reader errors are retried by the caller")
+		}
+		s.Open("writer", NoPanic(), NoClose()) // error not returned
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprintf("%v skipped=%v %q", sc.Steps, sc.Skipped, sc.SkipReason))
+	}
+	want := []string{
+		`[reader=NoError writer=NoError] skipped=false ""`,
+		`[reader=NoError writer=Error] skipped=false ""`,
+		`[reader=Error] skipped=true "reader errors are retried by the caller"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if r.Failed() != 1 || r.Skipped() != 1 {
+		t.Errorf("got %d failed, %d skipped; want 1 failed, 1 skipped", r.Failed(), r.Skipped())
+	}
+}
+
+func TestTags(t *testing.T) {
+	testCases := []struct {
+		steps []Step
+		want  []string
+	}{{
+		steps: []Step{{"reader", ModeNoError}},
+		want:  []string{"nofault"},
+	}, {
+		steps: []Step{{"reader", ModePanic}},
+		want:  []string{"reader", "panic", "body-panic"},
+	}, {
+		steps: []Step{{"reader", ModeError}, {"writer.close", ModeError}, {"reader.close", ModePanic}},
+		want:  []string{"reader", "error", "body-error", "writer.close", "close-error", "reader.close", "panic", "close-panic"},
+	}}
+	for _, tc := range testCases {
+		sc := Scenario{Steps: tc.steps}
+		if got := sc.Tags(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %q; want %q", tc.steps, got, tc.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := run(nil, &Config{Filter: []string{"Close-Error"}}, func(s *Simulation) (err error) {
+		if err := s.Open("reader", NoPanic()); err != nil {
+			return err
+		}
+		defer func() {
+			if errC := s.Close("reader", NoPanic()); err == nil {
+				err = errC
+			}
+		}()
+		s.Open("copy", NoPanic(), NoClose()) // error not returned
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprintf("%v failed=%v skipped=%v", sc.Steps, sc.Failed, sc.Skipped))
+	}
+	want := []string{
+		"[reader=NoError copy=NoError reader.close=NoError] failed=false skipped=true",
+		"[reader=NoError copy=NoError reader.close=Error] failed=false skipped=false",
+		"[reader=NoError copy=Error reader.close=NoError] failed=false skipped=true",
+		"[reader=NoError copy=Error reader.close=Error] failed=true skipped=false",
+		"[reader=Error] failed=false skipped=true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	// Simulate coverage counters by counting the branches taken.
+	covered := map[string]bool{}
+	config := &Config{
+		Coverage: func() float64 { return float64(len(covered)) / 3 },
+	}
+	r := RunReport(t, config, func(s *Simulation) error {
+		covered["open"] = true
+		if err := s.Open("reader", NoClose()); err != nil {
+			covered["error"] = true
+			return err
+		}
+		return nil
+	})
+	var got []int
+	for _, sc := range r.CoverageGains() {
+		got = append(got, sc.Index)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestCloseOptions(t *testing.T) {
+	r := RunReport(t, nil, func(s *Simulation) (err error) {
+		s.Open("reader", NoError(), NoPanic(), CloseOptions(NoError()))
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		return s.Close("reader")
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Steps))
+	}
+	want := []string{
+		"[reader=NoError reader.close=NoError]",
+		"[reader=NoError reader.close=Panic]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	var got []string
+	Run(t, &Config{RequireChecked: true}, func(s *Simulation) error {
+		err := s.Open("dial", NoPanic(), NetError(true, false), Wrap(io.ErrUnexpectedEOF), NoClose())
+		if err == nil {
+			return nil
+		}
+		var ne net.Error
+		if !errors.As(err, &ne) {
+			t.Fatalf("error %v does not implement net.Error", err)
+		}
+		got = append(got, fmt.Sprint(ne.Timeout(), ne.Temporary(), errors.Is(err, io.ErrUnexpectedEOF)))
+		return err
+	})
+	Run(t, &Config{RequireChecked: true}, func(s *Simulation) error {
+		err := s.Open("read", NoPanic(), Wrap(io.ErrUnexpectedEOF), NoClose())
+		if _, ok := err.(net.Error); ok {
+			t.Errorf("error %v unexpectedly implements net.Error", err)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			got = append(got, "wrapped")
+		}
+		return err
+	})
+	want := []string{"true false true", "wrapped"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestShort(t *testing.T) {
+	r := RunReport(t, &Config{Short: true}, func(s *Simulation) error {
+		for _, key := range []string{"a", "b", "c"} {
+			if err := s.Open(key, NoPanic(), NoClose()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Faults()))
+	}
+	want := []string{"[]", "[c=Error]", "[b=Error]", "[a=Error]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var got []string
+	config := &Config{
+		ProgressInterval: time.Nanosecond,
+		OnProgress: func(done, total int) {
+			got = append(got, fmt.Sprintf("%d/%d", done, total))
+		},
+	}
+	f := func(s *Simulation) error {
+		return s.Open("a", NoPanic(), NoClose())
+	}
+	Run(t, config, f)
+	config.Samples = 2
+	Run(t, config, f)
+	want := []string{"1/0", "2/0", "1/2", "2/2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	tb := &recordTB{TB: t}
+	RunReport(tb, &Config{ProgressInterval: time.Nanosecond, ContinueOnFailure: true}, f)
+	if len(tb.logs) != 2 || tb.logs[1] != "errtest: ran 2 scenarios" {
+		t.Errorf("got logs %q", tb.logs)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	f := func(s *Simulation) error {
+		s.Open("a", NoPanic(), NoClose())
+		s.Open("b", NoPanic(), NoClose())
+		s.Open("c", NoPanic(), NoClose())
+		return nil
+	}
+	tb := &recordTB{TB: t}
+	RunReport(tb, &Config{ContinueOnFailure: true}, f)
+	want := `scenario 1 [c=Error]: simulation did not return the correct error: got <nil>; want c: Error
+2 scenarios failed: simulation did not return the correct error: got <nil>; want b: Error (first: scenario 2 [b=Error])
+4 scenarios failed: simulation did not return the correct error: got <nil>; want a: Error (first: scenario 4 [a=Error])
+`
+	if len(tb.errs) != 1 || !strings.HasPrefix(tb.errs[0], want) {
+		t.Errorf("errors:\ngot  %q\nwant prefix %q", tb.errs, want)
+	}
+
+	tb = &recordTB{TB: t}
+	RunReport(tb, &Config{SkipErrors: true}, f)
+	if got := len(tb.logs); got != 4 {
+		t.Errorf("got %d logs; want 4: %q", got, tb.logs)
+	}
+}
+
+func TestTransferClose(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		want string
+	}{{
+		desc: "close wrapper only",
+		f: func(s *Simulation) error {
+			s.Open("conn", NoError(), NoPanic())
+			s.Open("tls", NoError(), NoPanic(), CloseOptions(NoError(), NoPanic()))
+			s.TransferClose("conn", "tls")
+			return s.Close("tls")
+		},
+	}, {
+		desc: "close inner value",
+		f: func(s *Simulation) error {
+			s.Open("conn", NoError(), NoPanic())
+			s.Open("tls", NoError(), NoPanic(), CloseOptions(NoError(), NoPanic()))
+			s.TransferClose("conn", "tls")
+			s.Close("tls")
+			return s.Close("conn")
+		},
+		want: `"conn" was already closed or should not be closed`,
+	}, {
+		desc: "not open",
+		f: func(s *Simulation) error {
+			s.Open("conn", NoError(), NoPanic())
+			s.TransferClose("conn", "tls")
+			return nil
+		},
+		want: `cannot transfer close of "conn" to "tls": both must be open`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			failures := RunStandalone(nil, tc.f)
+			got := ""
+			if len(failures) > 0 {
+				got = failures[0].Message
+			}
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDetectCollected(t *testing.T) {
+	f := func(s *Simulation) error {
+		s.Open("reader", NoError(), NoPanic())
+		s.Track("reader", new([32]byte))
+		s.Open("op", NoError(), NoClose())
+		return s.Close("reader")
+	}
+	if failures := RunStandalone(nil, f); len(failures) != 0 {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+	failures := RunStandalone(&Config{DetectCollected: true}, f)
+	want := `garbage collected without being closed: "reader"`
+	if len(failures) != 1 || failures[0].Message != want {
+		t.Errorf("got %v; want single failure %q", failures, want)
+	}
+}
+
+// kept holds a tracked value that remains reachable.
+var kept *[32]byte
+
+func TestDetectCollectedReachable(t *testing.T) {
+	failures := RunStandalone(&Config{DetectCollected: true}, func(s *Simulation) error {
+		s.Open("reader", NoError(), NoPanic())
+		kept = new([32]byte)
+		s.Track("reader", kept)
+		return s.Open("op", NoError(), NoClose())
+	})
+	kept = nil
+	// The reader is reported as not closed, but not as collected.
+	if len(failures) != 1 || strings.Contains(failures[0].Message, "garbage collected") {
+		t.Errorf("got %v; want a single failure for not closing the reader", failures)
+	}
+}
+
+func TestStoreKey(t *testing.T) {
+	var keys []string
+	RunStandalone(nil, func(s *Simulation) error {
+		keys = keys[:0]
+		for i := 0; i < 2; i++ {
+			key := "reader"
+			s.Open(key, NoError(), NoPanic(), Iterate(), StoreKey(&key), NoClose())
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if want := []string{"reader", "reader#1"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %q; want %q", keys, want)
+	}
+}
+
+func TestIterate(t *testing.T) {
+	r := RunReport(t, nil, func(s *Simulation) (err error) {
+		for i := 0; i < 2; i++ {
+			if err := s.Open("reader", NoPanic(), Iterate(), CloseOptions(NoError(), NoPanic())); err != nil {
+				return err
+			}
+			defer s.Close("reader")
+		}
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Steps))
+	}
+	want := []string{
+		"[reader=NoError reader#1=NoError reader#1.close=NoError reader.close=NoError]",
+		"[reader=NoError reader#1=Error reader.close=NoError]",
+		"[reader=Error]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	failures := RunStandalone(nil, func(s *Simulation) error {
+		s.Open("reader", NoError(), NoPanic(), NoClose())
+		s.Open("reader", NoError(), NoPanic(), NoClose())
+		return nil
+	})
+	want = []string{`scenario 0 []: statement "reader" was already executed`}
+	if got := fmt.Sprint(failures); got != fmt.Sprint(want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	// connect retries a failed connection once.
+	connect := func(s *Simulation) error {
+		if err := s.Reopen("conn", NoPanic(), IgnoreError(), CloseOptions(NoError(), NoPanic())); err == nil {
+			return s.Close("conn")
+		}
+		if err := s.Reopen("conn", NoPanic(), CloseOptions(NoError(), NoPanic())); err != nil {
+			return err
+		}
+		return s.Close("conn")
+	}
+	r := RunReport(t, nil, connect)
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Steps))
+	}
+	want := []string{
+		"[conn=NoError conn.close=NoError]",
+		"[conn=Error conn#1=NoError conn#1.close=NoError]",
+		"[conn=Error conn#1=Error]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	failures := RunStandalone(nil, func(s *Simulation) error {
+		s.Reopen("conn", NoError(), NoPanic(), CloseOptions(NoError(), NoPanic()))
+		s.Reopen("conn", NoError(), NoPanic(), CloseOptions(NoError(), NoPanic()))
+		return nil
+	})
+	if len(failures) != 1 || failures[0].Kind != Leak {
+		t.Errorf("reopened while open: got %v; want a Leak", failures)
+	}
+}
+
+func TestOp(t *testing.T) {
+	var msgs []string
+	r := run(nil, nil, func(s *Simulation) error {
+		if err := s.Open("writer", NoPanic(), CloseOptions(NoError(), NoPanic())); err != nil {
+			return err
+		}
+		defer s.Close("writer")
+		for i := 0; i < 2; i++ {
+			if err := s.Op("writer", "write", NoPanic()); err != nil {
+				msgs = append(msgs, s.quote(s.exec[len(s.exec)-1].key))
+				return nil // error not returned
+			}
+		}
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Steps))
+	}
+	want := []string{
+		"[writer=NoError writer.write=NoError writer.write#1=NoError writer.close=NoError]",
+		"[writer=NoError writer.write=NoError writer.write#1=Error writer.close=NoError]",
+		"[writer=NoError writer.write=Error writer.close=NoError]",
+		"[writer=Error]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	wantMsgs := []string{
+		`"writer.write#1" (write on "writer")`,
+		`"writer.write" (write on "writer")`,
+	}
+	if !reflect.DeepEqual(msgs, wantMsgs) {
+		t.Errorf("got descriptions %q; want %q", msgs, wantMsgs)
+	}
+
+	failures := RunStandalone(nil, func(s *Simulation) error {
+		return s.Op("reader", "read")
+	})
+	if len(failures) != 1 || failures[0].Kind != Misuse {
+		t.Errorf("operation on unopened value: got %v; want a Misuse", failures)
+	}
+}
+
+func TestFaultStats(t *testing.T) {
+	r := RunReport(&recordTB{TB: t}, &Config{ContinueOnFailure: true}, func(s *Simulation) error {
+		err := s.Open("a", NoPanic(), NoClose())
+		s.Open("b", NoPanic(), NoClose())
+		return err
+	})
+	want := []FaultStat{
+		{Step: Step{Key: "b", Mode: ModeError}, Total: 2, Failed: 1},
+		{Step: Step{Key: "a", Mode: ModeError}, Total: 2, Failed: 0},
+	}
+	if got := r.FaultStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v; want %+v", got, want)
+	}
+	if got, want := r.Summary(), "all failures involve b=Error"; !strings.HasSuffix(got, want) {
+		t.Errorf("got %q; want suffix %q", got, want)
+	}
+}
+
+func TestMustReach(t *testing.T) {
+	failures := RunStandalone(nil, func(s *Simulation) error {
+		s.MustReach("flush", "sync")
+		if err := s.Open("write", NoPanic(), NoClose()); err != nil {
+			return err
+		}
+		s.Checkpoint("flush")
+		return nil
+	})
+	want := `scenario 0 []: not reached: "sync"`
+	if len(failures) != 1 || failures[0].String() != want {
+		t.Errorf("got %v; want [%s]", failures, want)
+	}
+}
+
+func TestCurrentMode(t *testing.T) {
+	var got []string
+	Run(t, nil, func(s *Simulation) error {
+		if _, ok := s.CurrentMode("reader"); ok {
+			t.Errorf("mode of reader reported before it was opened")
+		}
+		defer func() {
+			m, _ := s.CurrentMode("reader")
+			got = append(got, fmt.Sprint(m, s.Scenario().Steps))
+		}()
+		return s.Open("reader", NoClose())
+	})
+	want := []string{"NoError [reader=NoError]", "Error [reader=Error]", "Panic [reader=Panic]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestAbortFirst(t *testing.T) {
+	opts := []Option{NoError(), NoPanic(), CloseOptions(NoError(), NoPanic())}
+	testCases := []struct {
+		desc  string
+		abort func(s *Simulation, err error)
+		want  []FailureKind
+	}{{
+		desc: "aborted first",
+		abort: func(s *Simulation, err error) {
+			s.CloseWithError("tx", err)
+			s.Close("part")
+		},
+		want: []FailureKind{NoFailure, NoFailure},
+	}, {
+		desc: "closed in reverse order",
+		abort: func(s *Simulation, err error) {
+			s.Close("part")
+			s.CloseWithError("tx", err)
+		},
+		want: []FailureKind{NoFailure, WrongCloseOrder},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := run(nil, &Config{ContinueOnFailure: true}, func(s *Simulation) error {
+				s.Open("tx", append(opts, AbortFirst())...)
+				s.Open("part", opts...)
+				err := s.Open("write", NoPanic(), NoClose())
+				if err != nil {
+					tc.abort(s, err)
+					return err
+				}
+				// On success, the part is closed before the commit.
+				s.Close("part")
+				return s.CloseWithError("tx", nil)
+			})
+			var got []FailureKind
+			for _, sc := range r.Scenarios {
+				got = append(got, sc.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUseAfterClose(t *testing.T) {
+	opts := []Option{NoPanic(), CloseOptions(NoError(), NoPanic())}
+	r := run(nil, &Config{ContinueOnFailure: true}, func(s *Simulation) error {
+		// The writer is created regardless of the error of the client.
+		if s.Open("client", append(opts, IgnoreError())...) == nil {
+			defer s.Close("client")
+		}
+		s.Open("writer", NoError(), NoPanic(), NoClose(), Requires("client"))
+		return nil
+	})
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprintf("%v: %s", sc.Kind, sc.Message))
+	}
+	want := []string{
+		"NoFailure: ",
+		`UseAfterClose: "client" used after it failed to open`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestOpAfterFailedOpen(t *testing.T) {
+	r := run(nil, &Config{ContinueOnFailure: true}, func(s *Simulation) error {
+		err := s.Open("writer", NoPanic(), NoClose())
+		s.Op("writer", "write", NoError(), NoPanic())
+		return err
+	})
+	var got []FailureKind
+	for _, sc := range r.Scenarios {
+		got = append(got, sc.Kind)
+	}
+	if want := []FailureKind{NoFailure, UseAfterClose}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestFailureKind(t *testing.T) {
+	opts := []Option{NoError(), NoPanic(), CloseOptions(NoError(), NoPanic())}
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		want FailureKind
+	}{{
+		desc: "pass",
+		f:    func(s *Simulation) error { return nil },
+		want: NoFailure,
+	}, {
+		desc: "fatal",
+		f: func(s *Simulation) error {
+			s.Fatalf("bad")
+			return nil
+		},
+		want: OtherFailure,
+	}, {
+		desc: "leak",
+		f: func(s *Simulation) error {
+			return s.Open("a", opts...)
+		},
+		want: Leak,
+	}, {
+		desc: "double close",
+		f: func(s *Simulation) error {
+			s.Open("a", opts...)
+			s.Close("a")
+			return s.Close("a")
+		},
+		want: DoubleClose,
+	}, {
+		desc: "close order",
+		f: func(s *Simulation) error {
+			s.Open("a", opts...)
+			s.Open("b", opts...)
+			s.Close("a")
+			return s.Close("b")
+		},
+		want: WrongCloseOrder,
+	}, {
+		desc: "duplicate",
+		f: func(s *Simulation) error {
+			s.Checkpoint("a")
+			s.Checkpoint("a")
+			return nil
+		},
+		want: DuplicateStep,
+	}, {
+		desc: "not owned",
+		f: func(s *Simulation) error {
+			s.Open("w", NoError(), NoPanic(), MustNotClose())
+			s.Open("a", opts...)
+			defer s.Close("a")
+			return s.Close("w")
+		},
+		want: NotOwned,
+	}, {
+		desc: "not owned and not closed",
+		f: func(s *Simulation) error {
+			return s.Open("w", NoError(), NoPanic(), MustNotClose())
+		},
+		want: NoFailure,
+	}, {
+		desc: "use after close",
+		f: func(s *Simulation) error {
+			s.Open("client", opts...)
+			s.Close("client")
+			s.Open("writer", append(opts, Requires("client"))...)
+			return s.Close("writer")
+		},
+		want: UseAfterClose,
+	}, {
+		desc: "described use after close",
+		f: func(s *Simulation) error {
+			s.Open("client", opts...)
+			s.Close("client")
+			s.Open("writer", append(opts, RequiresDesc("client", "writer created from closed client"))...)
+			return s.Close("writer")
+		},
+		want: UseAfterClose,
+	}, {
+		desc: "operation after close",
+		f: func(s *Simulation) error {
+			s.Open("writer", opts...)
+			s.Close("writer")
+			return s.Op("writer", "write", NoError(), NoPanic())
+		},
+		want: UseAfterClose,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := RunReport(&recordTB{TB: t}, &Config{ContinueOnFailure: true}, tc.f)
+			if got := r.Scenarios[0].Kind; got != tc.want {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAllowBranching(t *testing.T) {
+	// The primary is unavailable in the second and third scenario only.
+	f := func(s *Simulation) error {
+		key := "primary"
+		if s.scenario == 1 || s.scenario == 2 {
+			key = "fallback"
+		}
+		return s.Open(key, NoPanic(), NoClose())
+	}
+	failures := RunStandalone(nil, f)
+	want := `scenario 1 []: non-deterministic simulation at "fallback"`
+	if len(failures) != 1 || failures[0].String() != want {
+		t.Errorf("got %v; want [%s]", failures, want)
+	}
+
+	r := RunReport(t, &Config{AllowBranching: true}, f)
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Steps))
+	}
+	wantSteps := []string{"[primary=NoError]", "[fallback=NoError]", "[fallback=Error]", "[primary=Error]"}
+	if !reflect.DeepEqual(got, wantSteps) {
+		t.Errorf("got %q; want %q", got, wantSteps)
+	}
+}
+
+func TestAllowBranchingNeverTakenAgain(t *testing.T) {
+	// The set-aside primary=Error is tried once and then given up.
+	f := func(s *Simulation) error {
+		key := "primary"
+		if s.scenario > 0 {
+			key = "fallback"
+		}
+		return s.Open(key, NoPanic(), NoClose())
+	}
+	r := RunReport(t, &Config{AllowBranching: true}, f)
+	var got []string
+	for _, sc := range r.Scenarios {
+		got = append(got, fmt.Sprint(sc.Steps))
+	}
+	want := []string{"[primary=NoError]", "[fallback=NoError]", "[fallback=Error]", "[fallback=NoError]", "[fallback=Error]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestMaxFaults(t *testing.T) {
+	f := func(s *Simulation) error {
+		for _, key := range []string{"a", "b", "c"} {
+			if err := s.Open(key, NoPanic(), NoError(), CloseOptions(NoPanic(), IgnoreError())); err != nil {
+				return err
+			}
+			defer s.Close(key)
+		}
+		return nil
+	}
+	for _, tc := range []struct {
+		config *Config
+		want   int
+	}{
+		{nil, 8},
+		{&Config{Short: true}, 4},
+		{&Config{Short: true, MaxFaults: 2}, 7},
+		{&Config{MaxFaults: 3}, 8},
+	} {
+		r := RunReport(t, tc.config, f)
+		if got := len(r.Scenarios); got != tc.want {
+			t.Errorf("%+v: got %d scenarios; want %d", tc.config, got, tc.want)
+		}
+	}
+}
+
+func TestDescriptions(t *testing.T) {
+	f := func(s *Simulation) error {
+		s.Open("reader", NoError(), NoPanic(), Describe("the reader"))
+		s.Open("writer", NoError(), NoPanic())
+		return nil
+	}
+	failures := RunStandalone(nil, f)
+	want := `not closed: "reader" (the reader), "writer"`
+	if len(failures) != 1 || failures[0].Message != want {
+		t.Errorf("got %v; want %q", failures, want)
+	}
+	config := &Config{Descriptions: map[string]string{
+		"reader": "the input",
+		"writer": "the output",
+	}}
+	failures = RunStandalone(config, f)
+	want = `not closed: "reader" (the input), "writer" (the output)`
+	if len(failures) != 1 || failures[0].Message != want {
+		t.Errorf("got %v; want %q", failures, want)
+	}
+}
+
+func TestPartial(t *testing.T) {
+	opts := []Option{Partial(), NoPanic(), CloseOptions(NoError(), NoPanic())}
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		want []FailureKind
+	}{{
+		desc: "closed and not used",
+		f: func(s *Simulation) error {
+			err := s.Open("resp", opts...)
+			defer s.Close("resp")
+			if err != nil {
+				return err
+			}
+			s.Use("resp")
+			return nil
+		},
+	}, {
+		desc: "not closed",
+		f: func(s *Simulation) error {
+			if err := s.Open("resp", opts...); err != nil {
+				return err
+			}
+			defer s.Close("resp")
+			s.Use("resp")
+			return nil
+		},
+		want: []FailureKind{Leak},
+	}, {
+		desc: "used",
+		f: func(s *Simulation) error {
+			err := s.Open("resp", opts...)
+			defer s.Close("resp")
+			s.Use("resp")
+			return err
+		},
+		want: []FailureKind{UsedPartial},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []FailureKind
+			for _, f := range RunStandalone(nil, tc.f) {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
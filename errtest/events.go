@@ -0,0 +1,131 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An EventKind classifies the events passed to Config.OnEvent.
+type EventKind int
+
+// Kinds of events, in the order in which they may occur in a scenario.
+const (
+	EventScenarioStart EventKind = iota // a scenario starts
+	EventOpen                           // a step other than a close succeeds
+	EventClose                          // the close of a value succeeds
+	EventFault                          // an error or panic is simulated for a step
+	EventFailure                        // a failure is reported for the scenario
+	EventScenarioEnd                    // a scenario completes
+)
+
+var eventKindNames = []string{
+	EventScenarioStart: "ScenarioStart",
+	EventOpen:          "Open",
+	EventClose:         "Close",
+	EventFault:         "Fault",
+	EventFailure:       "Failure",
+	EventScenarioEnd:   "ScenarioEnd",
+}
+
+func (k EventKind) String() string {
+	if k < 0 || int(k) >= len(eventKindNames) {
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+	return eventKindNames[k]
+}
+
+// An Event describes a change in the state of a simulation, as passed to
+// Config.OnEvent.
+type Event struct {
+	Kind EventKind
+
+	// Scenario is the index of the scenario in which the event occurred.
+	Scenario int
+
+	// Key and Mode describe the step of an EventOpen, EventClose, or
+	// EventFault. The key of a close ends in ".close".
+	Key  string
+	Mode Mode
+
+	// Failure and Message describe the failure of an EventFailure, or the
+	// first failure of an EventScenarioEnd of a failed scenario.
+	Failure FailureKind
+	Message string
+
+	// Skipped reports whether the scenario of an EventScenarioEnd was
+	// skipped.
+	Skipped bool
+
+	// outcome is the scenario of an EventScenarioEnd.
+	outcome *Scenario
+}
+
+// emit dispatches e to the hooks of the configuration. The hooks other than
+// OnEvent are served from the events as well, so that new kinds of events
+// only need to be handled here.
+func (s *Simulation) emit(e Event) {
+	if s.config == nil {
+		return
+	}
+	if e.outcome != nil {
+		e.Scenario = e.outcome.Index
+	} else {
+		e.Scenario = s.scenario
+	}
+	s.onStep(e)
+	s.logEvent(e)
+	s.onScenarioEnd(e)
+	s.onEvent(e)
+}
+
+// emitStep emits the event for the execution of a step with the given mode.
+func (s *Simulation) emitStep(key string, mode Mode) {
+	kind := EventOpen
+	switch {
+	case mode != ModeNoError:
+		kind = EventFault
+	case strings.HasSuffix(key, ".close"):
+		kind = EventClose
+	}
+	s.emit(Event{Kind: kind, Key: key, Mode: mode})
+}
+
+// emitScenarioEnd emits the event for the end of the scenario sc. It is
+// called once the simulation has moved past sc, so that the hooks observe the
+// scenario count including sc.
+func (s *Simulation) emitScenarioEnd(sc Scenario) {
+	e := Event{Kind: EventScenarioEnd, Skipped: sc.Skipped, outcome: &sc}
+	if sc.Failed {
+		e.Failure, e.Message = sc.Kind, sc.Message
+	}
+	s.emit(e)
+}
+
+// onStep passes the steps of e to Config.OnStep, if set.
+func (s *Simulation) onStep(e Event) {
+	if s.config.OnStep == nil {
+		return
+	}
+	switch e.Kind {
+	case EventOpen, EventClose, EventFault:
+		s.config.OnStep(e.Key, e.Mode)
+	}
+}
+
+// onScenarioEnd passes the outcome of e to Config.OnScenarioEnd, if set.
+func (s *Simulation) onScenarioEnd(e Event) {
+	if s.config.OnScenarioEnd != nil && e.Kind == EventScenarioEnd {
+		s.config.OnScenarioEnd(*e.outcome)
+	}
+}
+
+// onEvent passes e to Config.OnEvent, if set.
+func (s *Simulation) onEvent(e Event) {
+	if s.config.OnEvent != nil {
+		s.config.OnEvent(e)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestOnEvent(t *testing.T) {
+	var got []string
+	config := &Config{
+		OnEvent: func(e Event) {
+			s := fmt.Sprintf("%d %v", e.Scenario, e.Kind)
+			switch e.Kind {
+			case EventOpen, EventClose, EventFault:
+				s += fmt.Sprintf(" %s=%v", e.Key, e.Mode)
+			case EventFailure, EventScenarioEnd:
+				if e.Failure != NoFailure {
+					s += fmt.Sprintf(" %v", e.Failure)
+				}
+			}
+			got = append(got, s)
+		},
+	}
+	RunStandalone(config, func(s *Simulation) error {
+		if err := s.Open("reader", NoPanic(), CloseOptions(NoError(), NoPanic())); err != nil {
+			return nil // error not returned
+		}
+		return s.Close("reader")
+	})
+	want := []string{
+		"0 ScenarioStart",
+		"0 Open reader=NoError",
+		"0 Close reader.close=NoError",
+		"0 ScenarioEnd",
+		"1 ScenarioStart",
+		"1 Fault reader=Error",
+		"1 Failure WrongError",
+		"1 ScenarioEnd WrongError",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestKindString(t *testing.T) {
+	for _, tc := range []struct {
+		k    fmt.Stringer
+		want string
+	}{
+		{EventScenarioEnd, "ScenarioEnd"},
+		{EventKind(-1), "EventKind(-1)"},
+		{UseAfterClose, "UseAfterClose"},
+		{FailureKind(100), "FailureKind(100)"},
+	} {
+		if got := tc.k.String(); got != tc.want {
+			t.Errorf("got %q; want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// A collector records the keys of tracked values that were garbage collected.
+type collector struct {
+	mu        sync.Mutex
+	collected map[int][]string // keys by scenario
+}
+
+// Track registers v, which must be a pointer to an allocated object, as the
+// value opened with key. If Config.DetectCollected is set, a scenario fails if
+// key was opened and not closed, and v is unreachable at the end of the
+// scenario. This detects values that were dropped without being closed in
+// scenarios that panicked, for which the bookkeeping of Open and Close does
+// not require closes unless Config.RequireCloseOnPanic is set. Values that
+// are still reachable, for instance from a goroutine that is still running,
+// are not reported; see Config.GoroutineGrace for detecting such goroutines.
+func (s *Simulation) Track(key string, v interface{}) {
+	if s.config == nil || !s.config.DetectCollected {
+		return
+	}
+	c := &s.collector
+	scenario := s.scenario
+	runtime.SetFinalizer(v, func(interface{}) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.collected == nil {
+			c.collected = map[int][]string{}
+		}
+		c.collected[scenario] = append(c.collected[scenario], key)
+	})
+}
+
+// collectedUnclosed returns the quoted keys of the values tracked in the
+// current scenario that were opened and not closed, and that are unreachable
+// at the end of the scenario, as determined by a garbage collection.
+func (s *Simulation) collectedUnclosed() (keys []string) {
+	c := &s.collector
+	collectGarbage(func() int {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.collected[s.scenario])
+	})
+
+	c.mu.Lock()
+	collected := c.collected[s.scenario]
+	delete(c.collected, s.scenario)
+	c.mu.Unlock()
+
+	open := map[string]bool{}
+	for _, f := range s.exec {
+		if !f.noClose {
+			open[f.key] = true
+		}
+	}
+	for _, key := range collected {
+		if open[key] {
+			keys = append(keys, s.quote(key))
+		}
+	}
+	return keys
+}
+
+// collectTimeout bounds the time spent by collectGarbage.
+const collectTimeout = time.Second
+
+// collectGarbage runs garbage collections until the number of collected values
+// reported by count no longer changes, or collectTimeout passes. Finalizers
+// run in a separate goroutine, so each collection waits for a sentinel
+// finalizer queued after those of the values it found unreachable.
+func collectGarbage(count func() int) {
+	deadline := time.After(collectTimeout)
+	for prev := -1; ; {
+		done := make(chan struct{})
+		runtime.SetFinalizer(new([32]byte), func(*[32]byte) { close(done) })
+		runtime.GC()
+		select {
+		case <-done:
+		case <-deadline:
+			return
+		}
+		n := count()
+		if n == prev {
+			return
+		}
+		prev = n
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !playground
+
+package errtest
+
+import (
+	"flag"
+	"strings"
+)
+
+// RegisterFlags defines the flags that control how dares are run in fs and
+// returns a function that creates a Config from their values. The function
+// must be called after fs is parsed. Test packages typically call
+//
+//	var config = errtest.RegisterFlags(flag.CommandLine)
+//
+// at the package level and pass config() to Run.
+//
+// The flags are:
+//
+//	-panic_order  require the first panic to be passed to an error referenced
+//	              in a defer; the inverse of Config.IgnorePanicOrder
+//	-panic_close  require closes to be called in case of panic, as with
+//	              Config.RequireCloseOnPanic
+//	-pedantic     use Pedantic; overrides -panic_order and -panic_close
+//	-errtest.filter=TAGS
+//	              report only scenarios with any of the comma-separated tags,
+//	              such as panic or close-error, as with Config.Filter
+//	-errtest.golden=DIR
+//	              compare scenario outcomes to the golden files in DIR, as
+//	              with Config.GoldenDir
+//	-errtest.update
+//	              rewrite the golden files instead of comparing to them
+//	-errtest.budget=DURATION
+//	              stop starting new scenarios of a simulation after DURATION,
+//	              as with Config.Budget
+//	-errtest.artifacts=DIR
+//	              write a reproduction of each failed scenario to a new
+//	              directory in DIR, as with Config.ArtifactsDir
+//	-errtest.replay=FILE
+//	              run only the scenario reproduced in FILE, as written with
+//	              -errtest.artifacts; see Config.Replay
+func RegisterFlags(fs *flag.FlagSet) func() *Config {
+	panicOrder := fs.Bool("panic_order", false,
+		"require the first panic to be passed to an error referenced in defer")
+	closeOnPanic := fs.Bool("panic_close", false,
+		"require closes to be called in case of panic")
+	pedantic := fs.Bool("pedantic", false,
+		"strictest interpretation; overrides all other flags except wrapping")
+	filter := fs.String("errtest.filter", "",
+		"comma-separated tags, such as panic or close-error; only scenarios with any of the tags are reported")
+	golden := fs.String("errtest.golden", "",
+		"directory of golden files recording scenario outcomes")
+	update := fs.Bool("errtest.update", false,
+		"rewrite the golden files in the -errtest.golden directory")
+	budget := fs.Duration("errtest.budget", 0,
+		"maximum wall-clock time spent on the scenarios of a simulation")
+	artifacts := fs.String("errtest.artifacts", "",
+		"directory in which reproductions of failed scenarios are written")
+	replay := fs.String("errtest.replay", "",
+		"reproduction of a failed scenario to run instead of all scenarios")
+	return func() *Config {
+		c := &Config{
+			RequireCloseOnPanic: *closeOnPanic,
+			IgnorePanicOrder:    !*panicOrder,
+		}
+		if *pedantic {
+			*c = *Pedantic
+		}
+		if *filter != "" {
+			c.Filter = strings.Split(*filter, ",")
+		}
+		c.GoldenDir = *golden
+		c.UpdateGolden = *update
+		c.Budget = *budget
+		c.ArtifactsDir = *artifacts
+		c.Replay = *replay
+		return c
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	testCases := []struct {
+		args []string
+		want Config
+	}{{
+		args: nil,
+		want: Config{IgnorePanicOrder: true},
+	}, {
+		args: []string{"-panic_order", "-panic_close"},
+		want: Config{RequireCloseOnPanic: true},
+	}, {
+		args: []string{"-pedantic"},
+		want: *Pedantic,
+	}, {
+		args: []string{"-errtest.filter=panic,close-error"},
+		want: Config{IgnorePanicOrder: true, Filter: []string{"panic", "close-error"}},
+	}, {
+		args: []string{"-errtest.golden=testdata", "-errtest.update"},
+		want: Config{IgnorePanicOrder: true, GoldenDir: "testdata", UpdateGolden: true},
+	}, {
+		args: []string{"-errtest.budget=1m"},
+		want: Config{IgnorePanicOrder: true, Budget: time.Minute},
+	}, {
+		args: []string{"-errtest.artifacts=artifacts"},
+		want: Config{IgnorePanicOrder: true, ArtifactsDir: "artifacts"},
+	}, {
+		args: []string{"-errtest.replay=scenario-1.json"},
+		want: Config{IgnorePanicOrder: true, Replay: "scenario-1.json"},
+	}}
+	for _, tc := range testCases {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		config := RegisterFlags(fs)
+		if err := fs.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if got := config(); !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("%v: got %+v; want %+v", tc.args, *got, tc.want)
+		}
+	}
+}
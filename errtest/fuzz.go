@@ -0,0 +1,26 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !playground
+
+package errtest
+
+import "testing"
+
+// Fuzz adds a fuzz target to f that runs a single scenario of fn for each
+// input. Each byte of the input selects the mode of the next step that is
+// encountered, so that go test -fuzz can explore combinations of faults and
+// minimize failing inputs. Steps beyond the end of the input succeed.
+func Fuzz(f *testing.F, config *Config, fn func(s *Simulation) error) {
+	f.Add([]byte{})
+	f.Add([]byte{1})
+	f.Add([]byte{2})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sim := &Simulation{
+			config: config,
+			choose: byteChooser(data),
+		}
+		sim.runScenario(t, fn)
+	})
+}
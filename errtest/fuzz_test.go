@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func FuzzSimulation(f *testing.F) {
+	f.Add([]byte{0, 1})
+	f.Add([]byte{0, 0, 2})
+	Fuzz(f, nil, func(s *Simulation) (err error) {
+		if err := s.Open("reader"); err != nil {
+			return err
+		}
+		defer func() {
+			if errC := s.Close("reader"); err == nil {
+				err = errC
+			}
+		}()
+		return s.Open("copy", NoClose())
+	})
+}
+
+func TestByteChooser(t *testing.T) {
+	choose := byteChooser([]byte{4, 1})
+	for i, want := range []int{1, 1, 0} {
+		if got := choose(make([]float64, 3)); got != want {
+			t.Errorf("%d: got %d; want %d", i, got, want)
+		}
+	}
+}
+
+func TestWeightedChooser(t *testing.T) {
+	choose := weightedChooser(rand.New(rand.NewSource(1)))
+	counts := make([]int, 3)
+	for i := 0; i < 1000; i++ {
+		counts[choose([]float64{0, 1, 3})]++
+	}
+	if counts[0] != 0 || counts[1] == 0 || counts[2] < 2*counts[1] {
+		t.Errorf("unexpected distribution %v", counts)
+	}
+}
+
+func TestSamples(t *testing.T) {
+	config := &Config{Samples: 20, Seed: 1}
+	r := RunReport(t, config, func(s *Simulation) error {
+		return s.Open("reader", NoClose(), Weights(1, 1, 0))
+	})
+	if got := len(r.Scenarios); got != 20 {
+		t.Fatalf("got %d scenarios; want 20", got)
+	}
+	seen := map[Mode]bool{}
+	for _, sc := range r.Scenarios {
+		seen[sc.Steps[0].Mode] = true
+	}
+	if !seen[ModeNoError] || !seen[ModeError] || seen[ModePanic] {
+		t.Errorf("unexpected modes %v", seen)
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	var got [][]float64
+	sc := RunScenario(nil, func(weights []float64) int {
+		got = append(got, weights)
+		return 1
+	}, func(s *Simulation) error {
+		s.Open("reader", NoClose(), Weights(1, 2, 0))
+		return nil
+	})
+	want := [][]float64{{1, 2, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("weights: got %v; want %v", got, want)
+	}
+	if !sc.Failed || sc.Kind != WrongError {
+		t.Errorf("got failed=%v, kind %v; want a failure of kind %v", sc.Failed, sc.Kind, WrongError)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const goldenHeader = "# errtest golden file: scenario outcomes; regenerate with -errtest.update\n"
+
+// goldenFile returns the name of the golden file of the test with the given
+// name in dir. Subtests are stored in subdirectories.
+func goldenFile(dir, name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9',
+			r == '-', r == '_', r == '.', r == '/':
+			return r
+		}
+		return '_'
+	}, name)
+	return filepath.Join(dir, filepath.FromSlash(name)+".golden")
+}
+
+// outcome describes the outcome of sc in a form that is stable across runs
+// and releases: the scenario is identified by its steps and their modes, and
+// only the kind of failure is included, not the failure message.
+func outcome(sc *Scenario) (id, result string) {
+	var steps []string
+	for _, st := range sc.Steps {
+		steps = append(steps, st.String())
+	}
+	id = strings.Join(steps, " ")
+	if id == "" {
+		id = "(no steps)"
+	}
+	if sc.Schedule > 0 {
+		id = fmt.Sprintf("schedule %d: %s", sc.Schedule, id)
+	}
+	switch {
+	case sc.Failed:
+		result = "fail " + sc.Kind.String()
+	case sc.Skipped:
+		result = "skip"
+	default:
+		result = "pass"
+	}
+	return id, result
+}
+
+// formatGolden returns the contents of the golden file for r.
+func formatGolden(r *Results) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(goldenHeader)
+	for i := range r.Scenarios {
+		id, result := outcome(&r.Scenarios[i])
+		fmt.Fprintf(b, "%s: %s\n", id, result)
+	}
+	return b.Bytes()
+}
+
+// parseGolden parses the contents of a golden file, returning the scenario
+// IDs in order and their outcomes. It returns an error if a scenario is
+// listed more than once.
+func parseGolden(b []byte) (ids []string, outcomes map[string]string, err error) {
+	outcomes = map[string]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, ": ")
+		if i < 0 {
+			continue
+		}
+		id := line[:i]
+		if _, ok := outcomes[id]; ok {
+			return nil, nil, fmt.Errorf("scenario %s is listed more than once", id)
+		}
+		ids = append(ids, id)
+		outcomes[id] = line[i+len(": "):]
+	}
+	return ids, outcomes, nil
+}
+
+// diffGolden reports how the outcomes in got differ from those in want, or
+// returns the empty string if they are the same. If truncated is set, got
+// lacks the scenarios that were not run, which are then not reported.
+func diffGolden(got, want []byte, truncated bool) (string, error) {
+	gotIDs, gotOutcomes, err := parseGolden(got)
+	if err != nil {
+		return "", err
+	}
+	wantIDs, wantOutcomes, err := parseGolden(want)
+	if err != nil {
+		return "", err
+	}
+	b := &strings.Builder{}
+	for _, id := range wantIDs {
+		w := wantOutcomes[id]
+		switch g, ok := gotOutcomes[id]; {
+		case !ok && truncated:
+		case !ok:
+			fmt.Fprintf(b, "\n\t%s: %s; no longer run", id, w)
+		case g != w:
+			fmt.Fprintf(b, "\n\t%s: %s; want %s", id, g, w)
+		}
+	}
+	for _, id := range gotIDs {
+		if _, ok := wantOutcomes[id]; !ok {
+			fmt.Fprintf(b, "\n\t%s: %s; new scenario", id, gotOutcomes[id])
+		}
+	}
+	return b.String(), nil
+}
+
+// checkGolden writes the outcomes of r to the golden file of the test run by
+// t if config.UpdateGolden is set, or otherwise compares them to the outcomes
+// recorded in that file. It returns an error if the outcomes differ or the
+// file could not be read or written. It does not update the file if not all
+// scenarios were run.
+func checkGolden(t reporter, config *Config, r *Results) error {
+	if config == nil || config.GoldenDir == "" {
+		return nil
+	}
+	file := goldenFile(config.GoldenDir, t.Name())
+	got := formatGolden(r)
+	if _, _, err := parseGolden(got); err != nil {
+		return fmt.Errorf("cannot compare outcomes to golden file %s: %v", file, err)
+	}
+	if config.UpdateGolden {
+		if r.Truncated != nil {
+			return fmt.Errorf("not updating golden file %s: %s", file, r.Truncated)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(file, got, 0o644)
+	}
+	want, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("golden file %s does not exist; run with -errtest.update to create it", file)
+	}
+	if err != nil {
+		return err
+	}
+	d, err := diffGolden(got, want, r.Truncated != nil)
+	if err != nil {
+		return fmt.Errorf("golden file %s: %v", file, err)
+	}
+	if d != "" {
+		return fmt.Errorf("scenario outcomes differ from golden file %s:%s", file, d)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// namedTB is a recordTB with a name, which identifies its golden file.
+type namedTB struct{ *recordTB }
+
+func (namedTB) Name() string { return "TestGolden/reader" }
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	ignore := func(s *Simulation) error {
+		s.Open("reader", NoPanic(), NoClose())
+		return nil
+	}
+	check := func(s *Simulation) error {
+		return s.Open("reader", NoPanic(), NoClose())
+	}
+	golden := func(update bool, f func(s *Simulation) error) []string {
+		tb := namedTB{&recordTB{}}
+		RunReport(tb, (&Config{}).With(WithGolden(dir, update)), f)
+		var errs []string
+		for _, e := range tb.errs {
+			if strings.Contains(e, "golden") {
+				errs = append(errs, e)
+			}
+		}
+		return errs
+	}
+
+	if errs := golden(false, ignore); len(errs) != 1 || !strings.Contains(errs[0], "does not exist") {
+		t.Errorf("missing file: got %q; want error about missing golden file", errs)
+	}
+
+	if errs := golden(true, ignore); len(errs) > 0 {
+		t.Fatalf("update: unexpected errors %q", errs)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "TestGolden", "reader.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := goldenHeader + "reader=NoError: pass\nreader=Error: fail WrongError\n"
+	if got := string(b); got != want {
+		t.Errorf("golden file: got\n%s\nwant\n%s", got, want)
+	}
+
+	if errs := golden(false, ignore); len(errs) > 0 {
+		t.Errorf("same outcomes: unexpected errors %q", errs)
+	}
+	errs := golden(false, check)
+	if len(errs) != 1 || !strings.Contains(errs[0], "reader=Error: pass; want fail WrongError") {
+		t.Errorf("changed outcomes: got %q; want the changed outcome of reader=Error", errs)
+	}
+}
+
+func TestDiffGolden(t *testing.T) {
+	want := []byte(goldenHeader + "a=Error: pass\nb=Panic: skip\n")
+	got := []byte(goldenHeader + "b=Panic: skip\nc=Error: fail Leak\n")
+	d, err := diffGolden(got, want, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"a=Error: pass; no longer run", "c=Error: fail Leak; new scenario"} {
+		if !strings.Contains(d, s) {
+			t.Errorf("diff %q does not contain %q", d, s)
+		}
+	}
+	if strings.Contains(d, "b=Panic") {
+		t.Errorf("diff %q reports unchanged scenario b=Panic", d)
+	}
+}
+
+func TestDiffGoldenTruncated(t *testing.T) {
+	want := []byte(goldenHeader + "a=Error: pass\nb=Panic: skip\n")
+	got := []byte(goldenHeader + "a=Error: fail Leak\n")
+	d, err := diffGolden(got, want, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n\ta=Error: fail Leak; want pass"; d != want {
+		t.Errorf("got diff %q; want %q", d, want)
+	}
+}
+
+func TestDiffGoldenDuplicate(t *testing.T) {
+	want := []byte(goldenHeader + "a=Error: pass\na=Error: fail Leak\n")
+	got := []byte(goldenHeader + "a=Error: pass\n")
+	if _, err := diffGolden(got, want, false); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("got error %v; want error about duplicate scenario", err)
+	}
+}
+
+func TestOutcomeSteps(t *testing.T) {
+	// Scenarios with the same faults but different steps are told apart.
+	a := &Scenario{Steps: []Step{{Key: "a", Mode: ModeNoError}, {Key: "b", Mode: ModeError}}}
+	b := &Scenario{Steps: []Step{{Key: "b", Mode: ModeError}}}
+	idA, _ := outcome(a)
+	idB, _ := outcome(b)
+	if idA == idB {
+		t.Errorf("scenarios %v and %v have the same ID %q", a.Steps, b.Steps, idA)
+	}
+	if want := "a=NoError b=Error"; idA != want {
+		t.Errorf("got ID %q; want %q", idA, want)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// goroutines returns the stack traces of all goroutines, indexed by their
+// "goroutine N" header.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	m := map[string]string{}
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		s := string(g)
+		header := s
+		if i := strings.Index(s, " ["); i >= 0 {
+			header = s[:i]
+		}
+		m[header] = s
+	}
+	return m
+}
+
+// creator returns the ID of the goroutine that started the goroutine with
+// the given stack trace, or 0 if it is not known.
+func creator(stack string) int64 {
+	const in = " in goroutine "
+	i := strings.LastIndex(stack, in)
+	if i < 0 {
+		return 0
+	}
+	id := stack[i+len(in):]
+	if j := strings.IndexByte(id, '\n'); j >= 0 {
+		id = id[:j]
+	}
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
+
+// leakedGoroutines waits up to grace for the goroutines started by the
+// goroutine with ID root to finish and returns the stack traces of those that
+// remain. A goroutine not in before is started by root if root or another
+// goroutine started by root created it. Goroutines started by a goroutine
+// that exited before being observed can therefore not be attributed to root
+// and are not reported. Goroutines of concurrent tests are ignored.
+func leakedGoroutines(before map[string]string, root int64, grace time.Duration) []string {
+	deadline := time.Now().Add(grace)
+	started := map[int64]bool{root: true}
+	for {
+		running := map[int64]string{}
+		for header, stack := range goroutines() {
+			if _, ok := before[header]; !ok {
+				id, _ := strconv.ParseInt(strings.TrimPrefix(header, "goroutine "), 10, 64)
+				running[id] = stack
+			}
+		}
+		for added := true; added; {
+			added = false
+			for id, stack := range running {
+				if !started[id] && started[creator(stack)] {
+					started[id] = true
+					added = true
+				}
+			}
+		}
+		var leaked []string
+		for id, stack := range running {
+			if id != root && started[id] {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// lock locks the state of the current scenario until the returned function is
+// called, unless the calling goroutine already holds the lock, as is the case
+// when methods of Simulation call each other. It is used as
+//
+//	defer s.lock()()
+func (s *Simulation) lock() (unlock func()) {
+	id := goid()
+	if s.owner.Load() == id {
+		return func() {}
+	}
+	s.mu.Lock()
+	s.owner.Store(id)
+	return func() {
+		s.owner.Store(0)
+		s.mu.Unlock()
+	}
+}
+
+// step is like lock, but first waits for the turn of the calling goroutine to
+// execute a step if the goroutines of the scenario are interleaved. Steps of
+// different goroutines thus run one at a time.
+func (s *Simulation) step() (unlock func()) {
+	if s.owner.Load() != goid() {
+		s.sched.yield()
+	}
+	s.reportStopped()
+	return s.lock()
+}
+
+// goroutineWait is the minimum time a scenario waits for the goroutines
+// started with Simulation.Go to finish.
+const goroutineWait = 100 * time.Millisecond
+
+// A goroutineSet tracks the goroutines started with Simulation.Go in a single
+// scenario.
+type goroutineSet struct {
+	mu sync.Mutex
+
+	// running is the number of goroutines that have not finished. idle is
+	// closed when it drops to zero.
+	running int
+	idle    chan struct{}
+
+	panics []interface{}
+}
+
+// add records the start of a goroutine.
+func (g *goroutineSet) add() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.running++
+}
+
+// done records the end of a goroutine that panicked with r, if r is not nil.
+func (g *goroutineSet) done(r interface{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r != nil {
+		g.panics = append(g.panics, r)
+	}
+	g.running--
+	if g.running == 0 {
+		close(g.idle)
+	}
+}
+
+// Go runs f in a new goroutine on behalf of the current scenario. Unlike with
+// a go statement, a panic in f does not crash the test binary. Instead, the
+// scenario waits for f to finish before its outcome is checked, and fails if f
+// panicked with anything other than a simulated panic that was expected.
+// A simulated panic in f must still be passed to the simulation function,
+// typically as an error, like any other fault.
+//
+// The methods of Simulation may be called from f concurrently with the
+// simulation function: each step is executed while holding a lock on the
+// state of the scenario, so that steps run one at a time. A failure detected
+// in f, such as exceeding Config.MaxSteps, ends f and cancels the context of
+// the scenario; it is reported once the simulation function executes its next
+// step or returns.
+//
+// Goroutines that are still running after a grace period, the longer of
+// 100ms and Config.GoroutineGrace, are not waited for. With
+// Config.Interleavings, the steps of f are interleaved with those of other
+// goroutines in a reproducible order.
+func (s *Simulation) Go(f func()) {
+	g := s.goroutines
+	g.add()
+	sched := s.sched
+	id := sched.register()
+	go func() {
+		defer sched.exit(id)
+		defer func() { g.done(recover()) }()
+		sched.start(id)
+		f()
+	}()
+}
+
+// wait waits up to grace for the goroutines of g to finish and returns the
+// values with which they panicked so far. It starts no goroutines, which
+// would otherwise be reported as leaked by the scenario.
+func (g *goroutineSet) wait(grace time.Duration) []interface{} {
+	g.mu.Lock()
+	idle := g.idle
+	g.mu.Unlock()
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-time.After(grace):
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]interface{}(nil), g.panics...)
+}
+
+// checkGoroutines waits for the goroutines started with Go in the current
+// scenario and reports their unexpected panics. It reports whether any of them
+// panicked with the expected simulated panic, which must then be passed on.
+func (s *Simulation) checkGoroutines() (expected bool) {
+	grace := goroutineWait
+	if s.config != nil && s.config.GoroutineGrace > grace {
+		grace = s.config.GoroutineGrace
+	}
+	for _, r := range s.goroutines.wait(grace) {
+		if _, ok := r.(simError); !ok && !s.wrapsPanic(r) && !(s.ignorePanicOrder() && isPanicError(r)) {
+			s.fail(UnexpectedPanic, "goroutine panicked unexpectedly: %v", r)
+		}
+		if s.mustErr == nil || !isPanic(s.mustErr) {
+			s.fail(UnexpectedPanic, "goroutine panicked unexpectedly: %v", r)
+		}
+		expected = true
+	}
+	return expected
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package group provides a Group of goroutines working on a common task within
+// an errtest.Simulation, in the style of golang.org/x/sync/errgroup.
+//
+// The semantics of a Group are enforced by the simulation:
+//
+//   - the group is opened as a step that must be closed by Wait, so that a
+//     group that is not waited for is reported as a leak, and the values
+//     opened while the group is running must be closed before Wait is
+//     called;
+//   - Wait waits for all goroutines and returns the first error or panic
+//     of any of them, which is checked by the simulation like any other
+//     error;
+//   - panics in goroutines are checked by the simulation, as with
+//     errtest.Simulation.Go, instead of crashing the test.
+//
+// The context of the group is canceled once a goroutine fails, so that its
+// siblings can stop early. This is not enforced: a sibling may still execute
+// steps after the cancellation, as it cannot observe it atomically with
+// starting a step, and the simulation checks only the error returned by Wait.
+package group
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Group is a collection of goroutines working on a common task.
+type Group struct {
+	s   *errtest.Simulation
+	key string
+
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	err    error
+	waited bool
+}
+
+// New opens a Group with the given key and returns it along with a context
+// derived from the context of the simulation. The context is canceled when a
+// goroutine of the group fails or when Wait returns, whichever occurs first.
+func New(s *errtest.Simulation, key string) (*Group, context.Context) {
+	s.Open(key,
+		errtest.NoError(), errtest.NoPanic(),
+		errtest.CloseOptions(errtest.NoError(), errtest.NoPanic()),
+		errtest.Describe("the Group returned by New"))
+	ctx, cancel := context.WithCancelCause(s.Context())
+	return &Group{s: s, key: key, cancel: cancel}, ctx
+}
+
+// Go runs f in a new goroutine. The first goroutine to return a non-nil error
+// or to panic cancels the context of the group; its error or panic is
+// returned by Wait. A panic is also checked by the simulation after it is
+// recorded. Go may not be called after Wait.
+func (g *Group) Go(f func() error) {
+	g.mu.Lock()
+	waited := g.waited
+	g.mu.Unlock()
+	if waited {
+		g.s.Fatalf("group %q: Go called after Wait", g.key)
+	}
+	g.wg.Add(1)
+	g.s.Go(func() {
+		defer g.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", r)
+				}
+				g.fail(err)
+				panic(r)
+			}
+		}()
+		if err := f(); err != nil {
+			g.fail(err)
+		}
+	})
+}
+
+// fail records err if it is the first error of the group and cancels its
+// context.
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil {
+		g.err = err
+		g.cancel(err)
+	}
+}
+
+// Wait waits for all goroutines started with Go to return, closes the group,
+// and returns the first error or panic of any of them. Wait must be called
+// exactly once.
+func (g *Group) Wait() error {
+	g.mu.Lock()
+	waited := g.waited
+	g.waited = true
+	g.mu.Unlock()
+	if waited {
+		g.s.Fatalf("group %q: Wait called twice", g.key)
+	}
+	g.wg.Wait()
+	g.cancel(context.Canceled)
+	g.s.Close(g.key)
+	return g.err
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package group
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestGroup(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		f         func(s *errtest.Simulation) error
+		scenarios int
+		want      []errtest.FailureKind
+	}{{
+		desc: "first error wins",
+		f: func(s *errtest.Simulation) error {
+			g, ctx := New(s, "group")
+			done := make(chan struct{})
+			g.Go(func() error {
+				defer close(done)
+				return s.Open("a", errtest.NoClose())
+			})
+			g.Go(func() error {
+				<-done
+				if ctx.Err() != nil {
+					return nil // a sibling failed
+				}
+				return s.Open("b", errtest.NoClose())
+			})
+			err := g.Wait()
+			if err != nil && context.Cause(ctx) != err {
+				t.Errorf("got cause %v; want %v", context.Cause(ctx), err)
+			}
+			return err
+		},
+		// b is only run if a succeeds
+		scenarios: 5,
+	}, {
+		desc: "error ignored",
+		f: func(s *errtest.Simulation) error {
+			g, _ := New(s, "group")
+			g.Go(func() error {
+				return s.Open("a", errtest.NoPanic(), errtest.NoClose())
+			})
+			g.Wait()
+			return nil
+		},
+		scenarios: 2,
+		want:      []errtest.FailureKind{errtest.WrongError},
+	}, {
+		desc: "not waited for",
+		f: func(s *errtest.Simulation) error {
+			g, _ := New(s, "group")
+			g.Go(func() error { return nil })
+			return nil
+		},
+		scenarios: 1,
+		want:      []errtest.FailureKind{errtest.Leak},
+	}, {
+		desc: "waited twice",
+		f: func(s *errtest.Simulation) error {
+			g, _ := New(s, "group")
+			g.Wait()
+			return g.Wait()
+		},
+		scenarios: 1,
+		want:      []errtest.FailureKind{errtest.OtherFailure},
+	}, {
+		desc: "go after wait",
+		f: func(s *errtest.Simulation) error {
+			g, _ := New(s, "group")
+			g.Wait()
+			g.Go(func() error { return nil })
+			return nil
+		},
+		scenarios: 1,
+		want:      []errtest.FailureKind{errtest.OtherFailure},
+	}, {
+		desc: "value closed after wait",
+		f: func(s *errtest.Simulation) error {
+			g, _ := New(s, "group")
+			s.Open("a", errtest.NoError(), errtest.NoPanic(), errtest.CloseOptions(errtest.NoError(), errtest.NoPanic()))
+			err := g.Wait()
+			s.Close("a")
+			return err
+		},
+		scenarios: 1,
+		want:      []errtest.FailureKind{errtest.WrongCloseOrder},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := errtest.RunReport(t, &errtest.Config{ExpectFailure: len(tc.want) > 0}, tc.f)
+			if len(r.Scenarios) != tc.scenarios {
+				t.Errorf("got %d scenarios; want %d", len(r.Scenarios), tc.scenarios)
+			}
+			var got []errtest.FailureKind
+			for _, f := range r.Failures() {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	errtest.Run(t, nil, func(s *errtest.Simulation) error {
+		g, ctx := New(s, "group")
+		err := g.Wait()
+		if ctx.Err() == nil {
+			t.Error("context not canceled after Wait")
+		}
+		if context.Cause(ctx) != context.Canceled {
+			t.Errorf("got cause %v; want %v", context.Cause(ctx), context.Canceled)
+		}
+		return err
+	})
+}
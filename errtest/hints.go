@@ -0,0 +1,111 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "sync"
+
+// DefaultHints holds the hints shown for each kind of failure if
+// Config.ShowHints is set. The hints for a kind are revealed one at a time,
+// from the least to the most revealing.
+var DefaultHints = map[FailureKind][]string{
+	WrongError: {
+		"did you consider that Close can return an error even when err is nil?",
+		"the first error encountered must be returned, including errors returned by deferred closes",
+		"a deferred call evaluates its arguments when the defer statement is executed, not when the call runs",
+	},
+	UnexpectedPanic: {
+		"did you consider that a deferred call may panic while another panic is in progress?",
+		"a recovered panic must be passed on, either by panicking again or by returning it as an error",
+	},
+	WrongCloseOrder: {
+		"values must be closed in the reverse order in which they were opened",
+		"deferred calls run in reverse order; make sure a value is deferred after the values it depends on",
+	},
+	DoubleClose: {
+		"did you close a value both explicitly and in a deferred call?",
+	},
+	Leak: {
+		"did you consider what happens to values opened before an error is returned?",
+		"close values with a defer statement right after checking the error of the call that opened them",
+		"a deferred call in a loop only runs when the function returns",
+	},
+	Unchecked: {
+		"an error that is assigned but never inspected is as good as ignored",
+	},
+	IgnoredCancel: {
+		"did you check whether the context was canceled before doing more work?",
+		"once ctx.Err() is not nil, return it instead of starting new operations",
+	},
+	UsedPartial: {
+		"a value returned along with an error may be incomplete; check the error before using it",
+		"a partial value must not be used, but it must still be closed",
+	},
+	BadWrapping: {
+		"each layer of wrapping should say what was being done when the error occurred",
+		"wrap an error once per abstraction boundary; the function that returned it may already have added the context",
+	},
+	NotOwned: {
+		"did you close a value that was passed to you or borrowed from someone else?",
+		"only the owner of a value closes it; values you did not open are usually closed by whoever created them",
+	},
+	RecoveredPanic: {
+		"did you recover a panic to clean up and then return it as an error?",
+		"after running cleanups for a recovered panic, panic again with the recovered value",
+	},
+	ChangedPanic: {
+		"did you panic with a new value after recovering a panic?",
+		"panic again with the recovered value, or with an error wrapping it using %w, to preserve the root cause",
+	},
+	UseAfterClose: {
+		"did you use a value after a deferred or explicit close already ran?",
+		"check the error of the call that opened a value before using it; a failed open yields no usable value",
+	},
+	Unreached: {
+		"all required calls must be made if no error occurs",
+	},
+}
+
+// hints tracks the number of hints revealed for each kind of failure.
+var hints struct {
+	mu       sync.Mutex
+	revealed map[FailureKind]int
+}
+
+// nextHint returns the first hint for kind that was not revealed before in
+// this process, if any, and marks it as revealed. Hints in custom, if it has
+// an entry for kind, replace those of DefaultHints.
+func nextHint(kind FailureKind, custom map[FailureKind][]string) (string, bool) {
+	list, ok := custom[kind]
+	if !ok {
+		list = DefaultHints[kind]
+	}
+	hints.mu.Lock()
+	defer hints.mu.Unlock()
+	if hints.revealed == nil {
+		hints.revealed = map[FailureKind]int{}
+	}
+	i := hints.revealed[kind]
+	if i >= len(list) {
+		return "", false
+	}
+	hints.revealed[kind]++
+	return list[i], true
+}
+
+// showHint logs the next hint for the kind of the first failure of r to t,
+// if hints are enabled.
+func showHint(t reporter, config *Config, r *Results) {
+	if config == nil || !config.ShowHints {
+		return
+	}
+	for _, sc := range r.Scenarios {
+		if sc.Failed {
+			if h, ok := nextHint(sc.Kind, config.Hints); ok {
+				t.Logf("hint: %s", h)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHints(t *testing.T) {
+	hints.revealed = nil
+	leak := func(s *Simulation) error {
+		return s.Open("a", NoError(), NoPanic())
+	}
+	pass := func(s *Simulation) error {
+		return nil
+	}
+	custom := map[FailureKind][]string{Leak: {"first", "second"}}
+	testCases := []struct {
+		desc   string
+		config *Config
+		f      func(s *Simulation) error
+		want   []string
+	}{{
+		desc:   "disabled",
+		config: &Config{ContinueOnFailure: true, Hints: custom},
+		f:      leak,
+	}, {
+		desc:   "pass",
+		config: &Config{ContinueOnFailure: true, ShowHints: true, Hints: custom},
+		f:      pass,
+	}, {
+		desc:   "first",
+		config: &Config{ContinueOnFailure: true, ShowHints: true, Hints: custom},
+		f:      leak,
+		want:   []string{"hint: first"},
+	}, {
+		desc:   "second",
+		config: &Config{ContinueOnFailure: true, ShowHints: true, Hints: custom},
+		f:      leak,
+		want:   []string{"hint: second"},
+	}, {
+		desc:   "exhausted",
+		config: &Config{ContinueOnFailure: true, ShowHints: true, Hints: custom},
+		f:      leak,
+	}, {
+		desc:   "default",
+		config: &Config{ContinueOnFailure: true, ShowHints: true},
+		f: func(s *Simulation) error {
+			s.Open("a", NoError(), NoPanic())
+			s.Close("a")
+			return s.Close("a")
+		},
+		want: []string{"hint: " + DefaultHints[DoubleClose][0]},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tb := &recordTB{}
+			RunReport(tb, tc.config, tc.f)
+			if !reflect.DeepEqual(tb.logs, tc.want) {
+				t.Errorf("got %q; want %q", tb.logs, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logger returns the logger of the configuration, or nil if events are not
+// logged.
+func (s *Simulation) logger() *slog.Logger {
+	if s.config == nil {
+		return nil
+	}
+	return s.config.Logger
+}
+
+// logEvent logs e to the logger of the configuration, if any.
+func (s *Simulation) logEvent(e Event) {
+	l := s.logger()
+	if l == nil {
+		return
+	}
+	switch e.Kind {
+	case EventScenarioStart:
+		l.LogAttrs(context.Background(), slog.LevelInfo, "scenario start",
+			slog.Int("scenario", e.Scenario))
+	case EventOpen, EventClose, EventFault:
+		l.LogAttrs(context.Background(), slog.LevelDebug, "step",
+			slog.Int("scenario", e.Scenario),
+			slog.String("key", e.Key),
+			slog.String("mode", e.Mode.String()))
+	case EventFailure:
+		l.LogAttrs(context.Background(), slog.LevelInfo, "failure",
+			slog.Int("scenario", e.Scenario),
+			slog.String("kind", e.Failure.String()),
+			slog.String("message", e.Message))
+	case EventScenarioEnd:
+		logScenarioEnd(l, *e.outcome)
+	}
+}
+
+// logScenarioEnd logs the outcome of a scenario to l.
+func logScenarioEnd(l *slog.Logger, sc Scenario) {
+	attrs := []slog.Attr{
+		slog.Int("scenario", sc.Index),
+		slog.Int("steps", len(sc.Steps)),
+		slog.Bool("failed", sc.Failed),
+		slog.Bool("skipped", sc.Skipped),
+	}
+	if sc.Failed {
+		attrs = append(attrs,
+			slog.String("kind", sc.Kind.String()),
+			slog.String("message", sc.Message))
+	}
+	l.LogAttrs(context.Background(), slog.LevelInfo, "scenario end", attrs...)
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	b := &bytes.Buffer{}
+	h := slog.NewTextHandler(b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	config := &Config{Logger: slog.New(h)}
+	RunStandalone(config, func(s *Simulation) error {
+		s.Open("reader", NoPanic(), NoClose())
+		return nil
+	})
+	got := strings.Split(strings.TrimSpace(b.String()), "\n")
+	want := []string{
+		`level=INFO msg="scenario start" scenario=0`,
+		`level=DEBUG msg=step scenario=0 key=reader mode=NoError`,
+		`level=INFO msg="scenario end" scenario=0 steps=1 failed=false skipped=false`,
+		`level=INFO msg="scenario start" scenario=1`,
+		`level=DEBUG msg=step scenario=1 key=reader mode=Error`,
+		`level=INFO msg=failure scenario=1 kind=WrongError message="simulation did not return the correct error: got <nil>; want reader: Error"`,
+		`level=INFO msg="scenario end" scenario=1 steps=1 failed=true skipped=false kind=WrongError message="simulation did not return the correct error: got <nil>; want reader: Error"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package oteltest traces errtest simulations with OpenTelemetry.
+//
+// Each scenario is recorded as a span, with a child span for each executed
+// step holding the simulated mode, and an event for each failure. The spans
+// of steps for which a fault is simulated have an error status. The context
+// returned by Simulation.Context carries the span of the scenario, so that
+// spans created by the solution are nested within it. Tracing is built on
+// Config.OnEvent, so that packages not using OpenTelemetry do not depend on
+// it.
+package oteltest
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// WithTracer traces the scenarios of a simulation with tr. It sets
+// Config.OnEvent, calling any function already set, and Config.BaseContext.
+func WithTracer(tr trace.Tracer) errtest.ConfigOption {
+	return func(c *errtest.Config) {
+		t := &tracer{tr: tr, next: c.OnEvent}
+		c.OnEvent = t.event
+		c.BaseContext = t.context
+	}
+}
+
+// A tracer records the events of a simulation as spans.
+type tracer struct {
+	tr   trace.Tracer
+	next func(e errtest.Event)
+
+	// mu guards the fields below, as events may be emitted by the goroutines
+	// started with Simulation.Go.
+	mu sync.Mutex
+
+	// span is the span of the current scenario and ctx its context. steps
+	// counts the steps executed in the scenario.
+	ctx   context.Context
+	span  trace.Span
+	steps int
+}
+
+// context returns the context of the span of the current scenario.
+func (t *tracer) context() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.span == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+func (t *tracer) event(e errtest.Event) {
+	if t.next != nil {
+		t.next(e)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e.Kind {
+	case errtest.EventScenarioStart:
+		t.steps = 0
+		t.ctx, t.span = t.tr.Start(context.Background(), "errtest.scenario",
+			trace.WithAttributes(attribute.Int("errtest.scenario", e.Scenario)))
+
+	case errtest.EventOpen, errtest.EventClose, errtest.EventFault:
+		if t.span == nil {
+			return
+		}
+		t.steps++
+		_, span := t.tr.Start(t.ctx, "errtest.step", trace.WithAttributes(
+			attribute.String("errtest.key", e.Key),
+			attribute.String("errtest.mode", e.Mode.String())))
+		if e.Kind == errtest.EventFault {
+			span.SetStatus(codes.Error, "simulated "+e.Mode.String())
+		}
+		span.End()
+
+	case errtest.EventFailure:
+		if t.span != nil {
+			t.span.AddEvent("errtest.failure", trace.WithAttributes(
+				attribute.String("errtest.kind", e.Failure.String()),
+				attribute.String("errtest.message", e.Message)))
+		}
+
+	case errtest.EventScenarioEnd:
+		if t.span == nil {
+			return
+		}
+		t.span.SetAttributes(
+			attribute.Int("errtest.steps", t.steps),
+			attribute.Bool("errtest.skipped", e.Skipped))
+		if e.Failure != errtest.NoFailure {
+			t.span.SetAttributes(attribute.String("errtest.kind", e.Failure.String()))
+			t.span.SetStatus(codes.Error, e.Message)
+		}
+		t.span.End()
+		t.ctx, t.span = nil, nil
+	}
+}
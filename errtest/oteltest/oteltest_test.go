@@ -0,0 +1,121 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package oteltest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// recordTracer records the spans it creates, and the calls made on them, as
+// lines of text.
+type recordTracer struct {
+	noop.Tracer
+	lines []string
+}
+
+func (t *recordTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	c := trace.NewSpanStartConfig(opts...)
+	if _, ok := trace.SpanFromContext(ctx).(*recordSpan); ok {
+		name = "  " + name
+	}
+	t.lines = append(t.lines, "start "+name+attrs(c.Attributes()))
+	s := &recordSpan{Span: trace.SpanFromContext(context.Background()), t: t}
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type recordSpan struct {
+	trace.Span
+	t *recordTracer
+}
+
+func (s *recordSpan) End(...trace.SpanEndOption) { s.t.lines = append(s.t.lines, "end") }
+
+func (s *recordSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.t.lines = append(s.t.lines, "attrs"+attrs(kv))
+}
+
+func (s *recordSpan) SetStatus(code codes.Code, msg string) {
+	s.t.lines = append(s.t.lines, fmt.Sprintf("status %v %q", code, msg))
+}
+
+func (s *recordSpan) AddEvent(name string, opts ...trace.EventOption) {
+	c := trace.NewEventConfig(opts...)
+	s.t.lines = append(s.t.lines, "event "+name+attrs(c.Attributes()))
+}
+
+func attrs(kv []attribute.KeyValue) string {
+	b := &strings.Builder{}
+	for _, a := range kv {
+		fmt.Fprintf(b, " %s=%s", a.Key, a.Value.Emit())
+	}
+	return b.String()
+}
+
+func TestWithTracer(t *testing.T) {
+	tr := &recordTracer{}
+	var events int
+	config := (&errtest.Config{
+		OnEvent: func(errtest.Event) { events++ },
+	}).With(WithTracer(tr))
+	errtest.RunStandalone(config, func(s *errtest.Simulation) error {
+		if _, ok := trace.SpanFromContext(s.Context()).(*recordSpan); !ok {
+			t.Errorf("context does not carry the span of the scenario")
+		}
+		s.Open("reader", errtest.NoPanic(), errtest.NoClose())
+		return nil
+	})
+	want := []string{
+		"start errtest.scenario errtest.scenario=0",
+		"start   errtest.step errtest.key=reader errtest.mode=NoError",
+		"end",
+		"attrs errtest.steps=1 errtest.skipped=false",
+		"end",
+		"start errtest.scenario errtest.scenario=1",
+		"start   errtest.step errtest.key=reader errtest.mode=Error",
+		`status Error "simulated Error"`,
+		"end",
+		"event errtest.failure errtest.kind=WrongError errtest.message=simulation did not return the correct error: got <nil>; want reader: Error",
+		"attrs errtest.steps=1 errtest.skipped=false",
+		"attrs errtest.kind=WrongError",
+		`status Error "simulation did not return the correct error: got <nil>; want reader: Error"`,
+		"end",
+	}
+	if !reflect.DeepEqual(tr.lines, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(tr.lines, "\n"), strings.Join(want, "\n"))
+	}
+	if events == 0 {
+		t.Error("OnEvent set before WithTracer was not called")
+	}
+}
+
+// TestWithTracerGo checks that steps of goroutines started with Go may be
+// traced concurrently with those of the simulation function. Run with -race.
+func TestWithTracerGo(t *testing.T) {
+	tr := &recordTracer{}
+	config := (&errtest.Config{IgnorePanicOrder: true}).With(WithTracer(tr))
+	errtest.RunStandalone(config, func(s *errtest.Simulation) error {
+		errc := make(chan error, 1)
+		s.Go(func() { errc <- s.Open("a", errtest.NoPanic(), errtest.NoClose()) })
+		err := s.Open("b", errtest.NoPanic(), errtest.NoClose())
+		if errA := <-errc; err == nil {
+			err = errA
+		}
+		return err
+	})
+	if len(tr.lines) == 0 {
+		t.Error("no spans recorded")
+	}
+}
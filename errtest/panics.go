@@ -0,0 +1,56 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "errors"
+
+// checkRepanic fails the current scenario under Config.RequireRepanic if the
+// simulated panic that must be passed on did not propagate out of the
+// simulation function.
+func (s *Simulation) checkRepanic() {
+	if s.config == nil || !s.config.RequireRepanic {
+		return
+	}
+	if s.mustErr != nil && isPanic(s.mustErr) {
+		s.fail(RecoveredPanic, "simulated panic %v was recovered and not raised again", s.mustErr)
+	}
+}
+
+func (s *Simulation) forbidRecover() bool {
+	return s.config != nil && s.config.ForbidRecover
+}
+
+// checkRecover fails the current scenario under Config.ForbidRecover if a
+// simulated panic raised by the goroutine running the simulation function did
+// not leave the simulation function. It must be called by the function
+// deferred by runScenario, with the value it recovered.
+func (s *Simulation) checkRecover(r interface{}) {
+	if !s.forbidRecover() || s.raised == nil || s.message != "" {
+		return
+	}
+	if e, ok := r.(simError); !ok || e.mode != s.raised.mode || e.key != s.raised.key {
+		s.fail(RecoveredPanic, "simulated panic was recovered; panics must propagate untouched")
+	}
+}
+
+// wrapsPanic reports whether r, a value other than a simulated panic, is an
+// error wrapping the simulated panic that must be passed on, as is the case
+// if a solution raises a recovered panic again with added context.
+func (s *Simulation) wrapsPanic(r interface{}) bool {
+	err, ok := r.(error)
+	return ok && s.mustErr != nil && isPanic(s.mustErr) && errors.Is(err, s.mustErr)
+}
+
+// isPanicError reports whether r is an error identifiable as a panic, or an
+// error wrapping one.
+func isPanicError(r interface{}) bool {
+	err, _ := r.(error)
+	for ; err != nil; err = errors.Unwrap(err) {
+		if isPanic(err) {
+			return true
+		}
+	}
+	return false
+}
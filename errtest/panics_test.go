@@ -0,0 +1,183 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRequireRepanic(t *testing.T) {
+	converted := func(s *Simulation) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		return s.Open("a", NoError(), NoClose())
+	}
+	repanicked := func(s *Simulation) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				panic(r)
+			}
+		}()
+		return s.Open("a", NoError(), NoClose())
+	}
+	testCases := []struct {
+		desc    string
+		require bool
+		f       func(s *Simulation) error
+		want    []FailureKind
+	}{
+		{"converted", false, converted, nil},
+		{"converted/required", true, converted, []FailureKind{RecoveredPanic}},
+		{"repanicked/required", true, repanicked, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []FailureKind
+			for _, f := range RunStandalone((&Config{}).With(WithRequireRepanic(tc.require)), tc.f) {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestForbidRecover(t *testing.T) {
+	opts := []Option{NoError(), CloseOptions(NoError())}
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		want []FailureKind
+	}{{
+		desc: "propagated",
+		f: func(s *Simulation) error {
+			if err := s.Open("a", opts...); err != nil {
+				return err
+			}
+			defer s.Close("a") // may panic while a panics
+			return s.Open("b", append(opts, NoClose())...)
+		},
+	}, {
+		desc: "converted",
+		f: func(s *Simulation) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = r.(error)
+				}
+			}()
+			return s.Open("a", append(opts, NoClose())...)
+		},
+		want: []FailureKind{RecoveredPanic},
+	}, {
+		desc: "repanicked",
+		f: func(s *Simulation) error {
+			defer func() {
+				if r := recover(); r != nil {
+					panic(r)
+				}
+			}()
+			return s.Open("a", append(opts, NoClose())...)
+		},
+	}, {
+		desc: "wrapped",
+		f: func(s *Simulation) error {
+			defer func() {
+				if r := recover(); r != nil {
+					panic(fmt.Errorf("wrapped: %w", r.(error)))
+				}
+			}()
+			return s.Open("a", append(opts, NoClose())...)
+		},
+		want: []FailureKind{RecoveredPanic},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []FailureKind
+			for _, f := range RunStandalone((&Config{}).With(WithForbidRecover(true)), tc.f) {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChangedPanic(t *testing.T) {
+	repanic := func(wrap func(r interface{}) interface{}) func(s *Simulation) error {
+		return func(s *Simulation) error {
+			defer func() {
+				if r := recover(); r != nil {
+					panic(wrap(r))
+				}
+			}()
+			return s.Open("a", NoError(), NoClose())
+		}
+	}
+	// Relaxed accepts any panic error in place of the simulated one, just as
+	// it does not require panics to be passed on in order, but not other
+	// values.
+	testCases := []struct {
+		desc    string
+		f       func(s *Simulation) error
+		want    []FailureKind
+		relaxed []FailureKind
+	}{{
+		desc: "same value",
+		f:    repanic(func(r interface{}) interface{} { return r }),
+	}, {
+		desc: "wrapped",
+		f: repanic(func(r interface{}) interface{} {
+			return fmt.Errorf("copying: %w", r.(error))
+		}),
+	}, {
+		desc: "replaced",
+		f: repanic(func(r interface{}) interface{} {
+			return fmt.Errorf("copying: %v", r)
+		}),
+		want:    []FailureKind{ChangedPanic},
+		relaxed: []FailureKind{ChangedPanic},
+	}, {
+		desc:    "not an error",
+		f:       repanic(func(r interface{}) interface{} { return fmt.Sprint(r) }),
+		want:    []FailureKind{ChangedPanic},
+		relaxed: []FailureKind{ChangedPanic},
+	}, {
+		desc: "wrapped panic error",
+		f: repanic(func(r interface{}) interface{} {
+			return fmt.Errorf("copying: %w", NewPanicError("something else"))
+		}),
+		want: []FailureKind{ChangedPanic},
+	}, {
+		desc: "other panic error",
+		f: repanic(func(r interface{}) interface{} {
+			return NewPanicError("something else")
+		}),
+		want: []FailureKind{ChangedPanic},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, config := range []*Config{nil, Relaxed} {
+				want := tc.want
+				if config == Relaxed {
+					want = tc.relaxed
+				}
+				var got []FailureKind
+				for _, f := range RunStandalone(config, tc.f) {
+					got = append(got, f.Kind)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("relaxed %v: got %v; want %v", config == Relaxed, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build playground
+
+package errtest
+
+// runSubtest never runs scenarios as subtests, as builds with the playground
+// build tag do not depend on the testing package.
+func runSubtest(t reporter, s *Simulation, f func(s *Simulation) error) (ok, skipped, isT bool) {
+	return false, false, false
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "strings"
+
+// A Priority reports whether the fault next takes precedence over the fault
+// cur as the error that must be returned by a scenario, where cur occurred
+// before next.
+type Priority func(cur, next Step) bool
+
+// FirstError gives precedence to panics over errors and otherwise to the
+// first fault. It is the default.
+func FirstError(cur, next Step) bool {
+	return next.Mode == ModePanic && cur.Mode != ModePanic
+}
+
+// LastError gives precedence to panics over errors and otherwise to the last
+// fault.
+func LastError(cur, next Step) bool {
+	return cur.Mode != ModePanic
+}
+
+// BodyOverClose is like FirstError, but lets an error of any step other than
+// a close take precedence over an earlier error of a close.
+func BodyOverClose(cur, next Step) bool {
+	if FirstError(cur, next) {
+		return true
+	}
+	return cur.Mode != ModePanic && cur.IsClose() && !next.IsClose()
+}
+
+// IsClose reports whether the step is the close of a value.
+func (st Step) IsClose() bool {
+	return strings.HasSuffix(st.Key, ".close")
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "time"
+
+// A progress reports the number of scenarios run so far, at most once per
+// Config.ProgressInterval.
+type progress struct {
+	interval time.Duration
+	report   func(done, total int)
+	total    int
+	last     time.Time
+}
+
+// newProgress returns a progress for the given configuration, or nil if
+// progress should not be reported. Progress is logged to t if
+// Config.OnProgress is not set.
+func newProgress(t reporter, config *Config, total int) *progress {
+	if config == nil || config.ProgressInterval <= 0 {
+		return nil
+	}
+	report := config.OnProgress
+	if report == nil {
+		if t == nil {
+			return nil
+		}
+		report = func(done, total int) {
+			if total > 0 {
+				t.Logf("errtest: ran %d/%d scenarios", done, total)
+			} else {
+				t.Logf("errtest: ran %d scenarios", done)
+			}
+		}
+	}
+	return &progress{
+		interval: config.ProgressInterval,
+		report:   report,
+		total:    total,
+		last:     time.Now(),
+	}
+}
+
+// update records that done scenarios have run.
+func (p *progress) update(done int) {
+	if p == nil {
+		return
+	}
+	if now := time.Now(); now.Sub(p.last) >= p.interval {
+		p.last = now
+		p.report(done, p.total)
+	}
+}
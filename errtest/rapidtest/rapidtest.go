@@ -0,0 +1,72 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rapidtest drives errtest simulations with the generators of
+// pgregory.net/rapid.
+//
+// Instead of enumerating all scenarios, which is infeasible for simulations
+// with many steps, the mode of each step is drawn from rapid. Failing
+// scenarios are shrunk by rapid towards scenarios with fewer faults, and
+// scenarios can be combined with other generated input and with the state
+// machines of rapid.
+package rapidtest
+
+import (
+	"fmt"
+
+	"pgregory.net/rapid"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// Check runs scenarios of f, drawn by rapid, until rapid is satisfied or a
+// failing scenario is found, which is then reported to t in its smallest form.
+func Check(t rapid.TB, config *errtest.Config, f func(s *errtest.Simulation) error) {
+	t.Helper()
+	rapid.Check(t, func(rt *rapid.T) {
+		Run(rt, config, f)
+	})
+}
+
+// Run runs a single scenario of f in which the mode of each step is drawn from
+// rt. A failure of the scenario fails rt.
+func Run(rt *rapid.T, config *errtest.Config, f func(s *errtest.Simulation) error) errtest.Scenario {
+	var (
+		step    int
+		aborted interface{}
+	)
+	choose := func(weights []float64) int {
+		var allowed []int
+		for i, w := range weights {
+			if w > 0 {
+				allowed = append(allowed, i)
+			}
+		}
+		if aborted != nil || len(allowed) == 0 {
+			return 0
+		}
+		step++
+		defer func() {
+			// Rapid aborts a test case by panicking, which must not be
+			// mistaken for a panic of the simulation. The remainder of the
+			// scenario is run without faults and the panic is passed on
+			// once the scenario completes.
+			if r := recover(); r != nil {
+				aborted = r
+			}
+		}()
+		return rapid.SampledFrom(allowed).Draw(rt, fmt.Sprint("step ", step))
+	}
+	sc := errtest.RunScenario(config, choose, f)
+	if aborted != nil {
+		panic(aborted)
+	}
+	switch {
+	case sc.Skipped:
+		rt.SkipNow()
+	case sc.Failed:
+		rt.Fatalf("%v: %s", sc.Faults(), sc.Message)
+	}
+	return sc
+}
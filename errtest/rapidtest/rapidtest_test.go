@@ -0,0 +1,75 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rapidtest
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// copyN opens n readers and closes them in reverse order, except for the
+// reader with index leak.
+func copyN(n, leak int) func(s *errtest.Simulation) error {
+	return func(s *errtest.Simulation) (err error) {
+		for i := 0; i < n; i++ {
+			key := fmt.Sprint("r", i)
+			if err := s.Open(key, errtest.NoPanic()); err != nil {
+				return err
+			}
+			if i == leak {
+				continue
+			}
+			defer func() {
+				if errC := s.Close(key); err == nil {
+					err = errC
+				}
+			}()
+		}
+		return nil
+	}
+}
+
+func TestCheck(t *testing.T) {
+	Check(t, nil, copyN(20, -1))
+}
+
+// recordTB records the failures reported by rapid instead of failing the test.
+type recordTB struct {
+	*testing.T
+	errs []string
+}
+
+func (t *recordTB) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+func (t *recordTB) Fatalf(format string, args ...any) { t.Errorf(format, args...) }
+func (t *recordTB) Error(args ...any)                 { t.errs = append(t.errs, fmt.Sprint(args...)) }
+func (t *recordTB) Fatal(args ...any)                 { t.Error(args...) }
+func (t *recordTB) Fail()                             { t.Error("Fail called") }
+func (t *recordTB) FailNow()                          { t.Error("FailNow called") }
+func (t *recordTB) Failed() bool                      { return len(t.errs) > 0 }
+
+func TestCheckFailure(t *testing.T) {
+	// Do not leave a fail file behind for the expected failure.
+	old := flag.Lookup("rapid.nofailfile").Value.String()
+	if err := flag.Set("rapid.nofailfile", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.Set("rapid.nofailfile", old) })
+	tb := &recordTB{T: t}
+	// Only scenarios in which r2 is opened successfully fail. Rapid shrinks
+	// the failure to the scenario without faults.
+	Check(tb, nil, copyN(3, 2))
+	if len(tb.errs) == 0 {
+		t.Fatal("failure was not detected")
+	}
+	if want := `[]: "r2" closed in wrong order`; !strings.Contains(tb.errs[0], want) {
+		t.Errorf("got %q; want error containing %q", tb.errs[0], want)
+	}
+}
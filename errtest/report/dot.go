@@ -0,0 +1,100 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A node is a step in the decision tree. The path from the root to a node
+// represents the outcomes of all preceding steps.
+type node struct {
+	id       int
+	label    string
+	scenario *errtest.Scenario // non-nil for leaves
+	edges    []edge
+}
+
+type edge struct {
+	label string
+	to    *node
+}
+
+type tree struct {
+	root  *node
+	nodes []*node
+}
+
+func (t *tree) newNode(label string) *node {
+	n := &node{id: len(t.nodes), label: label}
+	t.nodes = append(t.nodes, n)
+	return n
+}
+
+// child returns the node reached from n by an edge with the given label,
+// creating it if it does not exist.
+func (t *tree) child(n *node, label, key string) *node {
+	for _, e := range n.edges {
+		if e.label == label && e.to.scenario == nil && e.to.label == key {
+			return e.to
+		}
+	}
+	c := t.newNode(key)
+	n.edges = append(n.edges, edge{label, c})
+	return c
+}
+
+func newTree(r *errtest.Results) *tree {
+	t := &tree{}
+	t.root = t.newNode("start")
+	for i := range r.Scenarios {
+		sc := &r.Scenarios[i]
+		n, label := t.root, ""
+		for _, st := range sc.Steps {
+			n = t.child(n, label, st.Key)
+			label = st.Mode.String()
+		}
+		leaf := t.newNode(fmt.Sprintf("#%d", sc.Index))
+		leaf.scenario = sc
+		n.edges = append(n.edges, edge{label, leaf})
+	}
+	return t
+}
+
+// DOT writes the decision tree explored by a simulation in Graphviz DOT
+// format. Each executed key branches into the modes with which it was run and
+// each scenario ends in a leaf. Failing leaves are highlighted.
+func DOT(w io.Writer, title string, r *errtest.Results) error {
+	bw := bufio.NewWriter(w)
+	t := newTree(r)
+	fmt.Fprintf(bw, "digraph %q {\n", title)
+	fmt.Fprintf(bw, "\tnode [shape=box];\n")
+	for _, n := range t.nodes {
+		switch sc := n.scenario; {
+		case sc == nil:
+			fmt.Fprintf(bw, "\tn%d [label=%q];\n", n.id, n.label)
+		case sc.Failed:
+			fmt.Fprintf(bw, "\tn%d [label=%q, tooltip=%q, shape=ellipse, style=filled, fillcolor=red];\n",
+				n.id, n.label, sc.Message)
+		default:
+			fmt.Fprintf(bw, "\tn%d [label=%q, shape=ellipse];\n", n.id, n.label)
+		}
+	}
+	for _, n := range t.nodes {
+		for _, e := range n.edges {
+			if e.label == "" {
+				fmt.Fprintf(bw, "\tn%d -> n%d;\n", n.id, e.to.id)
+			} else {
+				fmt.Fprintf(bw, "\tn%d -> n%d [label=%q];\n", n.id, e.to.id, e.label)
+			}
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
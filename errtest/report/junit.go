@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// scenarioName returns a name for sc that identifies it by its index and
+// faults, as in "#1 reader.close=Error".
+func scenarioName(sc *errtest.Scenario) string {
+	var faults []string
+	for _, st := range sc.Faults() {
+		faults = append(faults, st.String())
+	}
+	if len(faults) == 0 {
+		return fmt.Sprintf("#%d (no faults)", sc.Index)
+	}
+	return fmt.Sprintf("#%d %s", sc.Index, strings.Join(faults, " "))
+}
+
+// JUnit writes the scenarios of r to w as a JUnit XML test suite with the
+// given name, with one test case per scenario, so that CI systems can
+// display the failures of individual scenarios.
+func JUnit(w io.Writer, name string, r *errtest.Results) error {
+	suite := junitSuite{Name: name, Tests: len(r.Scenarios)}
+	for i := range r.Scenarios {
+		sc := &r.Scenarios[i]
+		c := junitCase{Name: scenarioName(sc), ClassName: name}
+		switch {
+		case sc.Skipped:
+			suite.Skipped++
+			c.Skipped = &struct{}{}
+		case sc.Failed:
+			suite.Failures++
+			var steps []string
+			for _, st := range sc.Steps {
+				steps = append(steps, st.String())
+			}
+			c.Failure = &junitFailure{
+				Message: sc.Message,
+				Type:    sc.Kind.String(),
+				Text:    "steps: " + strings.Join(steps, " "),
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
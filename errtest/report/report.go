@@ -0,0 +1,110 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package report renders the Results of a simulation in formats suitable for
+// human inspection or for consumption by other tools, such as CI systems.
+package report
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+var modes = []errtest.Mode{errtest.ModeNoError, errtest.ModeError, errtest.ModePanic}
+
+// A cell aggregates all scenarios in which a key was executed with a given
+// mode.
+type cell struct {
+	Scenarios int
+	Failed    int
+}
+
+func (c cell) Class() string {
+	switch {
+	case c.Scenarios == 0:
+		return "none"
+	case c.Failed == 0:
+		return "pass"
+	case c.Failed == c.Scenarios:
+		return "fail"
+	}
+	return "partial"
+}
+
+type row struct {
+	Key   string
+	Cells []cell
+}
+
+type matrix struct {
+	Title    string
+	Results  *errtest.Results
+	Modes    []errtest.Mode
+	Rows     []row
+	Failures []errtest.Scenario
+}
+
+// newMatrix computes a keys × modes matrix, where keys are listed in order of
+// first appearance.
+func newMatrix(title string, r *errtest.Results) *matrix {
+	m := &matrix{Title: title, Results: r, Modes: modes}
+	index := map[string]int{}
+	for _, sc := range r.Scenarios {
+		for _, st := range sc.Steps {
+			i, ok := index[st.Key]
+			if !ok {
+				i = len(m.Rows)
+				index[st.Key] = i
+				m.Rows = append(m.Rows, row{Key: st.Key, Cells: make([]cell, len(modes))})
+			}
+			c := &m.Rows[i].Cells[st.Mode]
+			c.Scenarios++
+			if sc.Failed {
+				c.Failed++
+			}
+		}
+		if sc.Failed {
+			m.Failures = append(m.Failures, sc)
+		}
+	}
+	return m
+}
+
+// HTML writes an HTML page to w showing, for each key and mode, how many
+// scenarios executed the key with that mode and how many of those failed.
+func HTML(w io.Writer, title string, r *errtest.Results) error {
+	return htmlTemplate.Execute(w, newMatrix(title, r))
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+table { border-collapse: collapse; font-family: monospace; }
+th, td { border: 1px solid #999; padding: 4px 8px; text-align: center; }
+td.key { text-align: left; }
+td.none { background: #eee; color: #999; }
+td.pass { background: #9e9; }
+td.partial { background: #fc6; }
+td.fail { background: #f88; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Results.Scenarios}} scenarios, {{.Results.Failed}} failed.</p>
+<table>
+<tr><th>key</th>{{range .Modes}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td class="key">{{.Key}}</td>{{range .Cells}}<td class="{{.Class}}">{{if .Scenarios}}{{.Failed}}/{{.Scenarios}}{{else}}-{{end}}</td>{{end}}</tr>
+{{end}}</table>
+{{if .Failures}}<h2>Failures</h2>
+<ol>
+{{range .Failures}}<li>#{{.Index}} {{range .Faults}}{{.}} {{end}}&mdash; {{.Message}}</li>
+{{end}}</ol>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,110 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+var results = &errtest.Results{Scenarios: []errtest.Scenario{{
+	Index: 0,
+	Steps: []errtest.Step{{Key: "reader", Mode: errtest.ModeNoError}, {Key: "reader.close", Mode: errtest.ModeNoError}},
+}, {
+	Index:   1,
+	Steps:   []errtest.Step{{Key: "reader", Mode: errtest.ModeNoError}, {Key: "reader.close", Mode: errtest.ModeError}},
+	Failed:  true,
+	Kind:    errtest.WrongError,
+	Message: "got <nil>; want reader.close: Error",
+}, {
+	Index: 2,
+	Steps: []errtest.Step{{Key: "reader", Mode: errtest.ModeError}},
+}}}
+
+func TestHTML(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := HTML(b, "Reader", results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<title>Reader</title>`,
+		`<tr><td class="key">reader</td><td class="partial">1/2</td><td class="pass">0/1</td><td class="none">-</td></tr>`,
+		`<tr><td class="key">reader.close</td><td class="pass">0/1</td><td class="fail">1/1</td><td class="none">-</td></tr>`,
+		`<li>#1 reader.close=Error &mdash; got &lt;nil&gt;; want reader.close: Error</li>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, b)
+		}
+	}
+}
+
+func TestDOT(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := DOT(b, "Reader", results); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph "Reader" {
+	node [shape=box];
+	n0 [label="start"];
+	n1 [label="reader"];
+	n2 [label="reader.close"];
+	n3 [label="#0", shape=ellipse];
+	n4 [label="#1", tooltip="got <nil>; want reader.close: Error", shape=ellipse, style=filled, fillcolor=red];
+	n5 [label="#2", shape=ellipse];
+	n0 -> n1;
+	n1 -> n2 [label="NoError"];
+	n1 -> n5 [label="Error"];
+	n2 -> n3 [label="NoError"];
+	n2 -> n4 [label="Error"];
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := JUnit(b, "Reader", results); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="Reader" tests="3" failures="1" skipped="0">
+	<testcase name="#0 (no faults)" classname="Reader"></testcase>
+	<testcase name="#1 reader.close=Error" classname="Reader">
+		<failure message="got &lt;nil&gt;; want reader.close: Error" type="WrongError">steps: reader=NoError reader.close=Error</failure>
+	</testcase>
+	<testcase name="#2 reader=Error" classname="Reader"></testcase>
+</testsuite>
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTAP(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := TAP(b, "Reader", results); err != nil {
+		t.Fatal(err)
+	}
+	want := `TAP version 13
+# Reader
+1..3
+ok 1 - \#0 (no faults)
+not ok 2 - \#1 reader.close=Error
+  ---
+  kind: WrongError
+  message: "got <nil>; want reader.close: Error"
+  steps: [reader=NoError, reader.close=Error]
+  ...
+ok 3 - \#2 reader=Error
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
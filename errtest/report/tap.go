@@ -0,0 +1,54 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// TAP writes the scenarios of r to w in the Test Anything Protocol, version
+// 13, with one test point per scenario. The name is written as a comment.
+// Failed test points include a YAML block with the kind of failure, its
+// message, and the executed steps. Skipped scenarios are marked with a SKIP
+// directive. Descriptions are escaped so that a # in them does not start a
+// directive.
+func TAP(w io.Writer, name string, r *errtest.Results) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "TAP version 13\n")
+	fmt.Fprintf(bw, "# %s\n", name)
+	fmt.Fprintf(bw, "1..%d\n", len(r.Scenarios))
+	for i := range r.Scenarios {
+		sc := &r.Scenarios[i]
+		desc := tapEscaper.Replace(scenarioName(sc))
+		switch {
+		case sc.Skipped:
+			fmt.Fprintf(bw, "ok %d - %s # SKIP\n", i+1, desc)
+		case sc.Failed:
+			var steps []string
+			for _, st := range sc.Steps {
+				steps = append(steps, st.String())
+			}
+			fmt.Fprintf(bw, "not ok %d - %s\n", i+1, desc)
+			fmt.Fprintf(bw, "  ---\n")
+			fmt.Fprintf(bw, "  kind: %s\n", sc.Kind)
+			fmt.Fprintf(bw, "  message: %s\n", strconv.Quote(sc.Message))
+			fmt.Fprintf(bw, "  steps: [%s]\n", strings.Join(steps, ", "))
+			fmt.Fprintf(bw, "  ...\n")
+		default:
+			fmt.Fprintf(bw, "ok %d - %s\n", i+1, desc)
+		}
+	}
+	return bw.Flush()
+}
+
+// tapEscaper escapes the characters of a test point description that TAP
+// would otherwise interpret.
+var tapEscaper = strings.NewReplacer(`\`, `\\`, "#", `\#`)
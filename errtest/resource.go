@@ -0,0 +1,57 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+// A Resource is a value opened in a simulation that holds a domain-specific
+// value of type T. Dares can embed a *Resource in the types they return, such
+// as a *Tx or a Conn, to give them Close and CloseWithError methods that are
+// checked by the simulation, while mirroring the shape of a real API.
+//
+// For instance, a dare simulating a database transaction could declare
+//
+//	type Tx struct {
+//		*errtest.Resource[*Conn]
+//	}
+//
+//	func (c *Conn) Begin() (*Tx, error) {
+//		r, err := errtest.OpenResource(c.s, "tx", c)
+//		return &Tx{r}, err
+//	}
+type Resource[T any] struct {
+	s     *Simulation
+	key   string
+	value T
+}
+
+// OpenResource opens a Resource with the given key and value. It returns the
+// error returned by Simulation.Open. The Resource is returned even if Open
+// returns an error, so that dares can verify it is not used.
+//
+// The Resource is tracked with Simulation.Track.
+func OpenResource[T any](s *Simulation, key string, v T, opts ...Option) (*Resource[T], error) {
+	err := s.Open(key, opts...)
+	r := &Resource[T]{s: s, key: key, value: v}
+	s.Track(key, r)
+	return r, err
+}
+
+// Simulation returns the simulation in which r was opened.
+func (r *Resource[T]) Simulation() *Simulation { return r.s }
+
+// Key returns the key with which r was opened.
+func (r *Resource[T]) Key() string { return r.key }
+
+// Value returns the value held by r.
+func (r *Resource[T]) Value() T { return r.value }
+
+// Close closes r as with Simulation.Close.
+func (r *Resource[T]) Close() error {
+	return r.s.Close(r.key)
+}
+
+// CloseWithError closes r as with Simulation.CloseWithError.
+func (r *Resource[T]) CloseWithError(err error) error {
+	return r.s.CloseWithError(r.key, err)
+}
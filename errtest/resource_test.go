@@ -0,0 +1,50 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "testing"
+
+type conn struct{ name string }
+
+type tx struct {
+	*Resource[*conn]
+}
+
+func TestResource(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(tx *tx) error
+		want string
+	}{{
+		desc: "closed",
+		f:    func(tx *tx) error { return tx.Close() },
+	}, {
+		desc: "closed with error",
+		f:    func(tx *tx) error { return tx.CloseWithError(nil) },
+	}, {
+		desc: "not closed",
+		f:    func(tx *tx) error { return nil },
+		want: `not closed: "tx"`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &conn{name: "db"}
+			failures := RunStandalone(nil, func(s *Simulation) error {
+				r, _ := OpenResource(s, "tx", c, NoError(), NoPanic(), CloseOptions(NoError(), NoPanic()))
+				if r.Key() != "tx" || r.Value() != c || r.Simulation() != s {
+					t.Errorf("got key %q, value %v; want %q, %v", r.Key(), r.Value(), "tx", c)
+				}
+				return tc.f(&tx{r})
+			})
+			got := ""
+			if len(failures) > 0 {
+				got = failures[0].Message
+			}
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Results holds the outcome of all scenarios run for a simulation.
+type Results struct {
+	Scenarios []Scenario
+
+	// Allocs is the average number of heap allocations of a run of the
+	// scenario without faults, if measured with Config.MeasureAllocs. It
+	// includes the allocations of the simulation itself, which grow with the
+	// number of steps executed, so that only differences between solutions
+	// executing the same steps are meaningful.
+	Allocs float64
+
+	// Truncated, if not nil, describes the scenarios that were not run
+	// because Config.Budget was exceeded.
+	Truncated *Truncation
+}
+
+// Failed reports the number of failed scenarios.
+func (r *Results) Failed() int {
+	n := 0
+	for _, sc := range r.Scenarios {
+		if sc.Failed {
+			n++
+		}
+	}
+	return n
+}
+
+// Failures returns a Failure for each failed scenario.
+func (r *Results) Failures() []Failure {
+	var failures []Failure
+	for _, sc := range r.Scenarios {
+		if sc.Failed {
+			failures = append(failures, Failure{
+				Scenario: sc.Index,
+				Faults:   sc.Faults(),
+				Kind:     sc.Kind,
+				Message:  sc.Message,
+			})
+		}
+	}
+	return failures
+}
+
+// Groups returns the failures grouped by message, in order of the first
+// scenario of each group. Failures with the same message typically share the
+// same root cause.
+func (r *Results) Groups() []FailureGroup {
+	var groups []FailureGroup
+	index := map[string]int{}
+	for _, f := range r.Failures() {
+		i, ok := index[f.Message]
+		if !ok {
+			i = len(groups)
+			index[f.Message] = i
+			groups = append(groups, FailureGroup{Message: f.Message})
+		}
+		groups[i].Failures = append(groups[i].Failures, f)
+	}
+	return groups
+}
+
+// CoverageGains returns the scenarios that increased code coverage, that is,
+// the scenarios that exercised code not exercised by any earlier scenario.
+// It requires Config.Coverage to be set.
+func (r *Results) CoverageGains() []Scenario {
+	var gains []Scenario
+	for _, sc := range r.Scenarios {
+		if sc.CoverageGain > 0 {
+			gains = append(gains, sc)
+		}
+	}
+	return gains
+}
+
+// Skipped reports the number of skipped scenarios.
+func (r *Results) Skipped() int {
+	n := 0
+	for _, sc := range r.Scenarios {
+		if sc.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary returns a human-readable overview of the results, including how
+// often each fault was involved in a failed scenario.
+func (r *Results) Summary() string {
+	b := &strings.Builder{}
+	failed := r.Failed()
+	fmt.Fprintf(b, "%d scenarios: %d failed, %d skipped",
+		len(r.Scenarios), failed, r.Skipped())
+	if r.Allocs > 0 {
+		fmt.Fprintf(b, "; %.1f allocations per run without faults", r.Allocs)
+	}
+
+	if r.Truncated != nil {
+		fmt.Fprintf(b, "\n%s", r.Truncated)
+	}
+
+	stats := r.FaultStats()
+	none := 0
+	for _, sc := range r.Scenarios {
+		if sc.Failed && len(sc.Faults()) == 0 {
+			none++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(b, "\nfailures by fault:")
+	}
+	for _, st := range stats {
+		if st.Failed > 0 {
+			fmt.Fprintf(b, "\n\t%v: %d of %d", st.Step, st.Failed, st.Total)
+		}
+	}
+	if none > 0 {
+		fmt.Fprintf(b, "\n\t(no faults): %d", none)
+	}
+	for _, st := range stats {
+		if failed > 0 && st.Failed == failed {
+			fmt.Fprintf(b, "\nall failures involve %v", st.Step)
+		}
+	}
+	return b.String()
+}
+
+// A FaultStat reports how often a fault was simulated and how often the
+// scenarios simulating it failed.
+type FaultStat struct {
+	Step
+
+	// Total is the number of scenarios in which the fault was simulated.
+	Total int
+
+	// Failed is the number of those scenarios that failed.
+	Failed int
+}
+
+// FaultStats returns statistics for each fault simulated in any scenario,
+// ordered by decreasing number of failures. A fault involved in many failures
+// is likely mishandled by the tested code.
+func (r *Results) FaultStats() []FaultStat {
+	index := map[Step]int{}
+	var stats []FaultStat
+	for _, sc := range r.Scenarios {
+		for _, f := range sc.Faults() {
+			i, ok := index[f]
+			if !ok {
+				i = len(stats)
+				index[f] = i
+				stats = append(stats, FaultStat{Step: f})
+			}
+			stats[i].Total++
+			if sc.Failed {
+				stats[i].Failed++
+			}
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Failed != b.Failed {
+			return a.Failed > b.Failed
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Mode < b.Mode
+	})
+	return stats
+}
+
+// A Scenario describes a single run of a simulation.
+type Scenario struct {
+	// Index is the position of the scenario in the order in which it was run.
+	Index int
+
+	// Schedule is the number of the schedule with which the goroutines of
+	// the scenario were interleaved. See Config.Interleavings.
+	Schedule int
+
+	// Steps lists the executed steps in order, including closes.
+	Steps []Step
+
+	// Failed reports whether the scenario did not meet its expectations.
+	// Kind and Message describe the first failure reported in that case.
+	Failed  bool
+	Kind    FailureKind
+	Message string
+
+	// Skipped reports whether the scenario was skipped, for instance because
+	// of Config.SkipErrors. SkipReason holds the reason passed to
+	// Simulation.SkipScenario, if the scenario was skipped by it.
+	Skipped    bool
+	SkipReason string
+
+	// Cached reports whether the scenario was not run because it passed in
+	// a previous run. See Config.OnlyNew.
+	Cached bool
+
+	// CoverageGain is the increase in statement coverage caused by running
+	// the scenario. It is only set if Config.Coverage is set.
+	CoverageGain float64
+}
+
+// Faults returns the steps for which an error or panic was injected.
+func (sc *Scenario) Faults() []Step {
+	var faults []Step
+	for _, st := range sc.Steps {
+		if st.Mode != ModeNoError {
+			faults = append(faults, st)
+		}
+	}
+	return faults
+}
+
+// Tags returns tags classifying the faults of the scenario. For each fault,
+// these are its key, its mode in lower case, as in "error" or "panic", and
+// its mode prefixed with "close-" or "body-", depending on whether the fault
+// occurred in the close of a value. A scenario without faults has the single
+// tag "nofault".
+func (sc *Scenario) Tags() []string {
+	faults := sc.Faults()
+	if len(faults) == 0 {
+		return []string{"nofault"}
+	}
+	var tags []string
+	seen := map[string]bool{}
+	add := func(tag string) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, st := range faults {
+		mode := strings.ToLower(st.Mode.String())
+		where := "body-"
+		if st.IsClose() {
+			where = "close-"
+		}
+		add(st.Key)
+		add(mode)
+		add(where + mode)
+	}
+	return tags
+}
+
+// Matches reports whether the scenario has any of the given tags. See Tags.
+// Tags are matched case-insensitively. A scenario matches any empty list.
+func (sc *Scenario) Matches(tags ...string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, have := range sc.Tags() {
+		for _, want := range tags {
+			if strings.EqualFold(have, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A Step is a single executed statement of a simulation and the outcome
+// simulated for it.
+type Step struct {
+	Key  string
+	Mode Mode
+}
+
+func (st Step) String() string { return st.Key + "=" + st.Mode.String() }
+
+// A Failure describes a scenario that did not meet its expectations.
+type Failure struct {
+	// Scenario is the index of the failed scenario.
+	Scenario int
+
+	// Faults lists the errors and panics injected in the scenario.
+	Faults []Step
+
+	Kind    FailureKind
+	Message string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("scenario %d %v: %s", f.Scenario, f.Faults, f.Message)
+}
+
+// A FailureGroup holds failures that reported the same message.
+type FailureGroup struct {
+	Message  string
+	Failures []Failure
+}
+
+func (g FailureGroup) String() string {
+	if len(g.Failures) == 1 {
+		return g.Failures[0].String()
+	}
+	first := g.Failures[0]
+	return fmt.Sprintf("%d scenarios failed: %s (first: scenario %d %v)",
+		len(g.Failures), g.Message, first.Scenario, first.Faults)
+}
+
+// A FailureKind classifies the failure of a scenario.
+type FailureKind int
+
+const (
+	NoFailure        FailureKind = iota
+	OtherFailure                 // reported by Simulation.Fatalf
+	WrongError                   // the wrong error was returned or passed to a close
+	UnexpectedPanic              // a panic occurred that was not simulated
+	WrongCloseOrder              // values were not closed in reverse order of opening
+	DoubleClose                  // a value was closed more than once
+	Leak                         // a value or goroutine was not cleaned up
+	Unchecked                    // a simulated error was not inspected
+	Unreached                    // a required step was not executed
+	NonDeterministic             // a scenario did not replay the steps of an earlier one
+	DuplicateStep                // a step was executed more than once
+	TooManySteps                 // a scenario exceeded Config.MaxSteps
+	Misuse                       // the simulation API was used incorrectly
+	IgnoredCancel                // a step was executed after a simulated cancellation
+	UsedPartial                  // a partial value returned along with an error was used
+	BadWrapping                  // the returned error was wrapped too deeply or without context
+	NotOwned                     // a value declared with MustNotClose was closed
+	RecoveredPanic               // a simulated panic was recovered and not raised again
+	ChangedPanic                 // a simulated panic was raised again with a value not wrapping it
+	UseAfterClose                // a value was used after it was closed or failed to open
+)
+
+var failureKindNames = []string{
+	NoFailure:        "NoFailure",
+	OtherFailure:     "OtherFailure",
+	WrongError:       "WrongError",
+	UnexpectedPanic:  "UnexpectedPanic",
+	WrongCloseOrder:  "WrongCloseOrder",
+	DoubleClose:      "DoubleClose",
+	Leak:             "Leak",
+	Unchecked:        "Unchecked",
+	Unreached:        "Unreached",
+	NonDeterministic: "NonDeterministic",
+	DuplicateStep:    "DuplicateStep",
+	TooManySteps:     "TooManySteps",
+	Misuse:           "Misuse",
+	IgnoredCancel:    "IgnoredCancel",
+	UsedPartial:      "UsedPartial",
+	BadWrapping:      "BadWrapping",
+	NotOwned:         "NotOwned",
+	RecoveredPanic:   "RecoveredPanic",
+	ChangedPanic:     "ChangedPanic",
+	UseAfterClose:    "UseAfterClose",
+}
+
+func (k FailureKind) String() string {
+	if k < 0 || int(k) >= len(failureKindNames) {
+		return fmt.Sprintf("FailureKind(%d)", int(k))
+	}
+	return failureKindNames[k]
+}
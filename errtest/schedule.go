@@ -0,0 +1,240 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"bytes"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pollInterval is the interval at which the scheduler checks whether the
+// holder is blocked. It only affects how quickly a blocked holder is detected,
+// not which goroutine is chosen next.
+const pollInterval = time.Millisecond
+
+// mainGoroutine is the scheduler ID of the goroutine running the simulation
+// function.
+const mainGoroutine = 0
+
+// A scheduler interleaves the goroutines of a scenario deterministically. It
+// lets a single goroutine, the holder, run at a time. Each goroutine yields at
+// every step, upon which the scheduler chooses the next holder among the
+// goroutines waiting at a step, once all goroutines wait or are blocked.
+// A holder is blocked if the runtime reports it as waiting, for instance to
+// receive from a channel, rather than running or sleeping. As only the holder
+// runs, this does not depend on timing, as long as the goroutines that may
+// unblock the holder were started with Simulation.Go. All methods may be
+// called on a nil scheduler, which does nothing.
+type scheduler struct {
+	mu      sync.Mutex
+	ids     map[int64]int // scheduler IDs by runtime goroutine ID
+	goids   map[int]int64 // runtime goroutine IDs by scheduler ID
+	live    map[int]bool
+	parked  map[int]chan struct{} // goroutines waiting at a step
+	blocked map[int]bool          // goroutines found blocked
+	holder  int                   // -1 if no goroutine holds the scheduler
+	grants  int                   // number of times a holder was chosen
+	next    int                   // ID of the next goroutine
+	rnd     *rand.Rand            // nil for schedule 0
+}
+
+// newScheduler returns a scheduler for the given schedule, held by the calling
+// goroutine.
+func newScheduler(schedule int, seed int64) *scheduler {
+	id := goid()
+	s := &scheduler{
+		ids:     map[int64]int{id: mainGoroutine},
+		goids:   map[int]int64{mainGoroutine: id},
+		live:    map[int]bool{mainGoroutine: true},
+		parked:  map[int]chan struct{}{},
+		blocked: map[int]bool{},
+		next:    mainGoroutine + 1,
+	}
+	if schedule > 0 {
+		s.rnd = rand.New(rand.NewSource(seed + int64(schedule)))
+	}
+	s.mu.Lock()
+	s.grant(mainGoroutine)
+	s.mu.Unlock()
+	return s
+}
+
+// register returns the ID for a new goroutine, which must call start before
+// anything else and exit when done.
+func (s *scheduler) register() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	s.live[id] = true
+	return id
+}
+
+// start associates the calling goroutine with id and waits for its turn.
+func (s *scheduler) start(id int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.ids[goid()] = id
+	s.goids[id] = goid()
+	s.mu.Unlock()
+	s.yield()
+}
+
+// exit removes the goroutine with the given ID from the scheduler.
+func (s *scheduler) exit(id int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.live, id)
+	delete(s.parked, id)
+	delete(s.blocked, id)
+	if s.holder == id {
+		s.holder = -1
+	}
+	s.dispatch()
+}
+
+// yield waits until the calling goroutine is chosen to proceed. It returns
+// immediately for goroutines not started with Simulation.Go.
+func (s *scheduler) yield() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	id, ok := s.ids[goid()]
+	if !ok || !s.live[id] {
+		s.mu.Unlock()
+		return
+	}
+	if s.holder == id {
+		if !s.contended(id) {
+			s.mu.Unlock()
+			return
+		}
+		s.holder = -1
+	}
+	delete(s.blocked, id)
+	ch := make(chan struct{})
+	s.parked[id] = ch
+	s.dispatch()
+	s.mu.Unlock()
+	<-ch
+}
+
+// contended reports whether any goroutine other than id may be chosen at the
+// next step.
+func (s *scheduler) contended(id int) bool {
+	for g := range s.live {
+		if g != id && !s.blocked[g] {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch chooses the next holder if there is none and all goroutines are
+// waiting at a step or blocked. It must be called with s.mu held.
+func (s *scheduler) dispatch() {
+	if s.holder != -1 || len(s.parked) == 0 {
+		return
+	}
+	var ids []int
+	for id := range s.live {
+		if _, ok := s.parked[id]; ok {
+			ids = append(ids, id)
+		} else if !s.blocked[id] {
+			return // a new goroutine that has yet to reach its first step
+		}
+	}
+	sort.Ints(ids)
+	id := ids[0]
+	if s.rnd != nil && len(ids) > 1 {
+		id = ids[s.rnd.Intn(len(ids))]
+	}
+	close(s.parked[id])
+	delete(s.parked, id)
+	s.grant(id)
+}
+
+// grant makes id the holder. Once the holder is blocked without reaching its
+// next step, another goroutine is chosen. It must be called with s.mu held.
+func (s *scheduler) grant(id int) {
+	s.holder = id
+	s.grants++
+	s.watch(s.grants)
+}
+
+// watch checks every pollInterval whether the holder chosen by the given grant
+// is blocked, until another holder is chosen.
+func (s *scheduler) watch(grant int) {
+	time.AfterFunc(pollInterval, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := s.holder
+		if id == -1 || s.grants != grant {
+			return
+		}
+		if !waiting(s.goids[id]) {
+			s.watch(grant)
+			return
+		}
+		s.blocked[id] = true
+		s.holder = -1
+		s.dispatch()
+	})
+}
+
+// waiting reports whether the runtime reports the goroutine with the given ID
+// as waiting for another goroutine, as opposed to running, runnable, sleeping,
+// or in a system call.
+func waiting(id int64) bool {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	header := []byte("goroutine " + strconv.FormatInt(id, 10) + " [")
+	i := bytes.Index(buf, header)
+	if i < 0 {
+		return false // exited
+	}
+	state := buf[i+len(header):]
+	if j := bytes.IndexAny(state, ",]"); j >= 0 {
+		state = state[:j]
+	}
+	switch string(state) {
+	case "running", "runnable", "syscall", "sleep":
+		return false
+	}
+	return true
+}
+
+// goid returns the runtime ID of the calling goroutine.
+func goid() int64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
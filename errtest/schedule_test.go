@@ -0,0 +1,63 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInterleavings(t *testing.T) {
+	// f runs two goroutines that each execute two steps and records the
+	// order of the steps in the scenario without faults.
+	f := func(s *Simulation) error {
+		var wg sync.WaitGroup
+		for _, g := range []string{"a", "b"} {
+			g := g
+			wg.Add(1)
+			s.Go(func() {
+				defer wg.Done()
+				s.Open(g+"1", NoError(), NoPanic(), NoClose())
+				s.Open(g+"2", NoError(), NoPanic(), NoClose())
+			})
+		}
+		wg.Wait()
+		return nil
+	}
+	orders := func() []string {
+		r := RunReport(t, &Config{Interleavings: 8, Seed: 1}, f)
+		var orders []string
+		for _, sc := range r.Scenarios {
+			var keys []string
+			for _, st := range sc.Steps {
+				keys = append(keys, st.Key)
+			}
+			if sc.Schedule != len(orders) {
+				t.Errorf("got schedule %d; want %d", sc.Schedule, len(orders))
+			}
+			orders = append(orders, strings.Join(keys, " "))
+		}
+		return orders
+	}
+	got := orders()
+	if len(got) != 8 {
+		t.Fatalf("got %d scenarios; want 8", len(got))
+	}
+	if got[0] != "a1 a2 b1 b2" {
+		t.Errorf("schedule 0: got %q; want %q", got[0], "a1 a2 b1 b2")
+	}
+	distinct := map[string]bool{}
+	for _, o := range got {
+		distinct[o] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("all schedules ran the same interleaving %q", got[0])
+	}
+	if again := orders(); !reflect.DeepEqual(again, got) {
+		t.Errorf("interleavings not reproducible:\ngot  %q\nwant %q", again, got)
+	}
+}
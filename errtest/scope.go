@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "strings"
+
+// A Scope opens and closes values with keys relative to a parent key.
+//
+// Keys may be hierarchical, with the elements separated by slashes, as in
+// "client/conn/stream". A value with key "a/b" is a child of the value with
+// key "a". Children must be closed before their parent and may not be opened
+// through a Scope after their parent was closed.
+type Scope struct {
+	s      *Simulation
+	prefix string
+}
+
+// Scope returns a Scope for the children of the value with the given key.
+func (s *Simulation) Scope(key string) *Scope {
+	return &Scope{s: s, prefix: key}
+}
+
+// Key returns the full key of the child with the given name.
+func (sc *Scope) Key(name string) string {
+	return sc.prefix + "/" + name
+}
+
+// Scope returns a Scope for the children of the child with the given name.
+func (sc *Scope) Scope(name string) *Scope {
+	return sc.s.Scope(sc.Key(name))
+}
+
+// Open is like Simulation.Open for the child with the given name. It fails
+// the scenario if the parent was already closed.
+func (sc *Scope) Open(name string, opts ...Option) error {
+	for _, f := range sc.s.exec {
+		if f.key == sc.prefix && f.closed {
+			sc.s.fail(Misuse, "%s opened after its parent %s was closed", sc.s.quote(sc.Key(name)), sc.s.quote(sc.prefix))
+			return nil
+		}
+	}
+	return sc.s.Open(sc.Key(name), opts...)
+}
+
+// Close is like Simulation.Close for the child with the given name.
+func (sc *Scope) Close(name string, opts ...Option) error {
+	return sc.s.Close(sc.Key(name), opts...)
+}
+
+// CloseWithError is like Simulation.CloseWithError for the child with the
+// given name.
+func (sc *Scope) CloseWithError(name string, err error, opts ...Option) error {
+	return sc.s.CloseWithError(sc.Key(name), err, opts...)
+}
+
+// isChild reports whether key denotes a descendant of parent.
+func isChild(key, parent string) bool {
+	return strings.HasPrefix(key, parent+"/")
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "testing"
+
+func TestScope(t *testing.T) {
+	opts := []Option{NoError(), NoPanic(), CloseOptions(NoError(), NoPanic())}
+	testCases := []struct {
+		desc string
+		f    func(s *Simulation) error
+		want string
+	}{{
+		desc: "child closed first",
+		f: func(s *Simulation) error {
+			s.Open("client", opts...)
+			conn := s.Scope("client")
+			conn.Open("conn", opts...)
+			conn.Scope("conn").Open("stream", opts...)
+			s.Close("client/conn/stream")
+			conn.Close("conn")
+			return s.Close("client")
+		},
+	}, {
+		desc: "parent closed first",
+		f: func(s *Simulation) error {
+			s.Open("client", opts...)
+			s.Scope("client").Open("conn", opts...)
+			return s.Close("client")
+		},
+		want: `"client" closed before its child "client/conn"`,
+	}, {
+		desc: "child opened after parent closed",
+		f: func(s *Simulation) error {
+			s.Open("client", opts...)
+			s.Close("client")
+			s.Scope("client").Open("conn", opts...)
+			return nil
+		},
+		want: `"client/conn" opened after its parent "client" was closed`,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			failures := RunStandalone(nil, tc.f)
+			got := ""
+			if len(failures) > 0 {
+				got = failures[0].Message
+			}
+			if got != tc.want {
+				t.Errorf("got %q; want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simfs provides a file system in which operations on selected paths
+// are steps of an errtest.Simulation.
+//
+// This allows running actual file-handling code against the simulation. For
+// instance,
+//
+//	errtest.Run(t, nil, func(s *errtest.Simulation) error {
+//		fsys := simfs.New(s, fstest.MapFS{
+//			"config.json": {Data: []byte(`{}`)},
+//		}, "config.json")
+//		return loadConfig(fsys, "config.json")
+//	})
+//
+// tests that loadConfig handles failing and panicking opens, reads, and
+// closes of config.json and closes the file in all cases.
+//
+// The steps for a path p are keyed as follows:
+//
+//	p          opening p with Open or Create; closed by closing the file
+//	p.read     a call to Read on a file opened with Open
+//	p.write    a call to Write on a file opened with Create
+//	p.stat     a call to Stat on the file system
+//
+// A path may be opened again, for instance to read back a file that was just
+// written. The second file of p is keyed p#1, its reads p#1.read, and so on,
+// so that each file is closed by its own key, even if the files of a path are
+// closed out of order. Repeated reads and writes of a file are keyed
+// p.read#1, p.read#2, and so on.
+//
+// Errors of simulated steps are returned as is, rather than in an
+// fs.PathError, as the simulation must recognize the error it injected.
+// Closing a file opened with Open may fail without the error being handled,
+// as a file that was only read has nothing left to lose. Closing a file
+// created with Create must be checked: its contents are only added to the
+// file system by a successful close. Options for specific steps can be set
+// with errtest.Config.KeyOptions.
+package simfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"testing/fstest"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// An FS is an fs.FS that simulates operations on selected paths. Operations
+// on other paths are passed to the underlying file system without
+// simulation.
+//
+// Files written with Create are added to the file system when they are
+// closed successfully and shadow the files of the underlying file system.
+type FS struct {
+	s     *errtest.Simulation
+	fsys  fs.FS
+	paths map[string]bool // nil if all paths are simulated
+
+	mu      sync.Mutex
+	written fstest.MapFS
+}
+
+var (
+	_ fs.FS     = (*FS)(nil)
+	_ fs.StatFS = (*FS)(nil)
+)
+
+// New returns an FS that simulates operations on the given paths of fsys,
+// or on all paths if none are given.
+func New(s *errtest.Simulation, fsys fs.FS, paths ...string) *FS {
+	f := &FS{s: s, fsys: fsys, written: fstest.MapFS{}}
+	if len(paths) > 0 {
+		f.paths = map[string]bool{}
+		for _, p := range paths {
+			f.paths[p] = true
+		}
+	}
+	return f
+}
+
+func (f *FS) simulated(name string) bool {
+	return f.paths == nil || f.paths[name]
+}
+
+// lookup returns the file system that holds name.
+func (f *FS) lookup(name string) fs.FS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.written[name]; ok {
+		return f.written
+	}
+	return f.fsys
+}
+
+// Open implements fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if !f.simulated(name) {
+		return f.lookup(name).Open(name)
+	}
+	key := name
+	if err := f.s.Open(name, errtest.Iterate(), errtest.StoreKey(&key), errtest.CloseOptions(errtest.IgnoreError())); err != nil {
+		return nil, err
+	}
+	file, err := f.lookup(name).Open(name)
+	if err != nil {
+		// The file was not opened, so there is nothing to close.
+		f.s.Close(key, errtest.NoError(), errtest.NoPanic())
+		return nil, err
+	}
+	return &File{f: f, key: key, file: file}, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if f.simulated(name) {
+		if err := f.s.Open(name+".stat", errtest.Iterate(), errtest.NoClose()); err != nil {
+			return nil, err
+		}
+	}
+	return fs.Stat(f.lookup(name), name)
+}
+
+// Create creates or truncates the named file for writing.
+func (f *FS) Create(name string) (*Writer, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	w := &Writer{f: f, name: name, key: name, sim: f.simulated(name)}
+	if w.sim {
+		if err := f.s.Open(name, errtest.Iterate(), errtest.StoreKey(&w.key)); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// WriteFile writes data to the named file, creating it if necessary. The file
+// is only added to the file system if all of its data was written.
+func (f *FS) WriteFile(name string, data []byte) (err error) {
+	w, err := f.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			errP, ok := r.(error)
+			if !ok {
+				errP = fmt.Errorf("panic: %v", r)
+			}
+			w.CloseWithError(errP)
+			panic(r)
+		}
+		if cerr := w.CloseWithError(err); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = w.Write(data)
+	return err
+}
+
+// A File is a file of an FS opened for reading.
+type File struct {
+	f    *FS
+	key  string // the key of the opening of the file
+	file fs.File
+}
+
+// Stat implements fs.File. It is not simulated.
+func (f *File) Stat() (fs.FileInfo, error) {
+	return f.file.Stat()
+}
+
+// Read implements fs.File. Reads at the end of the file are not simulated.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	if n == 0 && err == io.EOF {
+		return n, err
+	}
+	if err := f.f.s.Op(f.key, "read"); err != nil {
+		return 0, err
+	}
+	return n, err
+}
+
+// Close implements fs.File. The underlying file is closed even if the
+// simulated close panics.
+func (f *File) Close() error {
+	defer f.file.Close()
+	return f.f.s.Close(f.key)
+}
+
+// A Writer is a file of an FS opened for writing.
+type Writer struct {
+	f    *FS
+	name string
+	key  string // the key of the opening of the file
+	sim  bool
+	buf  bytes.Buffer
+}
+
+// Write writes p to the file.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.sim {
+		if err := w.f.s.Op(w.key, "write"); err != nil {
+			return 0, err
+		}
+	}
+	return w.buf.Write(p)
+}
+
+// Close closes the file and adds it to the file system if the close
+// succeeds.
+func (w *Writer) Close() error {
+	var err error
+	if w.sim {
+		err = w.f.s.Close(w.key)
+	}
+	return w.commit(err)
+}
+
+// CloseWithError closes the file. The file is only added to the file system
+// if err is nil and the close succeeds.
+func (w *Writer) CloseWithError(err error) error {
+	var cerr error
+	if w.sim {
+		cerr = w.f.s.CloseWithError(w.key, err)
+	}
+	if err != nil {
+		return cerr
+	}
+	return w.commit(cerr)
+}
+
+// commit adds the written data to the file system if err is nil and returns
+// err.
+func (w *Writer) commit(err error) error {
+	if err != nil {
+		return err
+	}
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	w.f.written[w.name] = &fstest.MapFile{
+		Data:    append([]byte(nil), w.buf.Bytes()...),
+		Mode:    0666,
+		ModTime: w.f.s.Clock().Now(),
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package simfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func newFS(s *errtest.Simulation, paths ...string) *FS {
+	return New(s, fstest.MapFS{
+		"a.txt": {Data: []byte("a")},
+		"b.txt": {Data: []byte("b")},
+	}, paths...)
+}
+
+func TestFS(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		paths     []string
+		f         func(fsys *FS) error
+		scenarios int
+		failed    int
+	}{{
+		desc:  "read file",
+		paths: []string{"a.txt"},
+		f: func(fsys *FS) error {
+			_, err := fs.ReadFile(fsys, "a.txt")
+			return err
+		},
+		// The open may fail or panic. Otherwise, the deferred close is
+		// simulated for each outcome of the read.
+		scenarios: 2 + 3*3,
+	}, {
+		desc:  "paths not simulated",
+		paths: []string{"a.txt"},
+		f: func(fsys *FS) error {
+			b, err := fs.ReadFile(fsys, "b.txt")
+			if string(b) != "b" {
+				return errors.New("unexpected contents")
+			}
+			return err
+		},
+		scenarios: 1,
+	}, {
+		desc:  "file not closed",
+		paths: []string{"a.txt"},
+		f: func(fsys *FS) error {
+			_, err := fsys.Open("a.txt")
+			return err
+		},
+		scenarios: 3,
+		failed:    1,
+	}, {
+		desc: "missing file",
+		f: func(fsys *FS) error {
+			_, err := fsys.Open("c.txt")
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		},
+		scenarios: 3,
+	}, {
+		desc: "write file",
+		f: func(fsys *FS) error {
+			if err := fsys.WriteFile("c.txt", []byte("c")); err != nil {
+				return err
+			}
+			f, err := fsys.Open("c.txt")
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			if err == nil && string(b) != "c" {
+				return errors.New("unexpected contents")
+			}
+			return err
+		},
+		// The create may fail or panic. Otherwise, the deferred close is
+		// simulated for each outcome of the write. If the write and close
+		// succeed, the file is read as in "read file".
+		scenarios: 2 + 2*3 + 2 + (2 + 3*3),
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := &errtest.Config{ExpectFailure: tc.failed > 0}
+			r := errtest.RunReport(t, cfg, func(s *errtest.Simulation) error {
+				return tc.f(newFS(s, tc.paths...))
+			})
+			if got := len(r.Scenarios); got != tc.scenarios {
+				t.Errorf("got %d scenarios; want %d", got, tc.scenarios)
+			}
+			if got := r.Failed(); got != tc.failed {
+				t.Errorf("got %d failures; want %d:\n%s", got, tc.failed, r.Summary())
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := []byte("written")
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		fsys := newFS(s, "c.txt")
+		if err := fsys.WriteFile("c.txt", data); err != nil {
+			// A failed write or close must not add the file.
+			if _, ok := fsys.written["c.txt"]; ok {
+				t.Errorf("%v: file added after %v", s.Scenario().Steps, err)
+			}
+			return err
+		}
+		b, err := fs.ReadFile(fsys, "c.txt")
+		if err == nil && string(b) != string(data) {
+			t.Errorf("%v: read %q; want %q", s.Scenario().Steps, b, data)
+		}
+		return err
+	})
+}
+
+func TestErrorAttribution(t *testing.T) {
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		_, err := fs.ReadFile(newFS(s), "a.txt")
+		if err != nil {
+			// The error is that of the first failed step, not wrapped.
+			sc := s.Scenario()
+			faults := sc.Faults()
+			if len(faults) == 0 {
+				t.Fatalf("%v: unexpected error %v", s.Scenario().Steps, err)
+			}
+			if want := faults[0].Key + ": Error"; err.Error() != want {
+				t.Errorf("%v: got error %q; want %q", s.Scenario().Steps, err, want)
+			}
+		}
+		return err
+	})
+}
+
+func TestGenerationKeys(t *testing.T) {
+	// Each file is read by the key of its own opening.
+	r := errtest.RunReport(t, nil, func(s *errtest.Simulation) (err error) {
+		fsys := newFS(s)
+		var files []fs.File
+		defer func() {
+			for i := len(files) - 1; i >= 0; i-- {
+				files[i].Close()
+			}
+		}()
+		for i := 0; i < 2; i++ {
+			f, err := fsys.Open("a.txt")
+			if err != nil {
+				return err
+			}
+			files = append(files, f)
+		}
+		for _, f := range files {
+			if _, err := io.ReadAll(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	got := fmt.Sprint(r.Scenarios[0].Steps)
+	want := "[a.txt=NoError a.txt#1=NoError a.txt.read=NoError a.txt#1.read=NoError a.txt#1.close=NoError a.txt.close=NoError]"
+	if got != want {
+		t.Errorf("got steps %s; want %s", got, want)
+	}
+}
+
+// countFS is a file system that counts the files that are open.
+type countFS struct {
+	fs.FS
+	open *int
+}
+
+func (c countFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err == nil {
+		*c.open++
+		f = countFile{f, c.open}
+	}
+	return f, err
+}
+
+type countFile struct {
+	fs.File
+	open *int
+}
+
+func (c countFile) Close() error {
+	*c.open--
+	return c.File.Close()
+}
+
+func TestClosePanic(t *testing.T) {
+	open := 0
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		fsys := New(s, countFS{fstest.MapFS{"a.txt": {Data: []byte("a")}}, &open})
+		_, err := fs.ReadFile(fsys, "a.txt")
+		return err
+	})
+	if open != 0 {
+		t.Errorf("%d files not closed", open)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simhttp provides an http.RoundTripper whose operations are steps of
+// an errtest.Simulation.
+//
+// Requests are served by an http.Handler without using the network, so that
+// code using a real *http.Client can be run against the simulation to verify
+// that it closes response bodies and handles failed requests and reads.
+//
+// The steps for a request with method m and URL path p are keyed as follows:
+//
+//	m p        executing the request; closed by closing the response body
+//	m p.read   a call to Read on the response body
+//
+// Reads at the end of the body are not simulated. A request that is sent
+// again, for instance by a client polling a resource, gets a key of its own:
+// the second request of GET /a is keyed GET /a#1 and the reads of its body
+// GET /a#1.read, so that each response is closed and read by its own key.
+//
+// Errors closing a response body may be ignored: the response was already
+// received, and a failed close only keeps the connection from being reused.
+//
+// An http.Client wraps the errors of its transport in a *url.Error, so
+// simulations using it should set errtest.Config.AllowWrapping.
+package simhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Transport is an http.RoundTripper that serves requests with a handler.
+type Transport struct {
+	s *errtest.Simulation
+	h http.Handler
+}
+
+// New returns a Transport that serves requests with h.
+func New(s *errtest.Simulation, h http.Handler) *Transport {
+	return &Transport{s: s, h: h}
+}
+
+// Client returns an http.Client that uses t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Key returns the key of the steps for req.
+func Key(req *http.Request) string {
+	return req.Method + " " + req.URL.Path
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := Key(req)
+	if err := t.s.Open(key, errtest.Iterate(), errtest.StoreKey(&key), errtest.CloseOptions(errtest.IgnoreError())); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	w := httptest.NewRecorder()
+	t.h.ServeHTTP(w, req)
+	resp := w.Result()
+	resp.Request = req
+	resp.Body = &body{s: t.s, key: key, body: resp.Body}
+	return resp, nil
+}
+
+// body is a response body whose reads are simulated.
+type body struct {
+	s    *errtest.Simulation
+	key  string
+	body io.ReadCloser
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n == 0 && err == io.EOF {
+		return n, err
+	}
+	if err := b.s.Op(b.key, "read"); err != nil {
+		return 0, err
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	defer b.body.Close()
+	return b.s.Close(b.key)
+}
@@ -0,0 +1,148 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package simhttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/missing" {
+		http.NotFound(w, r)
+		return
+	}
+	io.WriteString(w, "hello")
+}
+
+func get(c *http.Client, url string) (string, error) {
+	resp, err := c.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	return string(b), err
+}
+
+func TestTransport(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		f         func(c *http.Client) error
+		scenarios int
+		failed    int
+	}{{
+		desc: "get",
+		f: func(c *http.Client) error {
+			s, err := get(c, "http://example.com/hello")
+			if err == nil && s != "hello" {
+				return fmt.Errorf("got %q; want %q", s, "hello")
+			}
+			return err
+		},
+		// The request may fail or panic. Otherwise, the deferred close is
+		// simulated for each outcome of the read.
+		scenarios: 2 + 3*3,
+	}, {
+		desc: "status",
+		f: func(c *http.Client) error {
+			_, err := get(c, "http://example.com/missing")
+			if err == nil || !strings.Contains(err.Error(), "404") {
+				return err
+			}
+			return nil
+		},
+		scenarios: 2 + 3,
+	}, {
+		desc: "body not closed",
+		f: func(c *http.Client) error {
+			_, err := c.Get("http://example.com/hello")
+			return err
+		},
+		scenarios: 3,
+		failed:    1,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := &errtest.Config{
+				AllowWrapping: true,
+				ExpectFailure: tc.failed > 0,
+			}
+			r := errtest.RunReport(t, cfg, func(s *errtest.Simulation) error {
+				return tc.f(New(s, http.HandlerFunc(handler)).Client())
+			})
+			if got := len(r.Scenarios); got != tc.scenarios {
+				t.Errorf("got %d scenarios; want %d", got, tc.scenarios)
+			}
+			if got := r.Failed(); got != tc.failed {
+				t.Errorf("got %d failures; want %d:\n%s", got, tc.failed, r.Summary())
+			}
+		})
+	}
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+}
+
+func TestRoundTrip(t *testing.T) {
+	errtest.RunReport(t, &errtest.Config{AllowWrapping: true}, func(s *errtest.Simulation) error {
+		c := New(s, http.HandlerFunc(echo)).Client()
+		resp, err := c.Post("http://example.com/echo", "text/plain", strings.NewReader("ping"))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err == nil && string(b) != "ping" {
+			t.Errorf("got body %q; want %q", b, "ping")
+		}
+		return err
+	})
+}
+
+func TestErrorAttribution(t *testing.T) {
+	errtest.RunReport(t, &errtest.Config{AllowWrapping: true}, func(s *errtest.Simulation) error {
+		_, err := get(New(s, http.HandlerFunc(handler)).Client(), "http://example.com/hello")
+		if err != nil {
+			// The error is, or wraps, that of the first failed step.
+			sc := s.Scenario()
+			faults := sc.Faults()
+			if len(faults) == 0 {
+				t.Fatalf("%v: unexpected error %v", sc.Steps, err)
+			}
+			if want := faults[0].Key + ": Error"; !strings.HasSuffix(err.Error(), want) {
+				t.Errorf("%v: got error %q; want %q", sc.Steps, err, want)
+			}
+		}
+		return err
+	})
+}
+
+func TestRepeatedRequest(t *testing.T) {
+	r := errtest.RunReport(t, &errtest.Config{AllowWrapping: true}, func(s *errtest.Simulation) error {
+		c := New(s, http.HandlerFunc(handler)).Client()
+		for i := 0; i < 2; i++ {
+			if _, err := get(c, "http://example.com/hello"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	got := fmt.Sprint(r.Scenarios[0].Steps)
+	want := "[GET /hello=NoError GET /hello.read=NoError GET /hello.close=NoError " +
+		"GET /hello#1=NoError GET /hello#1.read=NoError GET /hello#1.close=NoError]"
+	if got != want {
+		t.Errorf("got steps %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simnet provides network connections and listeners whose operations
+// are steps of an errtest.Simulation.
+//
+// The adapters wrap real connections, such as those created by net.Pipe or a
+// loopback listener, so that network-handling code can be run against the
+// simulation unmodified. Typically, only the side under test is wrapped.
+//
+// The steps for a connection with key k are keyed as follows:
+//
+//	k            dialing or accepting the connection; closed by Close
+//	k.read       a call to Read
+//	k.write      a call to Write
+//	k.deadline   a call to SetDeadline, SetReadDeadline, or SetWriteDeadline
+//
+// A Dialer keys connections by the dialed address. A Listener with key k is
+// opened and closed with key k and keys the connections it accepts as k.conn.
+// Clients that reconnect dial the same address again: the second connection
+// to address a is keyed a#1, its reads a#1.read, and so on, so that the steps
+// of each connection can be told apart in scenarios and KeyOptions. Likewise,
+// the connections a server accepts are keyed k.conn, k.conn#1, and so on.
+//
+// Errors closing connections and listeners may be ignored: once a
+// connection is done, whether the peer received the data is for the
+// protocol spoken over it to confirm, not for Close.
+//
+// Errors of simulated steps are returned as is, rather than in a
+// net.OpError, as the simulation must recognize the error it injected.
+// Errors of the underlying connections are returned unchanged.
+package simnet
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Dialer dials connections whose operations are simulation steps.
+type Dialer struct {
+	s    *errtest.Simulation
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// NewDialer returns a Dialer that creates the underlying connections with
+// dial. If dial is nil, connections are dialed with a zero net.Dialer.
+func NewDialer(s *errtest.Simulation, dial func(ctx context.Context, network, address string) (net.Conn, error)) *Dialer {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return &Dialer{s: s, dial: dial}
+}
+
+// Dial connects to the address on the named network.
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address on the named network using ctx.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return open(d.s, address, func() (net.Conn, error) {
+		return d.dial(ctx, network, address)
+	})
+}
+
+// open opens a connection with the given key and creates the underlying
+// connection with f.
+func open(s *errtest.Simulation, key string, f func() (net.Conn, error)) (net.Conn, error) {
+	if err := s.Open(key, errtest.Iterate(), errtest.StoreKey(&key), errtest.CloseOptions(errtest.IgnoreError())); err != nil {
+		return nil, err
+	}
+	c, err := f()
+	if err != nil {
+		// The connection was not established, so there is nothing to close.
+		s.Close(key, errtest.NoError(), errtest.NoPanic())
+		return nil, err
+	}
+	return &Conn{s: s, key: key, conn: c}, nil
+}
+
+// A Listener is a net.Listener whose operations are simulation steps.
+type Listener struct {
+	s   *errtest.Simulation
+	key string
+	l   net.Listener
+}
+
+// NewListener returns a Listener with the given key that accepts connections
+// from l. As l is already listening, opening the Listener cannot fail, but it
+// must be closed.
+func NewListener(s *errtest.Simulation, key string, l net.Listener) *Listener {
+	s.Open(key, errtest.NoError(), errtest.NoPanic(), errtest.CloseOptions(errtest.IgnoreError()))
+	return &Listener{s: s, key: key, l: l}
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	return open(l.s, l.key+".conn", l.l.Accept)
+}
+
+// Close closes the listener.
+func (l *Listener) Close() error {
+	defer l.l.Close()
+	return l.s.Close(l.key)
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr { return l.l.Addr() }
+
+// A Conn is a net.Conn whose operations are simulation steps.
+type Conn struct {
+	s    *errtest.Simulation
+	key  string
+	conn net.Conn
+}
+
+// Key returns the key of the connection, including its generation suffix,
+// such as a#1 for the second connection to address a.
+func (c *Conn) Key() string { return c.key }
+
+// Read reads data from the connection. Reads at the end of the stream are not
+// simulated.
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.conn.Read(b)
+	if n == 0 && err == io.EOF {
+		return n, err
+	}
+	if err := c.s.Op(c.key, "read"); err != nil {
+		return 0, err
+	}
+	return n, err
+}
+
+// Write writes data to the connection.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.s.Op(c.key, "write"); err != nil {
+		return 0, err
+	}
+	return c.conn.Write(b)
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	defer c.conn.Close()
+	return c.s.Close(c.key)
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr { return c.conn.LocalAddr() }
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// SetDeadline sets the read and write deadlines of the connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.s.Op(c.key, "deadline"); err != nil {
+		return err
+	}
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	if err := c.s.Op(c.key, "deadline"); err != nil {
+		return err
+	}
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline of the connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	if err := c.s.Op(c.key, "deadline"); err != nil {
+		return err
+	}
+	return c.conn.SetWriteDeadline(t)
+}
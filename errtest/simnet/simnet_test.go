@@ -0,0 +1,215 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package simnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// echo returns the client end of a pipe whose server end echoes its input.
+func echo(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		io.Copy(server, server)
+	}()
+	return client, nil
+}
+
+func ping(d *Dialer) error {
+	c, err := d.Dial("pipe", "server")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.SetDeadline(time.Now().Add(time.Second)); err != nil {
+		return err
+	}
+	if _, err := c.Write([]byte("ping")); err != nil {
+		return err
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(c, b); err != nil {
+		return err
+	}
+	if string(b) != "ping" {
+		return errors.New("unexpected response")
+	}
+	return nil
+}
+
+// pipeListener is a net.Listener that accepts the server ends of pipes.
+type pipeListener chan net.Conn
+
+func (l pipeListener) Accept() (net.Conn, error) {
+	c, ok := <-l
+	if !ok {
+		return nil, net.ErrClosed
+	}
+	return c, nil
+}
+
+func (l pipeListener) Close() error   { return nil }
+func (l pipeListener) Addr() net.Addr { return nil }
+
+func serve(l net.Listener) error {
+	c, err := l.Accept()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, err = c.Write([]byte("hello"))
+	return err
+}
+
+func TestNet(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		f         func(s *errtest.Simulation) error
+		scenarios int
+		failed    int
+	}{{
+		desc: "dial",
+		f: func(s *errtest.Simulation) error {
+			return ping(NewDialer(s, echo))
+		},
+		// The dial may fail or panic. Otherwise, the deferred close is
+		// simulated for each failure of the other steps and for success.
+		scenarios: 2 + 3*(2+2+2+1),
+	}, {
+		desc: "dial fails",
+		f: func(s *errtest.Simulation) error {
+			refused := errors.New("connection refused")
+			_, err := NewDialer(s, func(context.Context, string, string) (net.Conn, error) {
+				return nil, refused
+			}).Dial("tcp", "server")
+			if err == refused {
+				return nil
+			}
+			return err
+		},
+		scenarios: 3,
+	}, {
+		desc: "connection not closed",
+		f: func(s *errtest.Simulation) error {
+			_, err := NewDialer(s, echo).Dial("pipe", "server")
+			return err
+		},
+		scenarios: 3,
+		failed:    1,
+	}, {
+		desc: "accept",
+		f: func(s *errtest.Simulation) error {
+			pl := make(pipeListener, 1)
+			client, server := net.Pipe()
+			go io.Copy(io.Discard, client)
+			defer client.Close()
+			pl <- server
+			l := NewListener(s, "listener", pl)
+			defer l.Close()
+			return serve(l)
+		},
+		// The deferred close of the listener is simulated for each outcome
+		// of the accept, and for each outcome of the deferred close of the
+		// connection if the accept succeeds.
+		scenarios: 3 * (2 + 3*(2+1)),
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := &errtest.Config{ExpectFailure: tc.failed > 0}
+			r := errtest.RunReport(t, cfg, tc.f)
+			if got := len(r.Scenarios); got != tc.scenarios {
+				t.Errorf("got %d scenarios; want %d", got, tc.scenarios)
+			}
+			if got := r.Failed(); got != tc.failed {
+				t.Errorf("got %d failures; want %d:\n%s", got, tc.failed, r.Summary())
+			}
+		})
+	}
+}
+
+func TestErrorAttribution(t *testing.T) {
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		err := ping(NewDialer(s, echo))
+		if err != nil {
+			// The error is that of the first failed step, not wrapped.
+			sc := s.Scenario()
+			faults := sc.Faults()
+			if len(faults) == 0 {
+				t.Fatalf("%v: unexpected error %v", sc.Steps, err)
+			}
+			if want := faults[0].Key + ": Error"; err.Error() != want {
+				t.Errorf("%v: got error %q; want %q", sc.Steps, err, want)
+			}
+		}
+		return err
+	})
+}
+
+func TestReconnect(t *testing.T) {
+	var first []string // the keys of the scenario without faults
+	r := errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		var keys []string
+		d := NewDialer(s, echo)
+		for i := 0; i < 2; i++ {
+			c, err := d.Dial("pipe", "server")
+			if err != nil {
+				return err
+			}
+			keys = append(keys, c.(*Conn).Key())
+			_, err = c.Write([]byte("ping"))
+			c.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if first == nil {
+			first = keys
+		}
+		return nil
+	})
+	if want := []string{"server", "server#1"}; fmt.Sprint(first) != fmt.Sprint(want) {
+		t.Errorf("got keys %q; want %q", first, want)
+	}
+	got := fmt.Sprint(r.Scenarios[0].Steps)
+	want := "[server=NoError server.write=NoError server.close=NoError server#1=NoError server#1.write=NoError server#1.close=NoError]"
+	if got != want {
+		t.Errorf("got steps %s; want %s", got, want)
+	}
+}
+
+// countConn is a connection that counts the connections that are open.
+type countConn struct {
+	net.Conn
+	open *atomic.Int32
+}
+
+func (c countConn) Close() error {
+	c.open.Add(-1)
+	return c.Conn.Close()
+}
+
+func TestClosePanic(t *testing.T) {
+	var open atomic.Int32
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		return ping(NewDialer(s, func(ctx context.Context, network, address string) (net.Conn, error) {
+			c, err := echo(ctx, network, address)
+			open.Add(1)
+			return countConn{c, &open}, err
+		}))
+	})
+	if n := open.Load(); n != 0 {
+		t.Errorf("%d connections not closed", n)
+	}
+}
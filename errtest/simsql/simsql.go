@@ -0,0 +1,185 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package simsql provides a database/sql driver whose operations are steps of
+// an errtest.Simulation.
+//
+// Code using a *sql.DB can be run against the simulation without
+// modification to verify that it rolls back transactions, closes rows, and
+// checks the errors of iterations:
+//
+//	errtest.Run(t, nil, func(s *errtest.Simulation) error {
+//		db := simsql.OpenDB(s, nil)
+//		defer db.Close()
+//		return transfer(db, "alice", "bob", 10)
+//	})
+//
+// The operations are keyed as follows:
+//
+//	tx          beginning a transaction; closed by Commit or Rollback
+//	exec        a call to Exec
+//	rows        a call to Query; closed by closing the rows
+//	rows.next   advancing the rows to a next row
+//
+// A second transaction is keyed tx#1, a second query rows#1 and the rows it
+// advances to rows#1.next, so that each transaction is committed or rolled
+// back, and each result set closed, by its own key. Repeated execs and rows
+// of a query are keyed exec#1, rows.next#1, and so on.
+//
+// Committing a transaction closes it with a nil error, so committing after an
+// earlier error fails the scenario. Errors of Rollback may be ignored, as
+// Rollback is typically deferred to clean up after a failed or committed
+// transaction. Closing rows may panic, but not fail, as package sql reports
+// such errors from Err after the iteration completes.
+//
+// As a data source name cannot refer to a Simulation, the driver is not
+// registered with sql.Register. Instead, OpenDB opens a *sql.DB with a
+// driver.Connector. The *sql.DB must be closed at the end of the scenario.
+package simsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Table holds the columns and rows returned by a query.
+type Table struct {
+	Columns []string
+	Rows    [][]driver.Value
+}
+
+// OpenDB returns a *sql.DB whose operations are steps of s. A query returns
+// the Table of tables keyed by the query text, or no rows if there is none.
+func OpenDB(s *errtest.Simulation, tables map[string]Table) *sql.DB {
+	return sql.OpenDB(&connector{s: s, tables: tables})
+}
+
+type connector struct {
+	s      *errtest.Simulation
+	tables map[string]Table
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{c}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return drv{} }
+
+// drv is the driver of a connector. It cannot open connections by name.
+type drv struct{}
+
+func (drv) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("simsql: connections must be opened with OpenDB")
+}
+
+type conn struct {
+	c *connector
+}
+
+var (
+	_ driver.ConnBeginTx    = (*conn)(nil)
+	_ driver.ExecerContext  = (*conn)(nil)
+	_ driver.QueryerContext = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c, query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	key := "tx"
+	if err := c.c.s.Open(key, errtest.Iterate(), errtest.StoreKey(&key)); err != nil {
+		return nil, err
+	}
+	return &tx{c.c.s, key}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.c.s.Open("exec", errtest.Iterate(), errtest.NoClose()); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	key := "rows"
+	if err := c.c.s.Open(key, errtest.Iterate(), errtest.StoreKey(&key), errtest.CloseOptions(errtest.NoError())); err != nil {
+		return nil, err
+	}
+	return &rows{s: c.c.s, key: key, table: c.c.tables[query]}, nil
+}
+
+type tx struct {
+	s   *errtest.Simulation
+	key string
+}
+
+func (t *tx) Commit() error {
+	return t.s.CloseWithError(t.key, nil)
+}
+
+func (t *tx) Rollback() error {
+	return t.s.Close(t.key, errtest.IgnoreError())
+}
+
+// stmt is a prepared statement. Preparing is not simulated.
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, named(args))
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return nv
+}
+
+type rows struct {
+	s     *errtest.Simulation
+	key   string
+	table Table
+	next  int
+}
+
+func (r *rows) Columns() []string { return r.table.Columns }
+
+func (r *rows) Close() error {
+	return r.s.Close(r.key)
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.table.Rows) {
+		return io.EOF
+	}
+	if err := r.s.Op(r.key, "next"); err != nil {
+		return err
+	}
+	copy(dest, r.table.Rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package simsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+var tables = map[string]Table{
+	"SELECT name FROM users": {
+		Columns: []string{"name"},
+		Rows:    [][]driver.Value{{"alice"}, {"bob"}},
+	},
+}
+
+func transfer(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE accounts SET balance = balance - 10 WHERE name = 'alice'"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE accounts SET balance = balance + 10 WHERE name = 'bob'"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func names(db *sql.DB, checkErr bool) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM users")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if checkErr {
+		return names, rows.Err()
+	}
+	return names, nil
+}
+
+func TestDB(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		f         func(db *sql.DB) error
+		scenarios int
+		failed    int
+	}{{
+		desc: "transaction",
+		f:    transfer,
+		// The begin may fail or panic. Otherwise, the deferred rollback is
+		// simulated for each failure of an exec, and the commit may
+		// succeed, fail, or panic.
+		scenarios: 2 + 2*3 + 2*3 + 3,
+	}, {
+		desc: "commit after error",
+		f: func(db *sql.DB) error {
+			tx, err := db.Begin()
+			if err != nil {
+				return err
+			}
+			tx.Exec("DELETE FROM users")
+			return tx.Commit()
+		},
+		scenarios: 2 + 2 + 3,
+		failed:    1,
+	}, {
+		desc: "missing rollback",
+		f: func(db *sql.DB) error {
+			tx, err := db.Begin()
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM users"); err != nil {
+				return err
+			}
+			return tx.Commit()
+		},
+		scenarios: 2 + 2 + 3,
+		failed:    1,
+	}, {
+		desc: "rows",
+		f: func(db *sql.DB) error {
+			_, err := names(db, true)
+			return err
+		},
+		// The query may fail or panic. Otherwise, the close may succeed or
+		// panic for each failure of a row and for success.
+		scenarios: 2 + 2*(2+2+1),
+	}, {
+		desc: "missing rows.Err",
+		f: func(db *sql.DB) error {
+			_, err := names(db, false)
+			return err
+		},
+		scenarios: 2 + 2*(2+2+1),
+		failed:    2,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := &errtest.Config{ExpectFailure: tc.failed > 0}
+			r := errtest.RunReport(t, cfg, func(s *errtest.Simulation) error {
+				db := OpenDB(s, tables)
+				defer db.Close()
+				return tc.f(db)
+			})
+			if got := len(r.Scenarios); got != tc.scenarios {
+				t.Errorf("got %d scenarios; want %d", got, tc.scenarios)
+			}
+			if got := r.Failed(); got != tc.failed {
+				t.Errorf("got %d failures; want %d:\n%s", got, tc.failed, r.Summary())
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		db := OpenDB(s, tables)
+		defer db.Close()
+		got, err := names(db, true)
+		if err == nil && fmt.Sprint(got) != "[alice bob]" {
+			t.Errorf("got names %q; want [alice bob]", got)
+		}
+		return err
+	})
+}
+
+func TestErrorAttribution(t *testing.T) {
+	errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		db := OpenDB(s, tables)
+		defer db.Close()
+		err := transfer(db)
+		if err != nil {
+			// The error is that of the first failed step, not wrapped.
+			sc := s.Scenario()
+			faults := sc.Faults()
+			if len(faults) == 0 {
+				t.Fatalf("%v: unexpected error %v", sc.Steps, err)
+			}
+			if want := faults[0].Key + ": Error"; err.Error() != want {
+				t.Errorf("%v: got error %q; want %q", sc.Steps, err, want)
+			}
+		}
+		return err
+	})
+}
+
+func TestGenerationKeys(t *testing.T) {
+	r := errtest.RunReport(t, nil, func(s *errtest.Simulation) error {
+		db := OpenDB(s, tables)
+		defer db.Close()
+		for i := 0; i < 2; i++ {
+			if err := transfer(db); err != nil {
+				return err
+			}
+			if _, err := names(db, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var got []string
+	for _, st := range r.Scenarios[0].Steps {
+		got = append(got, st.Key)
+	}
+	want := []string{
+		"tx", "exec", "exec#1", "tx.close", "rows", "rows.next", "rows.next#1", "rows.close",
+		"tx#1", "exec#2", "exec#3", "tx#1.close", "rows#1", "rows#1.next", "rows#1.next#1", "rows#1.close",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got steps %q; want %q", got, want)
+	}
+}
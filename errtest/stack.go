@@ -0,0 +1,79 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// pkgDir is the directory holding the source of this package. Frames from
+// this directory are omitted from captured stacks, except those of tests.
+var pkgDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callers returns the stack of the caller of the function calling callers,
+// if Config.CaptureStacks is set.
+func (s *Simulation) callers() []uintptr {
+	if s.config == nil || !s.config.CaptureStacks {
+		return nil
+	}
+	pcs := make([]uintptr, 32)
+	return pcs[:runtime.Callers(3, pcs)]
+}
+
+// where returns a description of the stack pcs, labeled with label, for
+// inclusion in a diagnostic. It returns the empty string if pcs is empty.
+func where(label string, pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "\n\t%s:", label)
+	for _, line := range stackLines(pcs) {
+		fmt.Fprintf(b, "\n\t\t%s", line)
+	}
+	return b.String()
+}
+
+// stackLines returns a line for each frame of the stack pcs, omitting the
+// frames of this package, except those of tests, and those of the runtime
+// and testing packages.
+func stackLines(pcs []uintptr) []string {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var lines []string
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if strings.HasPrefix(f.Function, "testing.") || strings.HasPrefix(f.Function, "runtime.") {
+			break
+		}
+		if filepath.Dir(f.File) != pkgDir || strings.HasSuffix(f.File, "_test.go") {
+			lines = append(lines, fmt.Sprintf("%s (%s:%d)", f.Function, filepath.Base(f.File), f.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return lines
+}
+
+// unclosedAt returns the locations at which the values that still need to be
+// closed were opened, if stacks are captured.
+func (s *Simulation) unclosedAt() string {
+	b := &strings.Builder{}
+	for _, f := range s.exec {
+		if !f.noClose {
+			b.WriteString(where(s.quote(f.key)+" opened at", f.openedAt))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaptureStacks(t *testing.T) {
+	f := func(s *Simulation) error {
+		s.Open("o1", NoError(), NoPanic(), CloseOptions(NoError(), NoPanic()))
+		s.Close("o1")
+		return s.Close("o1")
+	}
+	failures := RunStandalone(nil, f)
+	want := `"o1" was already closed or should not be closed`
+	if len(failures) != 1 || failures[0].Message != want {
+		t.Fatalf("got %v; want single failure %q", failures, want)
+	}
+
+	failures = RunStandalone(&Config{CaptureStacks: true}, f)
+	if len(failures) != 1 {
+		t.Fatalf("got %v; want single failure", failures)
+	}
+	msg := failures[0].Message
+	for _, want := range []string{
+		"\n\tclosed again at:\n\t\tgithub.com/mpvl/errdare/errtest.TestCaptureStacks.func1 (stack_test.go:16)",
+		"\n\tfirst closed at:\n\t\tgithub.com/mpvl/errdare/errtest.TestCaptureStacks.func1 (stack_test.go:15)",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+	if strings.Contains(msg, "errtest.go") {
+		t.Errorf("message %q contains frames of package errtest", msg)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !playground
+
+package errtest
+
+import "testing"
+
+// Run runs simulations by repeatedly calling s until all possible scenarios of
+// a simulation are covered.
+//
+// If t is a *testing.T, each scenario is run as a subtest. Otherwise, for
+// instance for a *testing.B, scenarios are run in sequence and failures are
+// reported to t with Errorf.
+func Run(t testing.TB, config *Config, f func(s *Simulation) error) {
+	RunReport(t, config, f)
+}
+
+// RunReport is like Run, but also returns the outcome of every scenario.
+func RunReport(t testing.TB, config *Config, f func(s *Simulation) error) *Results {
+	config = logProgress(t, config)
+	r := runReport(t, config, f)
+	if r.Truncated != nil {
+		t.Logf("errtest: ran %d scenarios; %v", len(r.Scenarios), r.Truncated)
+	}
+	if err := checkGolden(t, config, r); err != nil {
+		t.Errorf("errtest: %v", err)
+	}
+	return r
+}
+
+func runReport(t testing.TB, config *Config, f func(s *Simulation) error) *Results {
+	if config != nil && config.ExpectFailure {
+		return expectFailure(t, config, f)
+	}
+	if config != nil && config.ContinueOnFailure {
+		r := run(nil, config, f)
+		if r.Failed() > 0 {
+			t.Errorf("%s%s", groups(r), r.Summary())
+			showHint(t, config, r)
+		}
+		return r
+	}
+	r := run(t, config, f)
+	if r.Failed() > 0 {
+		if config != nil && config.SkipErrors {
+			t.Logf("%s%s", groups(r), r.Summary())
+		} else {
+			t.Log(r.Summary())
+		}
+		showHint(t, config, r)
+	}
+	return r
+}
+
+// ExpectFailure runs all scenarios of f and reports an error to t if none of
+// them fail. It can be used to assert that a known-incorrect solution is
+// indeed detected as such.
+func ExpectFailure(t testing.TB, config *Config, f func(s *Simulation) error) {
+	expectFailure(t, config, f)
+}
+
+func expectFailure(t testing.TB, config *Config, f func(s *Simulation) error) *Results {
+	r := run(nil, config, f)
+	if r.Failed() == 0 {
+		t.Errorf("all %d scenarios passed; expected at least one failure", len(r.Scenarios))
+	} else {
+		t.Log(r.Summary())
+	}
+	return r
+}
+
+// runSubtest runs the current scenario of s as a subtest of t if t is a
+// *testing.T. It reports false for isT otherwise.
+func runSubtest(t reporter, s *Simulation, f func(s *Simulation) error) (ok, skipped, isT bool) {
+	tt, isT := t.(*testing.T)
+	if !isT {
+		return false, false, false
+	}
+	ok = tt.Run("", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		s.runScenario(t, f)
+	})
+	return ok, skipped, true
+}
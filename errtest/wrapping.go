@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import "strings"
+
+// checkWrapping checks the layers with which err wraps the error that the
+// simulation must return against Config.MaxWrapDepth and
+// Config.CheckMessages.
+func (s *Simulation) checkWrapping(err error) {
+	c := s.config
+	if c == nil || !c.AllowWrapping || c.MaxWrapDepth <= 0 && !c.CheckMessages {
+		return
+	}
+	if err == nil || s.mustErr == nil || err == s.mustErr {
+		return
+	}
+	layers := wrapPath(err, s.mustErr)
+	if c.MaxWrapDepth > 0 && len(layers) > c.MaxWrapDepth {
+		s.fail(BadWrapping, "error wrapped %d times, more than %d: %q", len(layers), c.MaxWrapDepth, err)
+		return
+	}
+	if !c.CheckMessages {
+		return
+	}
+	inner := append(layers[1:], s.mustErr)
+	for i, l := range layers {
+		ctx, ok := addedContext(l, inner[i])
+		if !ok {
+			continue // the message does not end with that of the wrapped error
+		}
+		if ctx == "" {
+			s.fail(BadWrapping, "wrapping of %q adds no context: %q", inner[i], l)
+			return
+		}
+		if i+1 < len(layers) {
+			if innerCtx, ok := addedContext(inner[i], inner[i+1]); ok && ctx == innerCtx {
+				s.fail(BadWrapping, "wrapping of %q repeats its context: %q", inner[i], l)
+				return
+			}
+		}
+	}
+}
+
+// wrapPath returns the errors in the chain of err that wrap target, from the
+// outermost to the one wrapping target directly. It returns nil if target is
+// not in the chain of err.
+func wrapPath(err, target error) []error {
+	if err == nil || err == target {
+		return nil
+	}
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		wrapped = []error{e.Unwrap()}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+	for _, w := range wrapped {
+		if w == target {
+			return []error{err}
+		}
+		if path := wrapPath(w, target); path != nil {
+			return append([]error{err}, path...)
+		}
+	}
+	return nil
+}
+
+// addedContext returns the part of the message of err that precedes the
+// message of the error it wraps, without the separating colon. It reports
+// false if the message of err does not end with that of wrapped.
+func addedContext(err, wrapped error) (string, bool) {
+	msg, inner := err.Error(), wrapped.Error()
+	if !strings.HasSuffix(msg, inner) {
+		return "", false
+	}
+	ctx := strings.TrimSpace(strings.TrimSuffix(msg, inner))
+	return strings.TrimSuffix(ctx, ":"), true
+}
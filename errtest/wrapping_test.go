@@ -0,0 +1,85 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCheckWrapping(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config *Config
+		wrap   func(err error) error
+		want   []FailureKind
+	}{{
+		desc:   "within depth",
+		config: &Config{AllowWrapping: true, MaxWrapDepth: 2},
+		wrap: func(err error) error {
+			return fmt.Errorf("open config: %w", fmt.Errorf("read: %w", err))
+		},
+	}, {
+		desc:   "too deep",
+		config: &Config{AllowWrapping: true, MaxWrapDepth: 1},
+		wrap: func(err error) error {
+			return fmt.Errorf("open config: %w", fmt.Errorf("read: %w", err))
+		},
+		want: []FailureKind{BadWrapping},
+	}, {
+		desc:   "joined",
+		config: &Config{AllowWrapping: true, MaxWrapDepth: 1},
+		wrap: func(err error) error {
+			return errors.Join(errors.New("other"), fmt.Errorf("read: %w", err))
+		},
+		want: []FailureKind{BadWrapping},
+	}, {
+		desc:   "good messages",
+		config: &Config{AllowWrapping: true, CheckMessages: true},
+		wrap: func(err error) error {
+			return fmt.Errorf("open config: %w", fmt.Errorf("read: %w", err))
+		},
+	}, {
+		desc:   "no context",
+		config: &Config{AllowWrapping: true, CheckMessages: true},
+		wrap: func(err error) error {
+			return fmt.Errorf("%w", err)
+		},
+		want: []FailureKind{BadWrapping},
+	}, {
+		desc:   "repeated context",
+		config: &Config{AllowWrapping: true, CheckMessages: true},
+		wrap: func(err error) error {
+			return fmt.Errorf("read: %w", fmt.Errorf("read: %w", err))
+		},
+		want: []FailureKind{BadWrapping},
+	}, {
+		desc:   "not checked without AllowWrapping",
+		config: &Config{MaxWrapDepth: 1, CheckMessages: true},
+		wrap: func(err error) error {
+			return fmt.Errorf("read: %w", fmt.Errorf("read: %w", err))
+		},
+		want: []FailureKind{WrongError},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var got []FailureKind
+			failures := RunStandalone(tc.config, func(s *Simulation) error {
+				if err := s.Open("reader", NoPanic(), NoClose()); err != nil {
+					return tc.wrap(err)
+				}
+				return nil
+			})
+			for _, f := range failures {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package errtrace records the resources a program acquires and releases, so
+// that the shape of a real execution, such as one that led to a production
+// incident, can be turned into a dare.
+//
+// A program is instrumented by calling Open, Close, and Call at the points
+// where it acquires a resource, releases it, or makes a call that may fail:
+//
+//	c, err := storage.NewClient(ctx)
+//	errtrace.Open("client")
+//	...
+//	errtrace.Close("client")
+//	c.Close()
+//
+// These calls do nothing unless recording was started with Start. The
+// recorded execution can be written as a dare with Recorder.WriteDare.
+package errtrace
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// A Kind is the kind of a recorded event.
+type Kind int
+
+const (
+	OpenEvent  Kind = iota // a resource was acquired
+	CloseEvent             // a resource was released
+	CallEvent              // a call that may fail was made
+)
+
+func (k Kind) String() string {
+	switch k {
+	case OpenEvent:
+		return "open"
+	case CloseEvent:
+		return "close"
+	case CallEvent:
+		return "call"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// An Event is a recorded event.
+type Event struct {
+	Kind Kind
+	Key  string
+}
+
+// A Recorder records events. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *Recorder) record(k Kind, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{k, key})
+}
+
+// Open records the acquisition of the resource with the given key.
+func (r *Recorder) Open(key string) { r.record(OpenEvent, key) }
+
+// Close records the release of the resource with the given key.
+func (r *Recorder) Close(key string) { r.record(CloseEvent, key) }
+
+// Call records a call that may fail.
+func (r *Recorder) Call(key string) { r.record(CallEvent, key) }
+
+// Events returns the recorded events in order.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+var active atomic.Pointer[Recorder]
+
+// Start starts recording the events reported with the package-level
+// functions Open, Close, and Call to a new Recorder, which it returns.
+func Start() *Recorder {
+	r := &Recorder{}
+	active.Store(r)
+	return r
+}
+
+// Stop stops recording.
+func Stop() { active.Store(nil) }
+
+// Open records the acquisition of the resource with the given key, if
+// recording was started.
+func Open(key string) {
+	if r := active.Load(); r != nil {
+		r.Open(key)
+	}
+}
+
+// Close records the release of the resource with the given key, if recording
+// was started.
+func Close(key string) {
+	if r := active.Load(); r != nil {
+		r.Close(key)
+	}
+}
+
+// Call records a call that may fail, if recording was started.
+func Call(key string) {
+	if r := active.Load(); r != nil {
+		r.Call(key)
+	}
+}
+
+// WriteDare writes the source of a Go file in package pkg that declares the
+// recorded execution as a dare with the given name. The file declares
+//
+//	var <name>Dare *errdare.Builder
+//	func <name>Recorded(d *errdare.Instance) error
+//
+// The Builder declares each opened key as a resource that must be closed and
+// each called key as a step. The last call must be made if no fault occurs.
+// The Recorded function performs the recorded events in order, returning any
+// error, and thus reproduces the shape of the execution, including resources
+// that were not closed. It is a starting point for a correct solution.
+func (r *Recorder) WriteDare(w io.Writer, pkg, name string) error {
+	events := r.Events()
+
+	// Declarations, in order of first use.
+	var (
+		order  []string
+		kinds  = map[string]Kind{}
+		counts = map[string]int{}
+		last   string
+	)
+	for _, e := range events {
+		if e.Kind == CloseEvent {
+			continue
+		}
+		if k, ok := kinds[e.Key]; ok && k != e.Kind {
+			return fmt.Errorf("errtrace: key %q used both as resource and call", e.Key)
+		}
+		if _, ok := kinds[e.Key]; !ok {
+			order = append(order, e.Key)
+			kinds[e.Key] = e.Kind
+		}
+		counts[e.Key]++
+		if e.Kind == CallEvent {
+			last = e.Key
+		}
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "// Code generated by errtrace. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+	iterate := false
+	for _, n := range counts {
+		iterate = iterate || n > 1
+	}
+	if iterate {
+		fmt.Fprintf(b, "import (\n\t\"github.com/mpvl/errdare\"\n\t\"github.com/mpvl/errdare/errtest\"\n)\n\n")
+	} else {
+		fmt.Fprintf(b, "import \"github.com/mpvl/errdare\"\n\n")
+	}
+
+	fmt.Fprintf(b, "// %sDare is the dare %s derived from a recorded execution.\n", name, name)
+	fmt.Fprintf(b, "var %sDare = errdare.NewDare(%q)", name, name)
+	for _, key := range order {
+		var opts []string
+		if kinds[key] == OpenEvent {
+			opts = append(opts, "errdare.Closeable")
+		}
+		if counts[key] > 1 {
+			opts = append(opts, "errdare.Options(errtest.Iterate())")
+		}
+		method := "Step"
+		if kinds[key] == OpenEvent {
+			method = "Resource"
+		}
+		fmt.Fprintf(b, ".\n\t%s(%q", method, key)
+		for _, o := range opts {
+			fmt.Fprintf(b, ", %s", o)
+		}
+		fmt.Fprintf(b, ")")
+	}
+	if last != "" {
+		fmt.Fprintf(b, ".\n\tMustCall(%q)", last)
+	}
+	fmt.Fprintf(b, "\n\n")
+
+	fmt.Fprintf(b, "// %sRecorded performs the events of the recorded execution in order.\n", name)
+	fmt.Fprintf(b, "func %sRecorded(d *errdare.Instance) error {\n", name)
+	vars := map[string][]string{} // open variables by key
+	n := 0
+	for _, e := range events {
+		switch e.Kind {
+		case OpenEvent:
+			v := "r" + strconv.Itoa(n)
+			n++
+			vars[e.Key] = append(vars[e.Key], v)
+			fmt.Fprintf(b, "\t%s, err := d.Open(%q)\n\tif err != nil {\n\t\treturn err\n\t}\n", v, e.Key)
+		case CloseEvent:
+			open := vars[e.Key]
+			if len(open) == 0 {
+				return fmt.Errorf("errtrace: close of %q without matching open", e.Key)
+			}
+			v := open[len(open)-1]
+			vars[e.Key] = open[:len(open)-1]
+			fmt.Fprintf(b, "\tif err := %s.Close(); err != nil {\n\t\treturn err\n\t}\n", v)
+		case CallEvent:
+			fmt.Fprintf(b, "\tif err := d.Do(%q); err != nil {\n\t\treturn err\n\t}\n", e.Key)
+		}
+	}
+	for _, key := range order {
+		for _, v := range vars[key] {
+			fmt.Fprintf(b, "\t_ = %s // not closed\n", v)
+		}
+	}
+	fmt.Fprintf(b, "\treturn nil\n}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("errtrace: invalid generated code: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
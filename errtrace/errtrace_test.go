@@ -0,0 +1,128 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errtrace
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	Open("ignored")
+	r := Start()
+	Open("client")
+	Open("reader")
+	Call("copy")
+	Close("reader")
+	Stop()
+	Close("client")
+
+	want := []Event{
+		{OpenEvent, "client"},
+		{OpenEvent, "reader"},
+		{CallEvent, "copy"},
+		{CloseEvent, "reader"},
+	}
+	if got := r.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+const wantDare = `// Code generated by errtrace. DO NOT EDIT.
+
+package incidents
+
+import (
+	"github.com/mpvl/errdare"
+	"github.com/mpvl/errdare/errtest"
+)
+
+// OutageDare is the dare Outage derived from a recorded execution.
+var OutageDare = errdare.NewDare("Outage").
+	Resource("client", errdare.Closeable).
+	Resource("conn", errdare.Closeable, errdare.Options(errtest.Iterate())).
+	Step("send").
+	MustCall("send")
+
+// OutageRecorded performs the events of the recorded execution in order.
+func OutageRecorded(d *errdare.Instance) error {
+	r0, err := d.Open("client")
+	if err != nil {
+		return err
+	}
+	r1, err := d.Open("conn")
+	if err != nil {
+		return err
+	}
+	if err := d.Do("send"); err != nil {
+		return err
+	}
+	if err := r1.Close(); err != nil {
+		return err
+	}
+	r2, err := d.Open("conn")
+	if err != nil {
+		return err
+	}
+	_ = r0 // not closed
+	_ = r2 // not closed
+	return nil
+}
+`
+
+func TestWriteDare(t *testing.T) {
+	r := &Recorder{}
+	r.Open("client")
+	r.Open("conn")
+	r.Call("send")
+	r.Close("conn")
+	r.Open("conn")
+
+	b := &bytes.Buffer{}
+	if err := r.WriteDare(b, "incidents", "Outage"); err != nil {
+		t.Fatal(err)
+	}
+	src := b.String()
+	if src != wantDare {
+		t.Errorf("got:\n%s\nwant:\n%s", src, wantDare)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "outage.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("incidents", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("generated code does not type check: %v", err)
+	}
+}
+
+func TestWriteDareErrors(t *testing.T) {
+	testCases := []struct {
+		events []Event
+		err    string
+	}{{
+		events: []Event{{CloseEvent, "a"}},
+		err:    `close of "a" without matching open`,
+	}, {
+		events: []Event{{OpenEvent, "a"}, {CallEvent, "a"}},
+		err:    `key "a" used both as resource and call`,
+	}}
+	for _, tc := range testCases {
+		r := &Recorder{events: tc.events}
+		err := r.WriteDare(&bytes.Buffer{}, "p", "P")
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: got error %v; want %q", tc.events, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"context"
+
+	"github.com/mpvl/errdare/errtest"
+	"github.com/mpvl/errdare/errtest/group"
+)
+
+// A Simulator is a dare run by the simulation engine, such as *PipeConvert or
+// *Instance.
+type Simulator interface {
+	simulation() *errtest.Simulation
+}
+
+func (c *CloudStorage) simulation() *errtest.Simulation     { return c.s }
+func (p *PipeConvert) simulation() *errtest.Simulation      { return p.s }
+func (t *TrickyCatch) simulation() *errtest.Simulation      { return t.s }
+func (p *Pipeline) simulation() *errtest.Simulation         { return p.s }
+func (m *MultiReader) simulation() *errtest.Simulation      { return m.s }
+func (c *ErrorCodes) simulation() *errtest.Simulation       { return c.s }
+func (g *GracefulShutdown) simulation() *errtest.Simulation { return g.s }
+func (p *PartialResponse) simulation() *errtest.Simulation  { return p.s }
+func (b *BatchUpdate) simulation() *errtest.Simulation      { return b.s }
+func (c *Connect) simulation() *errtest.Simulation          { return c.s }
+func (u *Upload) simulation() *errtest.Simulation           { return u.s }
+func (d *Instance) simulation() *errtest.Simulation         { return d.s }
+
+// Go runs f in a new goroutine on behalf of the dare s. A panic in f does not
+// crash the test, but is checked by the simulation: the scenario fails if f
+// panics with anything other than an expected simulated panic. As always, a
+// simulated panic must still be passed on by the solution, for instance as
+// the error passed to CloseWithError. See errtest.Simulation.Go.
+func Go(s Simulator, f func()) {
+	s.simulation().Go(f)
+}
+
+// NewGroup returns a group of goroutines working on behalf of the dare s, and
+// its context. The group is opened with the given key, which identifies it in
+// the steps of a scenario, and must be created before, and waited for after,
+// the values used by its goroutines. See package group.
+//
+// No dare starts goroutines of its own: the goroutines of concurrent dares,
+// like PipeConvert, are part of the solution, which may run them in a group.
+func NewGroup(s Simulator, key string) (*group.Group, context.Context) {
+	return group.New(s.simulation(), key)
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import "testing"
+
+func TestGo(t *testing.T) {
+	solution := func(t *PipeConvert, r Reader) error {
+		pipeReader, pipeWriter := t.Pipe()
+		Go(t, func() {
+			var err error
+			defer func() {
+				if r := recover(); r != nil {
+					pipeWriter.CloseWithError(r.(error))
+					panic(r) // checked by Go
+				}
+				pipeWriter.CloseWithError(err)
+			}()
+			scanner := t.NewScanner(r)
+			for t.Scan(scanner) {
+				if err = t.WriteScanned(pipeWriter, scanner); err != nil {
+					return
+				}
+			}
+			err = t.ScanErr(scanner)
+		})
+		return t.Wait(pipeReader)
+	}
+	RunPipeConvert(t, config(), solution)
+
+	cfg := config()
+	cfg.ExpectFailure = true
+	RunPipeConvert(t, cfg, func(t *PipeConvert, r Reader) error {
+		pipeReader, pipeWriter := t.Pipe()
+		Go(t, func() {
+			defer pipeWriter.Close()
+			panic("bug") // not a simulated panic
+		})
+		return t.Wait(pipeReader)
+	})
+}
+
+func TestNewGroup(t *testing.T) {
+	// Groups with distinct keys may be open at the same time.
+	RunPipeConvert(t, config(), func(t *PipeConvert, r Reader) error {
+		outer, _ := NewGroup(t, "outer")
+		inner, _ := NewGroup(t, "inner")
+		pipeReader, pipeWriter := t.Pipe()
+		inner.Go(func() (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					pipeWriter.CloseWithError(r.(error))
+					panic(r) // recorded by the group
+				}
+				pipeWriter.CloseWithError(err)
+			}()
+			scanner := t.NewScanner(r)
+			for t.Scan(scanner) {
+				if err := t.WriteScanned(pipeWriter, scanner); err != nil {
+					return err
+				}
+			}
+			return t.ScanErr(scanner)
+		})
+		err := t.Wait(pipeReader)
+		if errG := inner.Wait(); err == nil {
+			err = errG
+		}
+		outer.Wait()
+		return err
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// A Grade is the outcome of running a dare, in a form suitable for encoding
+// as JSON.
+type Grade struct {
+	Dare string `json:"dare"`
+	ID   string `json:"id"`
+
+	// Passed reports whether none of the scenarios failed. It is not
+	// affected by Config.ExpectFailure.
+	Passed bool `json:"passed"`
+
+	Scenarios []ScenarioGrade `json:"scenarios"`
+}
+
+// A ScenarioGrade is the outcome of a single scenario of a dare.
+type ScenarioGrade struct {
+	ID      int      `json:"id"`
+	Passed  bool     `json:"passed"`
+	Skipped bool     `json:"skipped,omitempty"`
+	Kind    string   `json:"kind,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Faults  []string `json:"faults,omitempty"`
+}
+
+// GradeAll is like RunAll, but also returns the outcome of each dare that
+// was run. Grades record the ID of each dare, so that outcomes for different
+// versions of a dare can be told apart.
+func GradeAll(t *testing.T, cfg *errtest.Config, match ...string) []Grade {
+	var grades []Grade
+	for _, d := range Dares(match...) {
+		g := Grade{Dare: d.Name, ID: d.ID(), Passed: true}
+		c := cfg.With(func(c *errtest.Config) {
+			next := c.OnScenarioEnd
+			c.OnScenarioEnd = func(sc errtest.Scenario) {
+				g.add(sc)
+				if next != nil {
+					next(sc)
+				}
+			}
+		})
+		t.Run(d.Name, func(t *testing.T) { d.Run(t, c) })
+		grades = append(grades, g)
+	}
+	return grades
+}
+
+func (g *Grade) add(sc errtest.Scenario) {
+	s := ScenarioGrade{
+		ID:      sc.Index,
+		Passed:  !sc.Failed,
+		Skipped: sc.Skipped,
+		Message: sc.Message,
+	}
+	if sc.Failed {
+		s.Kind = sc.Kind.String()
+		g.Passed = false
+	}
+	for _, st := range sc.Faults() {
+		s.Faults = append(s.Faults, st.String())
+	}
+	g.Scenarios = append(g.Scenarios, s)
+}
+
+// WriteGrades writes grades to w as an indented JSON array.
+func WriteGrades(w io.Writer, grades []Grade) error {
+	if grades == nil {
+		grades = []Grade{}
+	}
+	b, err := json.MarshalIndent(grades, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestGradeAll(t *testing.T) {
+	cfg := config()
+	cfg.ExpectFailure = true
+	var ended int
+	cfg.OnScenarioEnd = func(sc errtest.Scenario) { ended++ }
+
+	grades := GradeAll(t, cfg, "CloudStorage")
+	if len(grades) != 1 {
+		t.Fatalf("got %d grades; want 1", len(grades))
+	}
+	g := grades[0]
+	if g.Dare != "CloudStorage" || g.Passed {
+		t.Errorf("got dare %q, passed %v; want CloudStorage, false", g.Dare, g.Passed)
+	}
+	if len(g.Scenarios) == 0 || len(g.Scenarios) != ended {
+		t.Errorf("got %d scenarios, %d ended; want equal and non-zero", len(g.Scenarios), ended)
+	}
+	failed := 0
+	for _, sc := range g.Scenarios {
+		if !sc.Passed {
+			failed++
+			if sc.Kind == "" || sc.Message == "" {
+				t.Errorf("scenario %d: missing kind or message", sc.ID)
+			}
+		}
+	}
+	if failed == 0 {
+		t.Error("no failed scenarios")
+	}
+
+	b := &strings.Builder{}
+	if err := WriteGrades(b, grades); err != nil {
+		t.Fatal(err)
+	}
+	var got []Grade
+	if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].Scenarios) != len(g.Scenarios) {
+		t.Errorf("round trip: got %+v", got)
+	}
+}
+
+func TestWriteGradesEmpty(t *testing.T) {
+	b := &strings.Builder{}
+	if err := WriteGrades(b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "[]\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
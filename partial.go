@@ -0,0 +1,64 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// The PartialResponse challenge: send a request and read the body of its
+// response. Like some APIs, Send may return a non-nil partial Response along
+// with a non-nil error. A partial Response must not be read, but it must
+// still be closed. The error of closing the Response may be ignored.
+//
+// A simple, but incorrect, implementation is:
+//
+//	func TestPartialResponse(t *testing.T) {
+//		errdare.RunPartialResponse(t, nil, func(p *errdare.PartialResponse) error {
+//			resp, err := p.Send()
+//			if err != nil {
+//				return err // resp must still be closed
+//			}
+//			defer resp.Close()
+//			return p.ReadAll(resp)
+//		})
+//	}
+type PartialResponse struct {
+	s *errtest.Simulation
+}
+
+func init() {
+	Register("PartialResponse", Info{
+		Description: "read a response that may be returned along with an error",
+		Tags:        []string{"close", "partial"},
+		Difficulty:  Beginner,
+		Concepts:    []string{"partial results", "deferred close"},
+	}, nil)
+}
+
+// RunPartialResponse runs the PartialResponse dare as a test. The options, if
+// any, override cfg for this dare only.
+func RunPartialResponse(t *testing.T, cfg *errtest.Config, f func(p *PartialResponse) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&PartialResponse{s}), "readAll")
+	})
+}
+
+// Send sends a request and returns its Response, which must be closed. If it
+// returns an error, the Response is partial: it must be closed, but not read.
+func (p *PartialResponse) Send() (Reader, error) {
+	return ve(p.s, "response",
+		errtest.Partial(),
+		errtest.Describe("the Response returned by Send"),
+		errtest.CloseOptions(errtest.NoPanic(), errtest.IgnoreError()))
+}
+
+// ReadAll reads the body of the Response returned by Send.
+func (p *PartialResponse) ReadAll(resp Reader) error {
+	require(p.s, resp, "response")
+	return e(p.s, "readAll")
+}
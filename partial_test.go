@@ -0,0 +1,45 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestPartialResponse(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(p *PartialResponse) error
+	}{{
+		desc: "partial response not closed",
+		f: func(p *PartialResponse) error {
+			resp, err := p.Send()
+			if err != nil {
+				return err
+			}
+			defer resp.Close()
+			return p.ReadAll(resp)
+		},
+	}, {
+		desc: "partial response read",
+		f: func(p *PartialResponse) error {
+			resp, err := p.Send()
+			if resp != nil {
+				defer resp.Close()
+			}
+			if errR := p.ReadAll(resp); err == nil {
+				err = errR
+			}
+			return err
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			RunPartialResponse(t, nil, tc.f, errtest.WithExpectFailure())
+		})
+	}
+}
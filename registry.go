@@ -0,0 +1,265 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// Info describes a dare.
+type Info struct {
+	// Description is a one-line description of the dare.
+	Description string
+
+	// Tags classify the dare, for instance by the kind of resources
+	// involved. Dares may be selected by tag.
+	Tags []string
+
+	// Difficulty indicates how hard the dare is to get right. Dares may be
+	// selected by difficulty, as in "beginner".
+	Difficulty Difficulty
+
+	// Concepts lists the error handling concepts that the dare teaches, such
+	// as "deferred close".
+	Concepts []string
+
+	// Source, if not empty, is a URL of the code on which the dare is based.
+	Source string
+
+	// Version identifies the semantics of the dare, including what the
+	// engine accepts as a correct solution. It must be incremented whenever
+	// a change may alter the outcome for existing solutions, so that the ID
+	// recorded by a course or grader no longer matches once the dare
+	// changes. A version is only runnable as long as it is registered with a
+	// run function of its own: the dares of this package register only
+	// their current version, so looking up the ID of an older one fails
+	// instead of running different semantics. A Version of 0 is treated as
+	// 1.
+	Version int
+}
+
+// Difficulty is the difficulty level of a dare.
+type Difficulty int
+
+// Difficulty levels, from easiest to hardest.
+const (
+	Unrated Difficulty = iota
+	Beginner
+	Intermediate
+	Advanced
+)
+
+var difficultyNames = []string{"unrated", "beginner", "intermediate", "advanced"}
+
+func (d Difficulty) String() string {
+	if d < 0 || int(d) >= len(difficultyNames) {
+		return fmt.Sprintf("Difficulty(%d)", int(d))
+	}
+	return difficultyNames[d]
+}
+
+// A Dare is a registered dare.
+type Dare struct {
+	Name string
+	Info
+
+	// Run runs the dare as a test with its solution. It skips the test if
+	// the dare has no solution. See Solve.
+	Run func(t *testing.T, cfg *errtest.Config)
+
+	// solution runs the dare with its solution, if any. It is guarded by mu.
+	solution func(t *testing.T, cfg *errtest.Config)
+}
+
+func (d *Dare) version() int {
+	if d.Version <= 0 {
+		return 1
+	}
+	return d.Version
+}
+
+// ID returns the stable identifier of the dare, which consists of its name in
+// lower case and its version, as in "cloudstorage@v2".
+func (d *Dare) ID() string {
+	return fmt.Sprintf("%s@v%d", strings.ToLower(d.Name), d.version())
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]*Dare{}
+)
+
+// Register registers a dare with the given name and information. Typically,
+// run calls the Run function of the dare, like RunCloudStorage, with a
+// solution. If run is nil, the solution may be set later with Solve, as is
+// done for the dares of this package, which are registered with their
+// information next to their Run functions. Register panics if a dare with the
+// same name and version was already registered. If the scenarios of the dare
+// are cached, they are keyed by the ID of the dare, so that changing its
+// version invalidates them.
+func Register(name string, info Info, run func(t *testing.T, cfg *errtest.Config)) {
+	mu.Lock()
+	defer mu.Unlock()
+	d := &Dare{Name: name, Info: info, solution: run}
+	d.Run = func(t *testing.T, cfg *errtest.Config) {
+		mu.Lock()
+		run := d.solution
+		mu.Unlock()
+		if run == nil {
+			t.Skipf("dare %s has no solution; see Solve", d.ID())
+		}
+		if cfg != nil && cfg.CacheDir != "" && cfg.CacheKey == "" {
+			cfg = cfg.With(errtest.WithCacheKey(d.ID()))
+		}
+		run(t, cfg)
+	}
+	if _, ok := registry[d.ID()]; ok {
+		panic(fmt.Sprintf("errdare: dare %s registered twice", d.ID()))
+	}
+	registry[d.ID()] = d
+}
+
+// Solve sets run as the solution of the registered dare with the given ID or,
+// if id has no version, of the latest version of the dare with that name.
+// Typically, run calls the Run function of the dare with a solution, as in
+//
+//	errdare.Solve("CloudStorage", func(t *testing.T, cfg *errtest.Config) {
+//		errdare.RunCloudStorage(t, cfg, answers.CloudStorage)
+//	})
+//
+// Solve panics if there is no such dare or if it already has a solution.
+func Solve(id string, run func(t *testing.T, cfg *errtest.Config)) {
+	d := Lookup(id)
+	if d == nil {
+		panic(fmt.Sprintf("errdare: no dare %s to solve", id))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if d.solution != nil {
+		panic(fmt.Sprintf("errdare: dare %s solved twice", d.ID()))
+	}
+	d.solution = run
+}
+
+// Matches reports whether the name, ID, difficulty, or any of the tags of d
+// is one of the given names, IDs, difficulties, or tags, or whether none were
+// given.
+func (d *Dare) Matches(match ...string) bool {
+	if len(match) == 0 {
+		return true
+	}
+	for _, m := range match {
+		if m == d.Name || m == d.Difficulty.String() || d.hasID(m) {
+			return true
+		}
+		for _, tag := range d.Tags {
+			if m == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *Dare) hasID(match ...string) bool {
+	for _, m := range match {
+		if strings.EqualFold(m, d.ID()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the dare with the given ID, or the latest version of the
+// dare with the given name if id has no version, as in "cloudstorage". Names
+// are not case sensitive. It returns nil if there is no such dare.
+func Lookup(id string) *Dare {
+	mu.Lock()
+	defer mu.Unlock()
+	if d, ok := registry[strings.ToLower(id)]; ok {
+		return d
+	}
+	if strings.Contains(id, "@") {
+		return nil
+	}
+	var latest *Dare
+	for _, d := range registry {
+		if strings.EqualFold(d.Name, id) && (latest == nil || d.version() > latest.version()) {
+			latest = d
+		}
+	}
+	return latest
+}
+
+// Dares returns the registered dares that match any of the given names, IDs,
+// difficulties, or tags, sorted by name and version. Only the latest version
+// of each dare is returned, unless other versions are selected by ID. It
+// returns the latest version of all dares if none are given.
+func Dares(match ...string) []*Dare {
+	mu.Lock()
+	defer mu.Unlock()
+	latest := map[string]*Dare{}
+	for _, d := range registry {
+		if l := latest[d.Name]; l == nil || d.version() > l.version() {
+			latest[d.Name] = d
+		}
+	}
+	var dares []*Dare
+	for _, d := range registry {
+		if d.hasID(match...) || latest[d.Name] == d && d.Matches(match...) {
+			dares = append(dares, d)
+		}
+	}
+	sort.Slice(dares, func(i, j int) bool {
+		if dares[i].Name != dares[j].Name {
+			return dares[i].Name < dares[j].Name
+		}
+		return dares[i].version() < dares[j].version()
+	})
+	return dares
+}
+
+// RunAll runs the dares returned by Dares for the given names, IDs,
+// difficulties, or tags as subtests of t. The subtests of dares without a
+// solution are skipped, so that all dares of this package are listed even if
+// only some were solved.
+func RunAll(t *testing.T, cfg *errtest.Config, match ...string) {
+	for _, d := range Dares(match...) {
+		t.Run(d.Name, func(t *testing.T) { d.Run(t, cfg) })
+	}
+}
+
+// WriteCatalog writes a Markdown catalog of the given dares to w.
+func WriteCatalog(w io.Writer, dares []*Dare) error {
+	b := &bytes.Buffer{}
+	for _, d := range dares {
+		fmt.Fprintf(b, "## %s\n\n", d.Name)
+		if d.Description != "" {
+			fmt.Fprintf(b, "%s\n\n", d.Description)
+		}
+		fmt.Fprintf(b, "- ID: %s\n", d.ID())
+		fmt.Fprintf(b, "- Difficulty: %s\n", d.Difficulty)
+		if len(d.Concepts) > 0 {
+			fmt.Fprintf(b, "- Concepts: %s\n", strings.Join(d.Concepts, ", "))
+		}
+		if len(d.Tags) > 0 {
+			fmt.Fprintf(b, "- Tags: %s\n", strings.Join(d.Tags, ", "))
+		}
+		if d.Source != "" {
+			fmt.Fprintf(b, "- Source: <%s>\n", d.Source)
+		}
+		fmt.Fprintln(b)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,253 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// runFuncs returns the names of the dares of this package, as derived from
+// their Run functions, which take a solution as their last non-variadic
+// parameter.
+func runFuncs(t *testing.T) []string {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range pkgs["errdare"].Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Run") {
+				continue
+			}
+			for _, p := range fn.Type.Params.List {
+				if _, ok := p.Type.(*ast.FuncType); ok {
+					names = append(names, strings.TrimPrefix(fn.Name.Name, "Run"))
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRegistry(t *testing.T) {
+	names := func(dares []*Dare) (s []string) {
+		for _, d := range dares {
+			s = append(s, d.Name)
+		}
+		return s
+	}
+	all := Dares()
+	if got, want := names(all), runFuncs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("registered dares %v do not match the Run functions %v", got, want)
+	}
+
+	// Each dare is selected by its name, ID, tags, and difficulty, and
+	// selecting by tag or difficulty yields exactly the dares having it.
+	selectors := map[string]bool{}
+	for _, d := range all {
+		if d.Description == "" || d.Difficulty == Unrated || len(d.Concepts) == 0 {
+			t.Errorf("%s: incomplete information %+v", d.Name, d.Info)
+		}
+		for _, m := range []string{d.Name, d.ID(), strings.ToUpper(d.ID())} {
+			if got := names(Dares(m)); !reflect.DeepEqual(got, []string{d.Name}) {
+				t.Errorf("%s: got %v; want [%s]", m, got, d.Name)
+			}
+		}
+		selectors[d.Difficulty.String()] = true
+		for _, tag := range d.Tags {
+			selectors[tag] = true
+		}
+	}
+	for m := range selectors {
+		var want []string
+		for _, d := range all {
+			if d.Matches(m) {
+				want = append(want, d.Name)
+			}
+		}
+		if got := names(Dares(m)); len(got) == 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v; want %v", m, got, want)
+		}
+	}
+	for _, m := range []string{"cloudstorage@v2", "unknown"} {
+		if got := Dares(m); len(got) != 0 {
+			t.Errorf("%s: got %v; want none", m, names(got))
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a dare twice did not panic")
+		}
+	}()
+	Register("CloudStorage", Info{}, nil)
+}
+
+func TestVersions(t *testing.T) {
+	var ran []int
+	Register("Versioned", Info{Tags: []string{"versioned"}}, func(t *testing.T, cfg *errtest.Config) {
+		ran = append(ran, 1)
+	})
+	Register("Versioned", Info{Tags: []string{"versioned"}, Version: 2}, func(t *testing.T, cfg *errtest.Config) {
+		ran = append(ran, 2)
+	})
+	defer func() {
+		mu.Lock()
+		delete(registry, "versioned@v1")
+		delete(registry, "versioned@v2")
+		mu.Unlock()
+	}()
+
+	ids := func(dares []*Dare) (s []string) {
+		for _, d := range dares {
+			s = append(s, d.ID())
+		}
+		return s
+	}
+	testCases := []struct {
+		match []string
+		want  []string
+	}{
+		{[]string{"Versioned"}, []string{"versioned@v2"}},
+		{[]string{"versioned"}, []string{"versioned@v2"}},
+		{[]string{"versioned@v1"}, []string{"versioned@v1"}},
+		{[]string{"versioned", "Versioned@v1"}, []string{"versioned@v1", "versioned@v2"}},
+	}
+	for _, tc := range testCases {
+		if got := ids(Dares(tc.match...)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %v; want %v", tc.match, got, tc.want)
+		}
+	}
+
+	for id, want := range map[string]string{
+		"versioned":    "versioned@v2",
+		"VERSIONED":    "versioned@v2",
+		"versioned@v1": "versioned@v1",
+		"Versioned@v2": "versioned@v2",
+		"versioned@v3": "",
+		"unknown":      "",
+	} {
+		got := ""
+		if d := Lookup(id); d != nil {
+			got = d.ID()
+		}
+		if got != want {
+			t.Errorf("Lookup(%q): got %q; want %q", id, got, want)
+		}
+	}
+
+	// Each version runs with its own run function.
+	Lookup("versioned@v1").Run(t, nil)
+	Lookup("versioned").Run(t, nil)
+	if want := []int{1, 2}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran versions %v; want %v", ran, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a version twice did not panic")
+		}
+	}()
+	Register("Versioned", Info{Version: 1}, nil)
+}
+
+func TestSolve(t *testing.T) {
+	Register("Unsolved", Info{}, nil)
+	defer func() {
+		mu.Lock()
+		delete(registry, "unsolved@v1")
+		mu.Unlock()
+	}()
+	d := Lookup("unsolved")
+	t.Run("unsolved", func(t *testing.T) {
+		d.Run(t, nil)
+		t.Error("dare without solution was not skipped")
+	})
+	ran := false
+	Solve("Unsolved", func(t *testing.T, cfg *errtest.Config) { ran = true })
+	d.Run(t, nil)
+	if !ran {
+		t.Error("solution was not run")
+	}
+
+	for _, id := range []string{"unsolved", "unknown"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Solve(%q) did not panic", id)
+				}
+			}()
+			Solve(id, nil)
+		}()
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	var got []string
+	record := func(t *testing.T, cfg *errtest.Config) {
+		got = append(got, cfg.CacheKey)
+	}
+	Register("Cached", Info{Version: 3}, record)
+	defer func() {
+		mu.Lock()
+		delete(registry, "cached@v3")
+		mu.Unlock()
+	}()
+	d := Lookup("cached")
+	d.Run(t, &errtest.Config{CacheDir: t.TempDir()})
+	d.Run(t, &errtest.Config{CacheDir: t.TempDir(), CacheKey: "custom"})
+	d.Run(t, &errtest.Config{})
+	if want := []string{"cached@v3", "custom", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got cache keys %q; want %q", got, want)
+	}
+}
+
+func TestDifficulty(t *testing.T) {
+	for d, want := range map[Difficulty]string{
+		Unrated:       "unrated",
+		Beginner:      "beginner",
+		Advanced:      "advanced",
+		Difficulty(9): "Difficulty(9)",
+	} {
+		if got := d.String(); got != want {
+			t.Errorf("%d: got %q; want %q", int(d), got, want)
+		}
+	}
+}
+
+func TestWriteCatalog(t *testing.T) {
+	b := &strings.Builder{}
+	if err := WriteCatalog(b, Dares("PipeConvert")); err != nil {
+		t.Fatal(err)
+	}
+	want := `## PipeConvert
+
+copy scanned input into a pipe written from a goroutine
+
+- ID: pipeconvert@v1
+- Difficulty: intermediate
+- Concepts: errors across goroutines, panics in goroutines
+- Tags: pipe, goroutine
+- Source: <https://github.com/cmars/represent/blob/e19ef73980e42e849cd026150516a5cdb9f827bc/pkg/represent/eol.go>
+
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// The dares in this file are parameterized by a size n. Their difficulty, and
+// the number of scenarios, grows with n. Unless the Config limits the
+// enumeration with Short, MaxFaults, or Samples, these dares only run the
+// scenarios with at most two faults.
+
+// scale returns the configuration for a dare of size n.
+func scale(t *testing.T, cfg *errtest.Config, n int, opts []errtest.ConfigOption) *errtest.Config {
+	if n < 1 {
+		t.Fatalf("size of dare must be positive; got %d", n)
+	}
+	cfg = cfg.With(opts...)
+	if cfg == nil || cfg.MaxFaults == 0 && cfg.Samples == 0 && !cfg.Short {
+		cfg = cfg.With(errtest.WithMaxFaults(2))
+	}
+	return cfg
+}
+
+// The Pipeline challenge: create a sink, wrap it in n stages, and write to the
+// outermost stage. Each stage, and the sink, must be closed after the stage
+// wrapping it with CloseWithError and the first error encountered, including
+// those returned by closing the stages. Any stage may panic when opened or
+// closed.
+//
+// The Pipeline dare generalizes TrickyCatch to any number of wrappers.
+type Pipeline struct {
+	s      *errtest.Simulation
+	n      int
+	stages int
+}
+
+func init() {
+	Register("Pipeline", Info{
+		Description: "write to a writer wrapped in several stages, closing each with the right error",
+		Tags:        []string{"close", "panic", "scaled"},
+		Difficulty:  Advanced,
+		Concepts:    []string{"errors of deferred closes", "defer in loops"},
+	}, nil)
+}
+
+// RunPipeline runs the Pipeline dare with n stages as a test. The options, if
+// any, override cfg for this dare only.
+func RunPipeline(t *testing.T, cfg *errtest.Config, n int, f func(p *Pipeline) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, scale(t, cfg, n, opts), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&Pipeline{s: s, n: n}), "write")
+	})
+}
+
+// N returns the number of stages that must be created.
+func (p *Pipeline) N() int { return p.n }
+
+// NewSink returns the Writer to be wrapped by the first stage.
+func (p *Pipeline) NewSink() (Writer, error) {
+	return ve(p.s, "sink", errtest.Describe("the Writer returned by NewSink"))
+}
+
+func stageKey(i int) string {
+	if i == 0 {
+		return "sink"
+	}
+	return fmt.Sprintf("stage%d", i)
+}
+
+// NewStage returns a Writer that wraps w, which must be the Writer returned by
+// NewSink for the first stage, or by the previous call to NewStage otherwise.
+func (p *Pipeline) NewStage(w Writer) (Writer, error) {
+	if p.stages == p.n {
+		p.s.Fatalf("more than %d stages created", p.n)
+	}
+	require(p.s, w, stageKey(p.stages))
+	p.stages++
+	key := stageKey(p.stages)
+	return ve(p.s, key, errtest.Describe(fmt.Sprintf("the Writer returned by call %d to NewStage", p.stages)))
+}
+
+// Write writes something to the Writer of the last stage.
+func (p *Pipeline) Write(w Writer) error {
+	require(p.s, w, stageKey(p.n))
+	return e(p.s, "write")
+}
+
+// The MultiReader challenge: open n readers, concatenate them, and read from
+// the result. All readers must be closed and any error, including those
+// returned by closing the readers, must be returned.
+type MultiReader struct {
+	s       *errtest.Simulation
+	n       int
+	readers int
+}
+
+func init() {
+	Register("MultiReader", Info{
+		Description: "read from the concatenation of several readers, closing all of them",
+		Tags:        []string{"close", "scaled"},
+		Difficulty:  Intermediate,
+		Concepts:    []string{"errors of deferred closes", "defer in loops"},
+	}, nil)
+}
+
+// RunMultiReader runs the MultiReader dare with n readers as a test. The
+// options, if any, override cfg for this dare only.
+func RunMultiReader(t *testing.T, cfg *errtest.Config, n int, f func(m *MultiReader) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, scale(t, cfg, n, opts), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&MultiReader{s: s, n: n}), "read")
+	})
+}
+
+// N returns the number of readers that must be opened.
+func (m *MultiReader) N() int { return m.n }
+
+// NewReader returns the next Reader. It must be called N times.
+func (m *MultiReader) NewReader() (Reader, error) {
+	if m.readers == m.n {
+		m.s.Fatalf("more than %d readers opened", m.n)
+	}
+	key := fmt.Sprintf("reader%d", m.readers)
+	m.readers++
+	return ve(m.s, key, errtest.Describe(fmt.Sprintf("the Reader returned by call %d to NewReader", m.readers)))
+}
+
+// Concat returns the concatenation of the given readers, which must be all
+// readers in the order in which they were opened. The result need not be
+// closed.
+func (m *MultiReader) Concat(rs ...Reader) Value {
+	if len(rs) != m.n {
+		m.s.Fatalf("got %d readers; want %d", len(rs), m.n)
+	}
+	for i, r := range rs {
+		require(m.s, r, fmt.Sprintf("reader%d", i))
+	}
+	do(m.s, "concat")
+	return key("concat")
+}
+
+// Read reads from the Value returned by Concat.
+func (m *MultiReader) Read(r Value) error {
+	require(m.s, r, "concat")
+	return e(m.s, "read")
+}
@@ -0,0 +1,30 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestScale(t *testing.T) {
+	testCases := []struct {
+		cfg  *errtest.Config
+		want int
+	}{
+		{nil, 2},
+		{&errtest.Config{}, 2},
+		{&errtest.Config{MaxFaults: 3}, 3},
+		{&errtest.Config{Samples: 10}, 0},
+		{&errtest.Config{Short: true}, 0},
+		{&errtest.Config{Short: true, MaxFaults: 2}, 2},
+	}
+	for _, tc := range testCases {
+		if got := scale(t, tc.cfg, 1, nil).MaxFaults; got != tc.want {
+			t.Errorf("%+v: got MaxFaults %d; want %d", tc.cfg, got, tc.want)
+		}
+	}
+}
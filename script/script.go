@@ -0,0 +1,188 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package script runs registered dares from testscript scripts, so that the
+// configuration and expected outcome of dares can be expressed as txtar files
+// instead of Go code.
+//
+// In addition to the standard testscript commands, scripts may use
+//
+//	config [option...]
+//		Reset the configuration used by subsequent dare commands and
+//		apply the given options. The options are pedantic, relaxed,
+//		panic_order, panic_close, wrapping, short, maxfaults=N,
+//		maxsteps=N, and samples=N[,seed].
+//
+//	[!] dare [-json file] match...
+//		Run the registered dares matching any of the given names,
+//		difficulties, or tags as subtests. With !, each dare is expected
+//		to fail. With -json, the outcome of the dares is written to file
+//		as by errdare.WriteGrades, so that it can be compared with cmp.
+//
+// For instance,
+//
+//	config short
+//	dare CloudStorage
+//
+//	config pedantic
+//	! dare -json got.json TrickyCatch
+//	cmp got.json want.json
+package script
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mpvl/errdare"
+	"github.com/mpvl/errdare/errtest"
+	"github.com/rogpeppe/go-internal/testscript"
+)
+
+// Run runs the scripts in p.Dir as subtests of t, with the commands described
+// in the package documentation added to p.Cmds. Commands already in p.Cmds
+// take precedence.
+func Run(t *testing.T, p testscript.Params) {
+	cmds := map[string]func(ts *testscript.TestScript, neg bool, args []string){
+		"config": cmdConfig,
+		"dare":   cmdDare,
+	}
+	for name, cmd := range p.Cmds {
+		cmds[name] = cmd
+	}
+	p.Cmds = cmds
+
+	setup := p.Setup
+	p.Setup = func(e *testscript.Env) error {
+		e.Values[stateKey{}] = &state{t: e.T().(shim).T, config: &errtest.Config{}}
+		if setup != nil {
+			return setup(e)
+		}
+		return nil
+	}
+	testscript.RunT(shim{t}, p)
+}
+
+// shim is a testscript.T that gives commands access to the *testing.T of the
+// script.
+type shim struct {
+	*testing.T
+}
+
+func (t shim) Run(name string, f func(testscript.T)) {
+	t.T.Run(name, func(t *testing.T) { f(shim{t}) })
+}
+
+func (t shim) Verbose() bool { return testing.Verbose() }
+
+type stateKey struct{}
+
+// state is the state of a single script.
+type state struct {
+	t      *testing.T
+	config *errtest.Config
+}
+
+func getState(ts *testscript.TestScript) *state {
+	return ts.Value(stateKey{}).(*state)
+}
+
+func cmdConfig(ts *testscript.TestScript, neg bool, args []string) {
+	if neg {
+		ts.Fatalf("unsupported: ! config")
+	}
+	c, err := parseConfig(args)
+	ts.Check(err)
+	getState(ts).config = c
+}
+
+// parseConfig returns the configuration described by the given options.
+func parseConfig(args []string) (*errtest.Config, error) {
+	c := &errtest.Config{IgnorePanicOrder: true}
+	for _, a := range args {
+		name, value, hasValue := strings.Cut(a, "=")
+		var n int
+		var seed int64
+		switch name {
+		case "maxfaults", "maxsteps", "samples":
+			v, s, hasSeed := strings.Cut(value, ",")
+			var err error
+			if n, err = strconv.Atoi(v); !hasValue || err != nil {
+				return nil, fmt.Errorf("config: invalid value for %s: %q", name, value)
+			}
+			if hasSeed && name == "samples" {
+				if seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+					return nil, fmt.Errorf("config: invalid seed for %s: %q", name, s)
+				}
+			}
+		default:
+			if hasValue {
+				return nil, fmt.Errorf("config: option %s does not take a value", name)
+			}
+		}
+		switch name {
+		case "pedantic":
+			*c = *errtest.Pedantic
+		case "relaxed":
+			*c = *errtest.Relaxed
+		case "panic_order":
+			c.IgnorePanicOrder = false
+		case "panic_close":
+			c.RequireCloseOnPanic = true
+		case "wrapping":
+			c.AllowWrapping = true
+		case "short":
+			c.Short = true
+		case "maxfaults":
+			c.MaxFaults = n
+		case "maxsteps":
+			c.MaxSteps = n
+		case "samples":
+			c.Samples, c.Seed = n, seed
+		default:
+			return nil, fmt.Errorf("config: unknown option %q", name)
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func cmdDare(ts *testscript.TestScript, neg bool, args []string) {
+	jsonFile := ""
+	if len(args) >= 2 && args[0] == "-json" {
+		jsonFile, args = args[1], args[2:]
+	}
+	if len(args) == 0 {
+		ts.Fatalf("usage: dare [-json file] match...")
+	}
+	if len(errdare.Dares(args...)) == 0 {
+		ts.Fatalf("no dares match %s", strings.Join(args, ", "))
+	}
+	st := getState(ts)
+	cfg := *st.config
+	cfg.ExpectFailure = neg
+	grades := errdare.GradeAll(st.t, &cfg, args...)
+
+	if jsonFile != "" {
+		f, err := os.Create(ts.MkAbs(jsonFile))
+		ts.Check(err)
+		err = errdare.WriteGrades(f, grades)
+		if errC := f.Close(); err == nil {
+			err = errC
+		}
+		ts.Check(err)
+	}
+	for _, g := range grades {
+		switch {
+		case g.Passed && neg:
+			ts.Fatalf("dare %s passed unexpectedly", g.Dare)
+		case !g.Passed && !neg:
+			ts.Fatalf("dare %s failed", g.Dare)
+		}
+	}
+}
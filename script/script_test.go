@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package script
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mpvl/errdare"
+	"github.com/mpvl/errdare/errtest"
+	"github.com/rogpeppe/go-internal/testscript"
+)
+
+func init() {
+	copyDare := func(name string) *errdare.Builder {
+		return errdare.NewDare(name).
+			Resource("reader", errdare.Closeable, errdare.IgnoreCloseError).
+			Step("copy", errdare.Requires("reader")).
+			MustCall("copy")
+	}
+	copyDare("GoodCopy").Register(errdare.Info{Tags: []string{"copy"}, Difficulty: errdare.Beginner}, func(d *errdare.Instance) error {
+		r, err := d.Open("reader")
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return d.Do("copy", r)
+	})
+	copyDare("LeakyCopy").Register(errdare.Info{Tags: []string{"copy", "leak"}}, func(d *errdare.Instance) error {
+		r, err := d.Open("reader")
+		if err != nil {
+			return err
+		}
+		if err := d.Do("copy", r); err != nil {
+			return err // does not close r
+		}
+		r.Close()
+		return nil
+	})
+}
+
+func TestScripts(t *testing.T) {
+	Run(t, testscript.Params{Dir: "testdata"})
+}
+
+func TestParseConfig(t *testing.T) {
+	testCases := []struct {
+		args []string
+		want *errtest.Config
+		err  bool
+	}{{
+		want: &errtest.Config{IgnorePanicOrder: true},
+	}, {
+		args: []string{"pedantic"},
+		want: &errtest.Config{RequireCloseOnPanic: true},
+	}, {
+		args: []string{"panic_order", "wrapping", "short"},
+		want: &errtest.Config{AllowWrapping: true, Short: true},
+	}, {
+		args: []string{"maxfaults=2", "samples=10,3"},
+		want: &errtest.Config{IgnorePanicOrder: true, MaxFaults: 2, Samples: 10, Seed: 3},
+	}, {
+		args: []string{"maxfaults"},
+		err:  true,
+	}, {
+		args: []string{"maxsteps=-1"},
+		err:  true,
+	}, {
+		args: []string{"short=1"},
+		err:  true,
+	}, {
+		args: []string{"unknown"},
+		err:  true,
+	}}
+	for _, tc := range testCases {
+		got, err := parseConfig(tc.args)
+		if (err != nil) != tc.err {
+			t.Errorf("%q: got error %v; want error %v", tc.args, err, tc.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %+v; want %+v", tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// The GracefulShutdown challenge: start a server, process a request, shut the
+// server down, and drain the work in flight. The server must be shut down and
+// drained even if processing fails.
+//
+// Both Process and Drain may fail with errors wrapping context.Canceled, but
+// their meaning differs. An error of Process means the request was canceled
+// by someone else and must be returned. An error of Drain wrapping
+// context.Canceled is the expected consequence of shutting down and must be
+// ignored, while other errors of Drain must be returned.
+//
+// A simple, but incorrect, implementation is:
+//
+//	func TestGracefulShutdown(t *testing.T) {
+//		errdare.RunGracefulShutdown(t, nil, func(g *errdare.GracefulShutdown) (err error) {
+//			srv := g.Start()
+//			defer func() {
+//				g.Shutdown(srv)
+//				if errD := g.Drain(); err == nil {
+//					err = errD
+//				}
+//			}()
+//			err = g.Process(srv)
+//			if errors.Is(err, context.Canceled) {
+//				return nil // only benign during shutdown
+//			}
+//			return err
+//		})
+//	}
+type GracefulShutdown struct {
+	s        *errtest.Simulation
+	shutdown bool
+}
+
+func init() {
+	Register("GracefulShutdown", Info{
+		Description: "shut down a server, ignoring only the cancellations it causes",
+		Tags:        []string{"context", "inspect"},
+		Difficulty:  Intermediate,
+		Concepts:    []string{"context.Canceled", "benign errors", "classifying errors"},
+	}, nil)
+}
+
+// RunGracefulShutdown runs the GracefulShutdown dare as a test. The options,
+// if any, override cfg for this dare only.
+func RunGracefulShutdown(t *testing.T, cfg *errtest.Config, f func(g *GracefulShutdown) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&GracefulShutdown{s: s}), "process")
+	})
+}
+
+// Start starts the server, which must be passed to Shutdown.
+func (g *GracefulShutdown) Start() Value {
+	return v(g.s, "server",
+		errtest.Describe("the server returned by Start"),
+		errtest.CloseOptions(errtest.NoError(), errtest.NoPanic()))
+}
+
+// Process processes a request on the server. An error wrapping
+// context.Canceled indicates that the request was canceled by its client.
+func (g *GracefulShutdown) Process(srv Value) error {
+	require(g.s, srv, "server")
+	if g.shutdown {
+		g.s.Fatalf("Process called after Shutdown")
+	}
+	return e(g.s, "process", errtest.NoPanic(), errtest.Wrap(context.Canceled))
+}
+
+// Shutdown initiates the shutdown of the server, which cancels the work in
+// flight. Drain must be called afterwards.
+func (g *GracefulShutdown) Shutdown(srv Value) {
+	require(g.s, srv, "server")
+	g.s.Close("server")
+	g.shutdown = true
+	g.s.Open("drain", errtest.NoError(), errtest.NoPanic(),
+		errtest.Describe("the work in flight of Shutdown"),
+		errtest.CloseOptions(errtest.NoPanic(), errtest.IgnoreError(), errtest.Wrap(context.Canceled)))
+}
+
+// Drain waits for the work in flight to complete. It returns an error
+// wrapping context.Canceled for work canceled by the shutdown, or any other
+// error that occurred while flushing the work.
+func (g *GracefulShutdown) Drain() error {
+	if !g.shutdown {
+		g.s.Fatalf("Drain called before Shutdown")
+	}
+	if err := e(g.s, "flush", errtest.NoPanic()); err != nil {
+		g.s.Close("drain", errtest.NoError())
+		return err
+	}
+	return g.s.Close("drain")
+}
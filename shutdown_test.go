@@ -0,0 +1,80 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(g *GracefulShutdown) error
+	}{{
+		desc: "drain error returned",
+		f: func(g *GracefulShutdown) (err error) {
+			srv := g.Start()
+			defer func() {
+				g.Shutdown(srv)
+				if errD := g.Drain(); err == nil {
+					err = errD
+				}
+			}()
+			return g.Process(srv)
+		},
+	}, {
+		desc: "all drain errors ignored",
+		f: func(g *GracefulShutdown) (err error) {
+			srv := g.Start()
+			defer func() {
+				g.Shutdown(srv)
+				g.Drain()
+			}()
+			return g.Process(srv)
+		},
+	}, {
+		desc: "not drained",
+		f: func(g *GracefulShutdown) (err error) {
+			srv := g.Start()
+			defer g.Shutdown(srv)
+			return g.Process(srv)
+		},
+	}, {
+		desc: "drained before shutdown",
+		f: func(g *GracefulShutdown) (err error) {
+			srv := g.Start()
+			if err := g.Process(srv); err != nil {
+				return err
+			}
+			err = g.Drain()
+			g.Shutdown(srv)
+			return err
+		},
+	}, {
+		desc: "process canceled ignored",
+		f: func(g *GracefulShutdown) (err error) {
+			srv := g.Start()
+			defer func() {
+				g.Shutdown(srv)
+				if errD := g.Drain(); err == nil && !errors.Is(errD, context.Canceled) {
+					err = errD
+				}
+			}()
+			if err := g.Process(srv); !errors.Is(err, context.Canceled) {
+				return err
+			}
+			return nil
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			RunGracefulShutdown(t, nil, tc.f, errtest.WithExpectFailure())
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+// The Upload challenge: start an upload, write a part to it, and commit it.
+// The upload returned by Begin must be closed with CloseWithError, passing nil
+// to commit it or the first error or panic encountered to abort it. The part
+// returned by NewPart must be closed, which flushes its data into the upload.
+//
+// The order of the closes depends on the outcome. On success, the part must
+// be closed before the upload is committed, so that its data is included and
+// the error of flushing it is reported. On failure, the upload must be aborted
+// before the part is closed, so that closing the part does not flush
+// incomplete data into it. Deferring the closes in the order of opening
+// therefore does not suffice.
+//
+// A simple, but incorrect, implementation is:
+//
+//	func TestUpload(t *testing.T) {
+//		errdare.RunUpload(t, nil, func(u *errdare.Upload) (err error) {
+//			up, err := u.Begin()
+//			if err != nil {
+//				return err
+//			}
+//			defer func() {
+//				if errC := up.CloseWithError(err); err == nil {
+//					err = errC
+//				}
+//			}()
+//			part, err := u.NewPart(up)
+//			if err != nil {
+//				return err
+//			}
+//			defer func() {
+//				if errC := part.Close(); err == nil { // flushes on failure
+//					err = errC
+//				}
+//			}()
+//			return u.Write(part)
+//		})
+//	}
+type Upload struct {
+	s *errtest.Simulation
+}
+
+func init() {
+	Register("Upload", Info{
+		Description: "commit an upload after closing its part, or abort it before doing so",
+		Tags:        []string{"close", "order"},
+		Difficulty:  Advanced,
+		Concepts:    []string{"close order", "commit and rollback", "CloseWithError"},
+	}, nil)
+}
+
+// RunUpload runs the Upload dare as a test. The options, if any, override cfg
+// for this dare only.
+func RunUpload(t *testing.T, cfg *errtest.Config, f func(u *Upload) error, opts ...errtest.ConfigOption) {
+	errtest.Run(t, cfg.With(opts...), func(s *errtest.Simulation) error {
+		return mustCall(s, f(&Upload{s}), "part.write")
+	})
+}
+
+// Begin starts the upload, which must be closed with CloseWithError.
+func (u *Upload) Begin() (Writer, error) {
+	return ve(u.s, "upload",
+		errtest.Describe("the upload returned by Begin"),
+		errtest.AbortFirst(),
+		errtest.CloseOptions(errtest.NoPanic()))
+}
+
+// NewPart adds a part to the upload up, which must be closed before up is
+// committed, but only after up is aborted.
+func (u *Upload) NewPart(up Writer) (Writer, error) {
+	require(u.s, up, "upload")
+	return ve(u.s, "part",
+		errtest.Describe("the part returned by NewPart"),
+		errtest.CloseOptions(errtest.NoPanic()))
+}
+
+// Write writes the contents of the part.
+func (u *Upload) Write(part Writer) error {
+	require(u.s, part, "part")
+	_, err := part.Write(nil)
+	return err
+}
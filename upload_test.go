@@ -0,0 +1,61 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package errdare
+
+import (
+	"testing"
+
+	"github.com/mpvl/errdare/errtest"
+)
+
+func TestUpload(t *testing.T) {
+	testCases := []struct {
+		desc string
+		f    func(u *Upload) error
+	}{{
+		desc: "part closed before abort",
+		f: func(u *Upload) (err error) {
+			up, err := u.Begin()
+			if err != nil {
+				return err
+			}
+			defer func() { up.CloseWithError(err) }()
+			part, err := u.NewPart(up)
+			if err != nil {
+				return err
+			}
+			defer part.Close()
+			return u.Write(part)
+		},
+	}, {
+		desc: "committed before part closed",
+		f: func(u *Upload) (err error) {
+			up, err := u.Begin()
+			if err != nil {
+				return err
+			}
+			part, err := u.NewPart(up)
+			if err != nil {
+				up.CloseWithError(err)
+				return err
+			}
+			defer func() {
+				if err != nil {
+					up.CloseWithError(err)
+					part.Close()
+					return
+				}
+				err = up.CloseWithError(nil)
+				part.Close()
+			}()
+			return u.Write(part)
+		},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			RunUpload(t, nil, tc.f, errtest.WithExpectFailure())
+		})
+	}
+}